@@ -1,13 +1,14 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/podspawn/podspawn/internal/audit"
 	"github.com/podspawn/podspawn/internal/config"
 	"github.com/podspawn/podspawn/internal/podfile"
 	"github.com/podspawn/podspawn/internal/runtime"
@@ -22,6 +23,8 @@ var addProjectCmd = &cobra.Command{
 		name := args[0]
 		repo, _ := cmd.Flags().GetString("repo")
 		branch, _ := cmd.Flags().GetString("branch")
+		allowUsers, _ := cmd.Flags().GetStringSlice("allow-user")
+		allowGroups, _ := cmd.Flags().GetStringSlice("allow-group")
 
 		projects, err := config.LoadProjects(cfg.ProjectsFile)
 		if err != nil {
@@ -30,6 +33,9 @@ var addProjectCmd = &cobra.Command{
 		if _, exists := projects[name]; exists {
 			return fmt.Errorf("project %q already registered; use update-project to rebuild", name)
 		}
+		if err := podfile.LoadRecipes(cfg.PackagesDir); err != nil {
+			return err
+		}
 
 		ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Minute)
 		defer cancel()
@@ -39,17 +45,13 @@ var addProjectCmd = &cobra.Command{
 			return err
 		}
 
-		raw, err := podfile.FindAndRead(localPath)
+		access := config.ProjectConfig{AllowedUsers: allowUsers, AllowedGroups: allowGroups}
+		src, err := loadPodfile(localPath, projects, access)
 		if err != nil {
 			os.RemoveAll(localPath) //nolint:errcheck
-			return fmt.Errorf("no podfile found in %s: %w", repo, err)
-		}
-
-		pf, err := podfile.Parse(bytes.NewReader(raw))
-		if err != nil {
-			os.RemoveAll(localPath) //nolint:errcheck
-			return err
+			return fmt.Errorf("loading podfile from %s: %w", repo, err)
 		}
+		printWarnings(src.Warnings)
 
 		rt, err := runtime.NewDockerRuntime()
 		if err != nil {
@@ -57,7 +59,7 @@ var addProjectCmd = &cobra.Command{
 			return err
 		}
 
-		tag, err := podfile.BuildImageFromPodfile(ctx, rt, pf, raw, name)
+		tag, err := podfile.BuildImageFromPodfile(ctx, rt, src, name)
 		if err != nil {
 			os.RemoveAll(localPath) //nolint:errcheck
 			return err
@@ -66,21 +68,55 @@ var addProjectCmd = &cobra.Command{
 		projects[name] = config.ProjectConfig{
 			Repo:        repo,
 			LocalPath:   localPath,
-			PodfileHash: podfile.ComputeTag(name, raw),
+			PodfileHash: tag,
 			ImageTag:    tag,
+
+			AllowedUsers:  allowUsers,
+			AllowedGroups: allowGroups,
 		}
 		if err := config.SaveProjects(cfg.ProjectsFile, projects); err != nil {
 			return err
 		}
 
+		auditAdmin(audit.Event{Action: "add-project", Project: name, Detail: "repo " + repo})
 		fmt.Fprintf(os.Stderr, "project %s registered, image: %s\n", name, tag)
 		return nil
 	},
 }
 
+// loadPodfile loads a project's Podfile to build its image from. The
+// dev user's sudo has to be installed in the image.
+func loadPodfile(dir string, projects map[string]config.ProjectConfig, access config.ProjectConfig) (*podfile.Source, error) {
+	src, err := podfile.Load(dir, podfileResolver(projects, access))
+	if err != nil {
+		return nil, err
+	}
+	src.Sudo = cfg.Defaults.DevUser.Enabled && cfg.Defaults.DevUser.Sudo
+	return src, nil
+}
+
+// podfileResolver resolves extends for a project with the given
+// access list. If the groups file can't be read, a parent's groups
+// cover no one.
+func podfileResolver(projects map[string]config.ProjectConfig, access config.ProjectConfig) *podfile.Resolver {
+	groups, err := config.LoadGroups(cfg.GroupsFile)
+	if err != nil {
+		slog.Warn("failed to load groups for extends access checks", "error", err)
+	}
+	return &podfile.Resolver{Projects: projects, TemplateDir: cfg.TemplatesDir, Access: access, Groups: groups}
+}
+
+func printWarnings(warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w) //nolint:errcheck
+	}
+}
+
 func init() {
 	addProjectCmd.Flags().String("repo", "", "git repository URL")
 	addProjectCmd.Flags().String("branch", "", "git branch (default: repo default)")
+	addProjectCmd.Flags().StringSlice("allow-user", nil, "restrict the project to this user (repeatable)")
+	addProjectCmd.Flags().StringSlice("allow-group", nil, "restrict the project to members of this group from groups_file (repeatable)")
 	_ = addProjectCmd.MarkFlagRequired("repo")
 	rootCmd.AddCommand(addProjectCmd)
 }
@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/podspawn/podspawn/internal/adduser"
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/keyfile"
+	"github.com/podspawn/podspawn/internal/keysync"
 	"github.com/spf13/cobra"
 )
 
@@ -24,45 +30,41 @@ var addUserCmd = &cobra.Command{
 		keyStrings, _ := cmd.Flags().GetStringSlice("key")
 		keyFiles, _ := cmd.Flags().GetStringSlice("key-file")
 		github, _ := cmd.Flags().GetString("github")
+		expiresFlag, _ := cmd.Flags().GetString("expires")
+		sourceFlags, _ := cmd.Flags().GetStringSlice("source")
 
-		if len(keyStrings) == 0 && len(keyFiles) == 0 && github == "" {
-			return fmt.Errorf("at least one of --key, --key-file, or --github is required")
+		direct := len(keyStrings) > 0 || len(keyFiles) > 0 || github != ""
+		if !direct && len(sourceFlags) == 0 {
+			return fmt.Errorf("at least one of --key, --key-file, --github, or --source is required")
 		}
-
-		var keys []string
-		keys = append(keys, keyStrings...)
-
-		for _, path := range keyFiles {
-			fileKeys, err := adduser.ReadKeyFile(path)
+		if !direct && expiresFlag != "" {
+			return fmt.Errorf("--expires applies to --key, --key-file and --github keys; synced keys follow their source")
+		}
+		var sources []string
+		for _, src := range sourceFlags {
+			normalized, err := keysync.ParseSource(src)
 			if err != nil {
-				return fmt.Errorf("reading %s: %w", path, err)
-			}
-			if len(fileKeys) == 0 {
-				return fmt.Errorf("no valid SSH keys found in %s", path)
+				return err
 			}
-			keys = append(keys, fileKeys...)
+			sources = append(sources, normalized)
 		}
 
-		if github != "" {
-			ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
-			defer cancel()
-			ghKeys, err := adduser.FetchGitHubKeys(ctx, http.DefaultClient, github)
-			if err != nil {
-				return fmt.Errorf("fetching GitHub keys: %w", err)
-			}
-			if len(ghKeys) == 0 {
-				return fmt.Errorf("no SSH keys found for GitHub user %q", github)
+		var expires time.Time
+		if expiresFlag != "" {
+			var err error
+			if expires, err = keyfile.ParseExpiry(expiresFlag, time.Now()); err != nil {
+				return err
 			}
-			keys = append(keys, ghKeys...)
 		}
 
-		keyDir := cfg.Auth.KeyDir
-		n, err := adduser.WriteKeys(keyDir, username, keys)
-		if err != nil {
-			return err
+		if direct {
+			if err := addDirectKeys(cmd, username, keyStrings, keyFiles, github, expires); err != nil {
+				return err
+			}
+		}
+		if len(sources) > 0 {
+			return addKeySources(cmd, username, sources)
 		}
-
-		fmt.Fprintf(os.Stderr, "added %d key(s) for %s\n", n, username)
 		return nil
 	},
 }
@@ -70,6 +72,78 @@ var addUserCmd = &cobra.Command{
 func init() {
 	addUserCmd.Flags().StringSlice("key", nil, "SSH public key string (repeatable)")
 	addUserCmd.Flags().StringSlice("key-file", nil, "path to SSH public key file (repeatable)")
-	addUserCmd.Flags().String("github", "", "GitHub username to import keys from")
+	addUserCmd.Flags().String("github", "", "GitHub username to import keys from once")
+	addUserCmd.Flags().StringSlice("source", nil, "keep keys synced from github:<user>, gitlab:<user>, or an https:// URL (repeatable)")
+	addUserCmd.Flags().String("expires", "", "expire the keys at a date (2026-12-31), RFC 3339 time, or after a duration (720h)")
 	rootCmd.AddCommand(addUserCmd)
 }
+
+func addDirectKeys(cmd *cobra.Command, username string, keyStrings, keyFiles []string, github string, expires time.Time) error {
+	var keys []string
+	keys = append(keys, keyStrings...)
+
+	for _, path := range keyFiles {
+		fileKeys, err := adduser.ReadKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		if len(fileKeys) == 0 {
+			return fmt.Errorf("no valid SSH keys found in %s", path)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	if github != "" {
+		ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+		defer cancel()
+		ghKeys, err := adduser.FetchGitHubKeys(ctx, http.DefaultClient, github)
+		if err != nil {
+			return fmt.Errorf("fetching GitHub keys: %w", err)
+		}
+		if len(ghKeys) == 0 {
+			return fmt.Errorf("no SSH keys found for GitHub user %q", github)
+		}
+		keys = append(keys, ghKeys...)
+	}
+
+	keyDir := cfg.Auth.KeyDir
+	n, err := adduser.WriteKeys(keyDir, username, keys, expires)
+	if err != nil {
+		return err
+	}
+
+	auditAdmin(audit.Event{Action: "add-user", User: username, Detail: fmt.Sprintf("%d key(s) added", n)})
+	if skipped := len(keys) - n; skipped > 0 {
+		fmt.Fprintf(os.Stderr, "added %d key(s) for %s (%d already registered)\n", n, username, skipped)
+	} else {
+		fmt.Fprintf(os.Stderr, "added %d key(s) for %s\n", n, username)
+	}
+	return nil
+}
+
+// addKeySources registers sources for username and syncs them right
+// away. A source that fails now stays registered for the next sync.
+func addKeySources(cmd *cobra.Command, username string, sources []string) error {
+	registry, err := config.LoadKeySources(cfg.Auth.KeySourcesFile)
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		if !slices.Contains(registry[username], src) {
+			registry[username] = append(registry[username], src)
+		}
+	}
+	if err := config.SaveKeySources(cfg.Auth.KeySourcesFile, registry); err != nil {
+		return err
+	}
+	auditAdmin(audit.Event{Action: "add-user", User: username, Detail: "key sources " + strings.Join(sources, ", ")})
+
+	results, err := newKeySyncer().SyncUser(cmd.Context(), username, sources)
+	if err != nil {
+		return err
+	}
+	if failed := reportKeySync(results); failed > 0 {
+		return fmt.Errorf("%d source(s) registered but not synced yet", failed)
+	}
+	return nil
+}
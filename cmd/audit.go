@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query or verify the audit log",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfg.Audit.Path == "" {
+			return fmt.Errorf("audit log is disabled (audit.path is empty)")
+		}
+		verify, _ := cmd.Flags().GetBool("verify")
+		if verify {
+			return verifyAuditLog(os.Stderr)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output != "table" && output != "json" {
+			return fmt.Errorf("invalid --output %q: must be table or json", output)
+		}
+		filter, err := auditFilter(cmd, time.Now())
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(cfg.Audit.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, "audit log is empty") //nolint:errcheck
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close() //nolint:errcheck
+
+		events, err := audit.Read(f, filter)
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range events {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return writeAuditTable(os.Stdout, events)
+	},
+}
+
+func auditFilter(cmd *cobra.Command, now time.Time) (audit.Filter, error) {
+	f := audit.Filter{}
+	f.User, _ = cmd.Flags().GetString("user")
+	f.Type, _ = cmd.Flags().GetString("type")
+	var err error
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		if f.Since, err = parseAuditTime(since, now); err != nil {
+			return f, fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if until, _ := cmd.Flags().GetString("until"); until != "" {
+		if f.Until, err = parseAuditTime(until, now); err != nil {
+			return f, fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// parseAuditTime accepts a duration back from now ("24h"), a date
+// (2026-01-31, local midnight) or an RFC 3339 timestamp.
+func parseAuditTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration, date or RFC 3339 time", s)
+}
+
+func writeAuditTable(w io.Writer, events []*audit.Event) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTYPE\tUSER\tPROJECT\tDETAIL") //nolint:errcheck
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", //nolint:errcheck
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Type, dash(e.User), dash(e.Project), auditDetail(e))
+	}
+	return tw.Flush()
+}
+
+// auditDetail folds the type-specific fields into one column.
+func auditDetail(e *audit.Event) string {
+	var parts []string
+	if e.Actor != "" {
+		parts = append(parts, "by "+e.Actor)
+	}
+	for _, p := range []string{e.Action, e.Result, e.Fingerprint, e.Detail} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if e.Command != "" {
+		parts = append(parts, fmt.Sprintf("command=%q", e.Command))
+	}
+	return dash(strings.Join(parts, " "))
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// verifyAuditLog checks the hash chain, that root's anchor is still in
+// it and, when entries are mirrored into the state db, that none of
+// them are missing from the file.
+func verifyAuditLog(out io.Writer) error {
+	f, err := os.Open(cfg.Audit.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	hashes, err := audit.Verify(f)
+	if err != nil {
+		fmt.Fprintf(out, "  FAIL  %s: %v\n", cfg.Audit.Path, err) //nolint:errcheck
+		return fmt.Errorf("audit log failed verification")
+	}
+	fmt.Fprintf(out, "  OK    %s: %d entries, chain intact\n", cfg.Audit.Path, len(hashes)) //nolint:errcheck
+
+	if cfg.Audit.Anchor != "" {
+		anchor, err := audit.ReadAnchor(cfg.Audit.Anchor)
+		if err != nil {
+			return err
+		}
+		if err := audit.CheckAnchor(hashes, anchor); err != nil {
+			fmt.Fprintf(out, "  FAIL  %s: %v\n", cfg.Audit.Anchor, err) //nolint:errcheck
+			return fmt.Errorf("audit log failed verification")
+		}
+		if anchor == "" {
+			fmt.Fprintf(out, "  --    %s: nothing anchored yet\n", cfg.Audit.Anchor) //nolint:errcheck
+		} else {
+			fmt.Fprintf(out, "  OK    %s: anchored entry present\n", cfg.Audit.Anchor) //nolint:errcheck
+		}
+	}
+
+	if !cfg.Audit.Database {
+		return nil
+	}
+	store, err := state.Open(cfg.State.DBPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+	mirrored, err := store.AuditHashes()
+	if err != nil {
+		return fmt.Errorf("reading audit mirror: %w", err)
+	}
+	if err := audit.CheckMirror(hashes, mirrored); err != nil {
+		fmt.Fprintf(out, "  FAIL  database mirror: %v\n", err) //nolint:errcheck
+		return fmt.Errorf("audit log failed verification")
+	}
+	fmt.Fprintf(out, "  OK    database mirror: %d entries, all present in the log\n", len(mirrored)) //nolint:errcheck
+	return nil
+}
+
+// newAuditLog returns the configured audit log, mirroring into store
+// when audit.database is on and a store is open. Only root moves the
+// anchor. Nil when auditing is disabled.
+func newAuditLog(store *state.Store) *audit.Log {
+	if cfg == nil || cfg.Audit.Path == "" {
+		return nil
+	}
+	l := &audit.Log{Path: cfg.Audit.Path}
+	if os.Geteuid() == 0 {
+		l.Anchor = cfg.Audit.Anchor
+	}
+	if cfg.Audit.Database && store != nil {
+		l.Mirror = store
+	}
+	return l
+}
+
+// auditAdmin records an admin command described by e (Action plus the
+// user or project it touched). The actor is the user who ran sudo, if
+// any, so the trail doesn't just say "root".
+func auditAdmin(e audit.Event) {
+	l := newAuditLog(nil)
+	if l == nil {
+		return
+	}
+	if cfg.Audit.Database {
+		store, err := state.Open(cfg.State.DBPath)
+		if err != nil {
+			slog.Warn("audit: state db unavailable, logging to file only", "error", err)
+		} else {
+			defer func() { _ = store.Close() }()
+			l.Mirror = store
+		}
+	}
+	e.Type = audit.TypeAdmin
+	e.Actor = adminActor()
+	l.Record(e)
+}
+
+func adminActor() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return fmt.Sprintf("uid %d", os.Getuid())
+}
+
+func init() {
+	auditCmd.Flags().String("user", "", "only show events for this user")
+	auditCmd.Flags().String("type", "", "only show events of this type (auth, session, session.create, admin, emergency, ...)")
+	auditCmd.Flags().String("since", "", "start of the time range: a duration back from now (24h), a date or an RFC 3339 time")
+	auditCmd.Flags().String("until", "", "end of the time range, in the same formats as --since")
+	auditCmd.Flags().StringP("output", "o", "table", "output format: table or json")
+	auditCmd.Flags().Bool("verify", false, "check the hash chain (and database mirror) for tampering")
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/audit"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"2026-05-01":           time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local),
+		"2026-05-01T08:30:00Z": time.Date(2026, 5, 1, 8, 30, 0, 0, time.UTC),
+	}
+	for in, want := range cases {
+		got, err := parseAuditTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseAuditTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseAuditTime("yesterday", now); err == nil {
+		t.Error("expected error for an unparseable time")
+	}
+}
+
+func TestLookupResult(t *testing.T) {
+	output := `command="/usr/local/bin/podspawn spawn --user alice",restrict ssh-ed25519 AAAAC3Nz laptop` + "\n"
+	cases := []struct {
+		n       int
+		offered []string
+		want    string
+	}{
+		{0, []string{"ssh-ed25519", "AAAAC3Nz"}, "no keys"},
+		{1, nil, "1 key(s) listed"},
+		{1, []string{"ssh-ed25519", "AAAAC3Nz"}, "accepted"},
+		{1, []string{"ssh-ed25519", "AAAAC3"}, "unknown key"},
+	}
+	for _, tc := range cases {
+		if got := lookupResult(tc.n, output, tc.offered); got != tc.want {
+			t.Errorf("lookupResult(%d, %v) = %q, want %q", tc.n, tc.offered, got, tc.want)
+		}
+	}
+}
+
+func TestWriteAuditTable(t *testing.T) {
+	events := []*audit.Event{
+		{Time: time.Now(), Type: audit.TypeAuth, User: "alice", Result: "accepted", Fingerprint: "SHA256:abc"},
+		{Time: time.Now(), Type: audit.TypeSessionCreate, User: "alice", Project: "backend", Command: "git pull"},
+		{Time: time.Now(), Type: audit.TypeAdmin, Actor: "ops", Action: "add-project", Project: "backend"},
+	}
+	var buf bytes.Buffer
+	if err := writeAuditTable(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header + 3 rows, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "accepted SHA256:abc") {
+		t.Errorf("auth row = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], `command="git pull"`) {
+		t.Errorf("session row = %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "by ops add-project") || !strings.Contains(lines[3], " - ") {
+		t.Errorf("admin row = %q, want actor and no user", lines[3])
+	}
+}
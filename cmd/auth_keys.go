@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/podspawn/podspawn/internal/audit"
 	"github.com/podspawn/podspawn/internal/authkeys"
+	"github.com/podspawn/podspawn/internal/keyfile"
 	"github.com/spf13/cobra"
 )
 
@@ -31,15 +36,119 @@ var authKeysCmd = &cobra.Command{
 		if !cmd.Flags().Changed("key-dir") && cfg != nil {
 			keyDir = cfg.Auth.KeyDir
 		}
-		n, err := authkeys.Lookup(username, keyDir, binPath, os.Stdout)
-		if err != nil {
-			slog.Error("auth-keys lookup failed", "user", username, "error", err)
-			return
+
+		// The emergency lookup runs first so nothing that goes wrong in
+		// the normal paths, a panic included, can stop it. Its lines are
+		// printed last: sshd uses the first line that matches, so a key
+		// in both files still gets the container.
+		emergencyKeys, emergency := lookupEmergency(username, binPath, args[1:])
+		defer os.Stdout.Write(emergencyKeys) //nolint:errcheck
+
+		result := lookupUser(username, keyDir, binPath, args[1:])
+		if emergency != "" && result != "accepted" {
+			result = emergency
 		}
-		slog.Debug("auth-keys", "user", username, "keys", n)
+		auditAuth(username, args[1:], result)
 	},
 }
 
+// lookupUser prints the keys that give username a container and
+// returns the result for the audit log.
+func lookupUser(username, keyDir, binPath string, offered []string) string {
+	// Certificates are matched against the trusted CAs; key files
+	// can't contain them, so there's no point reading those too.
+	if len(offered) == 2 && authkeys.IsCertType(offered[0]) {
+		return lookupCert(username, offered[0], offered[1], binPath)
+	}
+
+	var buf bytes.Buffer
+	n, err := authkeys.Lookup(username, keyDir, binPath, &buf)
+	_, _ = os.Stdout.Write(buf.Bytes())
+	if err != nil {
+		slog.Error("auth-keys lookup failed", "user", username, "error", err)
+		return "error"
+	}
+	slog.Debug("auth-keys", "user", username, "keys", n)
+	return lookupResult(n, buf.String(), offered)
+}
+
+// lookupResult says whether sshd will accept the offered key: it does
+// when the key is among the lines we printed. Without %t %k in
+// AuthorizedKeysCommand we only know how many keys were listed.
+func lookupResult(n int, output string, offered []string) string {
+	switch {
+	case n == 0:
+		return "no keys"
+	case len(offered) != 2:
+		return fmt.Sprintf("%d key(s) listed", n)
+	case listsKey(output, offered[0], offered[1]):
+		return "accepted"
+	default:
+		return "unknown key"
+	}
+}
+
+func listsKey(output, keyType, keyData string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == keyType && fields[i+1] == keyData {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// auditAuth records an auth-keys lookup, with the fingerprint of the
+// offered key when sshd passes it.
+func auditAuth(username string, offered []string, result string) {
+	e := audit.Event{Type: audit.TypeAuth, User: username, Result: result}
+	if len(offered) == 2 {
+		e.Fingerprint = (&keyfile.Key{Type: offered[0], Data: offered[1]}).Fingerprint()
+	}
+	newAuditLog(nil).Record(e)
+}
+
+// lookupEmergency returns the break-glass key lines bound to username,
+// and a result for the audit log when the offered key is one of them.
+func lookupEmergency(username, binPath string, offered []string) ([]byte, string) {
+	if cfg == nil {
+		return nil, ""
+	}
+	var buf bytes.Buffer
+	n, err := authkeys.LookupEmergency(username, cfg.Auth.EmergencyKeys, cfg.Auth.EmergencyUsers, binPath, &buf)
+	if err != nil {
+		slog.Error("auth-keys: emergency key lookup failed", "user", username, "error", err)
+		return nil, ""
+	}
+	if n == 0 || len(offered) != 2 || !listsKey(buf.String(), offered[0], offered[1]) {
+		return buf.Bytes(), ""
+	}
+	slog.Warn("auth-keys: EMERGENCY key offered, granting a host shell", "user", username,
+		"fingerprint", (&keyfile.Key{Type: offered[0], Data: offered[1]}).Fingerprint())
+	return buf.Bytes(), "emergency key accepted"
+}
+
+func lookupCert(username, keyType, keyData, binPath string) string {
+	if cfg == nil || len(cfg.Auth.CAKeys) == 0 {
+		slog.Debug("auth-keys: certificate presented but no CA configured", "user", username)
+		return "certificate, no CA configured"
+	}
+	caKeys, err := authkeys.LoadCAKeys(cfg.Auth.CAKeys)
+	if err != nil {
+		slog.Error("auth-keys: loading CA keys failed", "error", err)
+		return "error"
+	}
+	n, err := authkeys.LookupCert(username, keyType, keyData, caKeys, binPath, os.Stdout)
+	if err != nil {
+		slog.Info("auth-keys: certificate rejected", "user", username, "reason", err)
+		return "certificate rejected: " + err.Error()
+	}
+	slog.Debug("auth-keys", "user", username, "cert_authorities", n)
+	return "accepted"
+}
+
 func init() {
 	authKeysCmd.Flags().String("key-dir", "/etc/podspawn/keys", "directory containing per-user key files")
 	rootCmd.AddCommand(authKeysCmd)
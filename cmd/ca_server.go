@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/authkeys"
+	"github.com/podspawn/podspawn/internal/certsign"
+	"github.com/podspawn/podspawn/internal/oidc"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var caServerCmd = &cobra.Command{
+	Use:   "ca-server",
+	Short: "Issue short-lived SSH certificates to users who log in with OIDC",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oc := cfg.Auth.OIDC
+		if oc.Issuer == "" {
+			return fmt.Errorf("auth.oidc.issuer is not configured")
+		}
+		listen, _ := cmd.Flags().GetString("listen")
+		if listen == "" {
+			listen = oc.Listen
+		}
+		ttl, _ := time.ParseDuration(oc.CertTTL) // validated by config.Load
+
+		ca, err := certsign.LoadCAKey(oc.CAKey)
+		if err != nil {
+			return err
+		}
+		warnIfCAUntrusted(ca.PublicKey())
+
+		discoverCtx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+		provider, err := oidc.Discover(discoverCtx, http.DefaultClient, oc.Issuer)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		signer := &certsign.Signer{
+			CA:             ca,
+			Verifier:       &oidc.Verifier{Provider: provider, ClientID: oc.ClientID, Client: http.DefaultClient},
+			UsernameClaim:  oc.UsernameClaim,
+			AllowedDomains: oc.AllowedDomains,
+			TTL:            ttl,
+		}
+		srv := &http.Server{
+			Addr:              listen,
+			Handler:           signer.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+
+		slog.Info("ca-server started", "listen", listen, "issuer", oc.Issuer, "cert_ttl", ttl)
+		if oc.TLSCert != "" {
+			err = srv.ListenAndServeTLS(oc.TLSCert, oc.TLSKey)
+		} else {
+			slog.Warn("ca-server is serving plain HTTP; terminate TLS in front of it")
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		slog.Info("ca-server stopped")
+		return nil
+	},
+}
+
+func init() {
+	caServerCmd.Flags().String("listen", "", "address to listen on (default auth.oidc.listen)")
+	rootCmd.AddCommand(caServerCmd)
+}
+
+// warnIfCAUntrusted flags a CA whose certificates auth-keys on this
+// host would turn away.
+func warnIfCAUntrusted(pub ssh.PublicKey) {
+	trusted, err := authkeys.LoadCAKeys(cfg.Auth.CAKeys)
+	if err != nil {
+		slog.Warn("could not read auth.ca_keys", "error", err)
+		return
+	}
+	for _, k := range trusted {
+		if bytes.Equal(k.Marshal(), pub.Marshal()) {
+			return
+		}
+	}
+	slog.Warn("CA key is not listed in auth.ca_keys; servers must trust it for issued certificates to work",
+		"fingerprint", ssh.FingerprintSHA256(pub))
+}
@@ -2,19 +2,117 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/podspawn/podspawn/internal/cleanup"
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
 	"github.com/spf13/cobra"
 )
 
+// orphanMinAge covers the window between spawn creating a container and
+// recording its session row.
+const orphanMinAge = 5 * time.Minute
+
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Reconcile orphaned containers and enforce TTLs",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("cleanup: noop")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemon, _ := cmd.Flags().GetBool("daemon")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive, got %s", interval)
+		}
+		if daemon && dryRun {
+			return fmt.Errorf("--dry-run cannot be combined with --daemon")
+		}
+
+		rt, err := runtime.NewDockerRuntime()
+		if err != nil {
+			return err
+		}
+
+		store, err := state.Open(cfg.State.DBPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = store.Close() }()
+
+		// Validated by config.Load; empty means idle reaping is off
+		idleTimeout, _ := time.ParseDuration(cfg.Session.IdleTimeout)
+		snapshotTTL, _ := time.ParseDuration(cfg.Session.SnapshotTTL)
+
+		reaper := &cleanup.Reaper{
+			Runtime:     rt,
+			Store:       store,
+			LockDir:     cfg.State.LockDir,
+			IdleTimeout: idleTimeout,
+			Hibernate:   cfg.Session.Mode == "hibernate",
+			SnapshotTTL: snapshotTTL,
+
+			OrphanMinAge: orphanMinAge,
+			AgentDir:     cfg.State.AgentDir,
+			Audit:        newAuditLog(store),
+		}
+
+		if dryRun {
+			orphans, err := reaper.SweepOrphans(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			for _, o := range orphans {
+				fmt.Printf("would remove %s\n", o)
+			}
+			fmt.Fprintf(os.Stderr, "cleanup: dry run, %d orphan(s) found\n", len(orphans))
+			return nil
+		}
+
+		if !daemon {
+			n, err := reaper.RunOnce(cmd.Context())
+			fmt.Fprintf(os.Stderr, "cleanup: reaped %d session(s)\n", n)
+			if err != nil {
+				return err
+			}
+			removed, err := reaper.SweepOrphans(cmd.Context(), false)
+			for _, o := range removed {
+				fmt.Printf("removed %s\n", o)
+			}
+			fmt.Fprintf(os.Stderr, "cleanup: removed %d orphan(s)\n", len(removed))
+			if err != nil {
+				return err
+			}
+			pruned, err := reaper.PruneSnapshots(cmd.Context())
+			fmt.Fprintf(os.Stderr, "cleanup: removed %d expired snapshot(s)\n", pruned)
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		// Keys are synced here so auth-keys itself never needs the network
+		if cfg.Auth.KeySyncInterval != "" {
+			syncInterval, _ := time.ParseDuration(cfg.Auth.KeySyncInterval)
+			go newKeySyncer().Loop(ctx, syncInterval, func() (map[string][]string, error) {
+				return config.LoadKeySources(cfg.Auth.KeySourcesFile)
+			})
+		}
+
+		slog.Info("cleanup daemon started", "interval", interval)
+		reaper.Loop(ctx, interval)
+		slog.Info("cleanup daemon stopped")
+		return nil
 	},
 }
 
 func init() {
 	cleanupCmd.Flags().Bool("daemon", false, "Run as background cleanup daemon")
+	cleanupCmd.Flags().Bool("dry-run", false, "list orphaned containers, networks and agent directories without removing anything")
+	cleanupCmd.Flags().Duration("interval", 30*time.Second, "time between cleanup passes in daemon mode")
 	rootCmd.AddCommand(cleanupCmd)
 }
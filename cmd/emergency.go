@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+var emergencyCmd = &cobra.Command{
+	Use:   "emergency",
+	Short: "ForceCommand for break-glass keys, opens a shell on the host",
+	Long: `Forced command for keys in auth.emergency_keys. Opens the user's login
+shell on the host (or runs the requested command there) without going
+through Docker, so admins can get in to fix a broken server. Every use
+is logged as a warning and recorded in the audit log.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		user, _ := cmd.Flags().GetString("user")
+		command := os.Getenv("SSH_ORIGINAL_COMMAND")
+		from := "unknown"
+		if f := strings.Fields(os.Getenv("SSH_CONNECTION")); len(f) > 0 {
+			from = f[0]
+		}
+
+		slog.Warn("EMERGENCY ACCESS: break-glass key used, opening host shell",
+			"user", user, "from", from, "command", command)
+		newAuditLog(nil).Record(audit.Event{
+			Type:    audit.TypeEmergency,
+			User:    user,
+			Command: command,
+			Detail:  "host shell from " + from,
+		})
+		fmt.Fprintln(os.Stderr, "podspawn: EMERGENCY ACCESS to the host, this session is logged") //nolint:errcheck
+
+		argv := emergencyShell(os.Getenv("SHELL"), command)
+		if err := syscall.Exec(argv[0], argv, os.Environ()); err != nil {
+			fmt.Fprintf(os.Stderr, "podspawn: starting %s: %v\n", argv[0], err) //nolint:errcheck
+			os.Exit(1)
+		}
+	},
+}
+
+// emergencyShell is the argv for the host session: sshd sets SHELL to
+// the user's login shell, and a command is run the way sshd would.
+func emergencyShell(shell, command string) []string {
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	if command != "" {
+		return []string{shell, "-c", command}
+	}
+	return []string{shell, "-l"}
+}
+
+func init() {
+	emergencyCmd.Flags().String("user", "", "username of the admin (set by auth-keys)")
+	rootCmd.AddCommand(emergencyCmd)
+}
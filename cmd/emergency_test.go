@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEmergencyShell(t *testing.T) {
+	tests := []struct {
+		shell, command string
+		want           []string
+	}{
+		{"/bin/bash", "", []string{"/bin/bash", "-l"}},
+		{"/bin/zsh", "systemctl restart docker", []string{"/bin/zsh", "-c", "systemctl restart docker"}},
+		{"", "", []string{"/bin/sh", "-l"}},
+	}
+	for _, tt := range tests {
+		if got := emergencyShell(tt.shell, tt.command); !slices.Equal(got, tt.want) {
+			t.Errorf("emergencyShell(%q, %q) = %q, want %q", tt.shell, tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/adduser"
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/keyfile"
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage users' registered SSH keys",
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list <username>",
+	Short: "List a user's keys with fingerprints and expiry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := readUserKeys(args[0])
+		if err != nil {
+			return err
+		}
+		return writeKeyTable(os.Stdout, f.Keys(), time.Now())
+	},
+}
+
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove <username> <fingerprint>",
+	Short: "Remove a key by its SHA256 fingerprint",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username, fingerprint := args[0], args[1]
+		if _, err := readUserKeys(username); err != nil {
+			return err
+		}
+		n := 0
+		err := keyfile.Update(filepath.Join(cfg.Auth.KeyDir, username), func(f *keyfile.File) (bool, error) {
+			n = f.Remove(fingerprint)
+			return n > 0, nil
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%s has no key with fingerprint %s", username, fingerprint)
+		}
+		auditAdmin(audit.Event{Action: "keys remove", User: username, Fingerprint: fingerprint})
+		fmt.Fprintf(os.Stderr, "removed %d key(s) from %s\n", n, username)
+		return nil
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysListCmd, keysRemoveCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+func readUserKeys(username string) (*keyfile.File, error) {
+	if err := adduser.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+	f, err := keyfile.Read(filepath.Join(cfg.Auth.KeyDir, username))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no keys registered for %s", username)
+	}
+	return f, err
+}
+
+func writeKeyTable(w io.Writer, keys []*keyfile.Key, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FINGERPRINT\tTYPE\tCOMMENT\tSOURCE\tEXPIRES") //nolint:errcheck
+	for _, k := range keys {
+		comment := k.Comment
+		if comment == "" {
+			comment = "-"
+		}
+		source := k.Source
+		if source == "" {
+			source = "manual"
+		}
+		expires := "never"
+		if !k.Expires.IsZero() {
+			expires = k.Expires.Local().Format(time.DateTime)
+			if k.Expired(now) {
+				expires += " (expired)"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", k.Fingerprint(), k.Type, comment, source, expires) //nolint:errcheck
+	}
+	return tw.Flush()
+}
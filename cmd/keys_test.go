@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/keyfile"
+)
+
+func TestWriteKeyTable(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	forever, _ := keyfile.ParseKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDWizsqWrLPW+1E7N3GdnBjvwRBcVPCUhMIsDOPKm3Bl laptop")
+	old, _ := keyfile.ParseKey("expires=2026-01-01T00:00:00Z source=github:alice ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB")
+
+	var buf bytes.Buffer
+	if err := writeKeyTable(&buf, []*keyfile.Key{forever, old}, now); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header + 2 rows, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "SHA256:i+01W+UDKnVS5S6VIjOAEp31yFsR8Np0LIWIqC78fyc") || !strings.Contains(lines[1], "never") || !strings.Contains(lines[1], "manual") {
+		t.Errorf("row = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "(expired)") || !strings.Contains(lines[2], " - ") || !strings.Contains(lines[2], "github:alice") {
+		t.Errorf("row = %q, want expired key with no comment", lines[2])
+	}
+}
@@ -1,16 +1,173 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List active sessions",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		userFilter, _ := cmd.Flags().GetString("user")
+		projectFilter, _ := cmd.Flags().GetString("project")
+		if output != "table" && output != "json" {
+			return fmt.Errorf("invalid --output %q: must be table or json", output)
+		}
+
+		store, err := state.Open(cfg.State.DBPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = store.Close() }()
+
+		sessions, err := store.ListSessions()
+		if err != nil {
+			return fmt.Errorf("listing sessions: %w", err)
+		}
+
+		// Container state is informational; list still works when
+		// Docker is down, with every state reported as unknown.
+		var containers []runtime.ContainerInfo
+		if rt, err := runtime.NewDockerRuntime(); err != nil {
+			slog.Warn("docker unavailable, container state unknown", "error", err)
+		} else {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+			defer cancel()
+			containers, err = rt.ListContainers(ctx, map[string]string{"managed-by": "podspawn"})
+			if err != nil {
+				slog.Warn("listing containers failed, container state unknown", "error", err)
+				containers = nil
+			}
+		}
+
+		rows := buildSessionRows(sessions, containers, time.Now(), userFilter, projectFilter)
+		if output == "json" {
+			return writeSessionJSON(os.Stdout, rows)
+		}
+		return writeSessionTable(os.Stdout, rows, time.Now())
 	},
 }
 
 func init() {
+	listCmd.Flags().StringP("output", "o", "table", "output format: table or json")
+	listCmd.Flags().String("user", "", "only show sessions for this user")
+	listCmd.Flags().String("project", "", "only show sessions for this project")
 	rootCmd.AddCommand(listCmd)
 }
+
+type sessionRow struct {
+	User           string     `json:"user"`
+	Project        string     `json:"project"`
+	Container      string     `json:"container"`
+	Image          string     `json:"image"`
+	Status         string     `json:"status"`
+	ContainerState string     `json:"container_state"`
+	Connections    int        `json:"connections"`
+	GraceExpiry    *time.Time `json:"grace_expiry,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	MaxLifetime    time.Time  `json:"max_lifetime"`
+	AgeSeconds     int64      `json:"age_seconds"`
+	TTLSeconds     int64      `json:"ttl_seconds"` // time until max lifetime; negative once exceeded
+}
+
+// buildSessionRows joins session rows with runtime container state and
+// applies the user/project filters. Empty filters match everything.
+func buildSessionRows(sessions []*state.Session, containers []runtime.ContainerInfo, now time.Time, user, project string) []sessionRow {
+	containerState := make(map[string]string, len(containers))
+	for _, c := range containers {
+		containerState[c.Name] = c.State
+	}
+
+	rows := make([]sessionRow, 0, len(sessions))
+	for _, sess := range sessions {
+		if user != "" && sess.User != user {
+			continue
+		}
+		if project != "" && sess.Project != project {
+			continue
+		}
+
+		st := "unknown"
+		if containers != nil {
+			st = "missing"
+			if s, ok := containerState[sess.ContainerName]; ok {
+				st = s
+			}
+		}
+
+		row := sessionRow{
+			User:           sess.User,
+			Project:        sess.Project,
+			Container:      sess.ContainerName,
+			Image:          sess.Image,
+			Status:         sess.Status,
+			ContainerState: st,
+			Connections:    sess.Connections,
+			CreatedAt:      sess.CreatedAt,
+			MaxLifetime:    sess.MaxLifetime,
+			AgeSeconds:     int64(now.Sub(sess.CreatedAt).Seconds()),
+			TTLSeconds:     int64(sess.MaxLifetime.Sub(now).Seconds()),
+		}
+		if sess.GraceExpiry.Valid {
+			expiry := sess.GraceExpiry.Time
+			row.GraceExpiry = &expiry
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func writeSessionJSON(w io.Writer, rows []sessionRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func writeSessionTable(w io.Writer, rows []sessionRow, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tPROJECT\tCONTAINER\tIMAGE\tSTATUS\tSTATE\tCONNS\tGRACE\tAGE\tTTL") //nolint:errcheck
+	for _, r := range rows {
+		project := r.Project
+		if project == "" {
+			project = "-"
+		}
+		grace := "-"
+		if r.GraceExpiry != nil {
+			grace = formatDuration(r.GraceExpiry.Sub(now))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", //nolint:errcheck
+			r.User, project, r.Container, r.Image, r.Status, r.ContainerState,
+			r.Connections, grace,
+			formatDuration(now.Sub(r.CreatedAt)), formatDuration(r.MaxLifetime.Sub(now)))
+	}
+	return tw.Flush()
+}
+
+// formatDuration renders a duration compactly (1h05m, 4m10s, 30s).
+// Negative durations mean the deadline already passed.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "expired"
+	}
+	d = d.Truncate(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func listFixture(now time.Time) []*state.Session {
+	return []*state.Session{
+		{
+			User:          "alice",
+			ContainerName: "podspawn-alice",
+			Image:         "ubuntu:24.04",
+			Status:        "running",
+			Connections:   2,
+			CreatedAt:     now.Add(-90 * time.Minute),
+			MaxLifetime:   now.Add(6*time.Hour + 30*time.Minute),
+		},
+		{
+			User:          "bob",
+			Project:       "backend",
+			ContainerName: "podspawn-bob-backend",
+			Image:         "podspawn/backend:podfile-abc",
+			Status:        "grace_period",
+			GraceExpiry:   sql.NullTime{Time: now.Add(45 * time.Second), Valid: true},
+			CreatedAt:     now.Add(-5 * time.Minute),
+			MaxLifetime:   now.Add(-time.Minute),
+		},
+	}
+}
+
+func TestBuildSessionRowsJoinsContainerState(t *testing.T) {
+	now := time.Now()
+	containers := []runtime.ContainerInfo{{Name: "podspawn-alice", State: "running"}}
+
+	rows := buildSessionRows(listFixture(now), containers, now, "", "")
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].ContainerState != "running" {
+		t.Errorf("alice state = %q, want running", rows[0].ContainerState)
+	}
+	if rows[1].ContainerState != "missing" {
+		t.Errorf("bob state = %q, want missing", rows[1].ContainerState)
+	}
+	if rows[1].GraceExpiry == nil {
+		t.Error("bob should have a grace expiry")
+	}
+	if rows[1].TTLSeconds >= 0 {
+		t.Errorf("bob ttl = %d, want negative", rows[1].TTLSeconds)
+	}
+}
+
+func TestBuildSessionRowsUnknownWithoutRuntime(t *testing.T) {
+	now := time.Now()
+	rows := buildSessionRows(listFixture(now), nil, now, "", "")
+	for _, r := range rows {
+		if r.ContainerState != "unknown" {
+			t.Errorf("%s state = %q, want unknown", r.User, r.ContainerState)
+		}
+	}
+}
+
+func TestBuildSessionRowsFilters(t *testing.T) {
+	now := time.Now()
+	sessions := listFixture(now)
+
+	if rows := buildSessionRows(sessions, nil, now, "bob", ""); len(rows) != 1 || rows[0].User != "bob" {
+		t.Errorf("user filter: got %+v", rows)
+	}
+	if rows := buildSessionRows(sessions, nil, now, "", "backend"); len(rows) != 1 || rows[0].Project != "backend" {
+		t.Errorf("project filter: got %+v", rows)
+	}
+	if rows := buildSessionRows(sessions, nil, now, "alice", "backend"); len(rows) != 0 {
+		t.Errorf("combined filter: got %+v", rows)
+	}
+}
+
+func TestWriteSessionTable(t *testing.T) {
+	now := time.Now()
+	rows := buildSessionRows(listFixture(now), nil, now, "", "")
+
+	var buf bytes.Buffer
+	if err := writeSessionTable(&buf, rows, now); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header + 2 rows, got:\n%s", buf.String())
+	}
+	for _, want := range []string{"alice", "1h30m", "6h30m", "-"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("alice row missing %q: %s", want, lines[1])
+		}
+	}
+	for _, want := range []string{"backend", "grace_period", "45s", "expired"} {
+		if !strings.Contains(lines[2], want) {
+			t.Errorf("bob row missing %q: %s", want, lines[2])
+		}
+	}
+}
+
+func TestWriteSessionJSON(t *testing.T) {
+	now := time.Now()
+	rows := buildSessionRows(listFixture(now), nil, now, "", "")
+
+	var buf bytes.Buffer
+	if err := writeSessionJSON(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(decoded))
+	}
+	if _, ok := decoded[0]["grace_expiry"]; ok {
+		t.Error("grace_expiry should be omitted when unset")
+	}
+	if decoded[1]["connections"] != float64(0) || decoded[0]["connections"] != float64(2) {
+		t.Errorf("unexpected connections: %v / %v", decoded[0]["connections"], decoded[1]["connections"])
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "30s"},
+		{4*time.Minute + 10*time.Second, "4m10s"},
+		{time.Hour + 5*time.Minute, "1h05m"},
+		{-time.Second, "expired"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
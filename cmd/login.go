@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/certsign"
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/oidc"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in with your identity provider and get a short-lived SSH certificate",
+	Long: `Runs the OAuth device flow against the configured OIDC issuer, then asks
+the podspawn signing server for a certificate for your SSH key. The
+certificate is written next to the key (id_ed25519-cert.pub), where ssh
+picks it up automatically.
+
+Settings come from the login section of ~/.podspawn/config.yaml; flags
+override them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("cannot determine home directory: %w", err)
+		}
+		opts, err := loginOptions(cmd, home)
+		if err != nil {
+			return err
+		}
+		_, err = runLogin(cmd.Context(), http.DefaultClient, opts, os.Stderr)
+		return err
+	},
+}
+
+func init() {
+	loginCmd.Flags().String("issuer", "", "OIDC issuer URL")
+	loginCmd.Flags().String("client-id", "", "OAuth client ID registered for podspawn")
+	loginCmd.Flags().String("signer", "", "podspawn ca-server URL")
+	loginCmd.Flags().String("identity", "", "SSH private key to certify (default ~/.ssh/id_ed25519)")
+	rootCmd.AddCommand(loginCmd)
+}
+
+// loginOptions merges the client config's login section with flags.
+// A missing client config is fine as long as the flags cover it.
+func loginOptions(cmd *cobra.Command, home string) (config.LoginConfig, error) {
+	var opts config.LoginConfig
+	path := filepath.Join(home, ".podspawn", "config.yaml")
+	if _, err := os.Stat(path); err == nil {
+		clientCfg, err := config.LoadClient(path)
+		if err != nil {
+			return opts, err
+		}
+		opts = clientCfg.Login
+	}
+
+	for flag, field := range map[string]*string{
+		"issuer":    &opts.Issuer,
+		"client-id": &opts.ClientID,
+		"signer":    &opts.SignerURL,
+		"identity":  &opts.Identity,
+	} {
+		if v, _ := cmd.Flags().GetString(flag); v != "" {
+			*field = v
+		}
+	}
+	if opts.Identity == "" {
+		opts.Identity = filepath.Join(home, ".ssh", "id_ed25519")
+	}
+	if opts.Issuer == "" || opts.ClientID == "" || opts.SignerURL == "" {
+		return opts, fmt.Errorf("issuer, client ID and signer URL are required (set login: in ~/.podspawn/config.yaml or pass --issuer, --client-id, --signer)")
+	}
+	return opts, nil
+}
+
+// runLogin does the device flow and certificate request, writes the
+// certificate next to the identity, and returns its path.
+func runLogin(ctx context.Context, client *http.Client, opts config.LoginConfig, out io.Writer) (string, error) {
+	pubPath := opts.Identity + ".pub"
+	pubData, err := os.ReadFile(pubPath)
+	if err != nil {
+		return "", fmt.Errorf("reading public key (create one with ssh-keygen -t ed25519): %w", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(pubData)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", pubPath, err)
+	}
+
+	setupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	provider, err := oidc.Discover(setupCtx, client, opts.Issuer)
+	if err != nil {
+		return "", err
+	}
+	da, err := provider.StartDevice(setupCtx, client, opts.ClientID, []string{"profile", "email"})
+	if err != nil {
+		return "", err
+	}
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "Open %s\nand confirm the code %s\n", da.VerificationURIComplete, da.UserCode) //nolint:errcheck
+	} else {
+		fmt.Fprintf(out, "Open %s\nand enter the code %s\n", da.VerificationURI, da.UserCode) //nolint:errcheck
+	}
+
+	idToken, err := provider.PollToken(ctx, client, opts.ClientID, da)
+	if err != nil {
+		return "", fmt.Errorf("logging in: %w", err)
+	}
+	cert, err := certsign.RequestCert(ctx, client, opts.SignerURL, idToken, pub)
+	if err != nil {
+		return "", err
+	}
+	return writeCert(cert, opts.Identity, out)
+}
+
+func writeCert(cert *ssh.Certificate, identity string, out io.Writer) (string, error) {
+	certPath := identity + "-cert.pub"
+	if err := os.WriteFile(certPath, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		return "", fmt.Errorf("writing certificate: %w", err)
+	}
+	expires := time.Unix(int64(cert.ValidBefore), 0)
+	fmt.Fprintf(out, "  OK    certificate for %s, valid until %s\n", //nolint:errcheck
+		strings.Join(cert.ValidPrincipals, ","), expires.Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(out, "        written to %s\n", certPath) //nolint:errcheck
+	return certPath, nil
+}
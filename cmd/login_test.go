@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/authkeys"
+	"github.com/podspawn/podspawn/internal/certsign"
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/oidc"
+	"github.com/podspawn/podspawn/internal/oidc/oidctest"
+	"golang.org/x/crypto/ssh"
+)
+
+func ed25519Signer(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestLoginIssuesCertAcceptedByAuthKeys(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	iss.Claims = map[string]any{"preferred_username": "deploy"}
+
+	provider, err := oidc.Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := ed25519Signer(t)
+	signer := httptest.NewServer((&certsign.Signer{
+		CA:            ca,
+		Verifier:      &oidc.Verifier{Provider: provider, ClientID: "podspawn", Client: http.DefaultClient},
+		UsernameClaim: "preferred_username",
+		TTL:           4 * time.Hour,
+	}).Handler())
+	defer signer.Close()
+
+	identity := filepath.Join(t.TempDir(), "id_ed25519")
+	userKey := ed25519Signer(t).PublicKey()
+	if err := os.WriteFile(identity+".pub", ssh.MarshalAuthorizedKey(userKey), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	certPath, err := runLogin(context.Background(), http.DefaultClient, config.LoginConfig{
+		Issuer:    iss.URL,
+		ClientID:  "podspawn",
+		SignerURL: signer.URL,
+		Identity:  identity,
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certPath != identity+"-cert.pub" {
+		t.Errorf("cert path = %s", certPath)
+	}
+	if !strings.Contains(out.String(), "WDJB-MJHT") {
+		t.Errorf("user code should be shown, got:\n%s", out.String())
+	}
+
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := key.(*ssh.Certificate)
+
+	// What sshd would pass to auth-keys for this cert
+	var lines bytes.Buffer
+	n, err := authkeys.LookupCert("deploy", cert.Type(), base64.StdEncoding.EncodeToString(cert.Marshal()),
+		[]ssh.PublicKey{ca.PublicKey()}, "/usr/local/bin/podspawn", &lines)
+	if err != nil || n != 1 {
+		t.Errorf("auth-keys should accept the issued cert: n=%d err=%v", n, err)
+	}
+}
+
+func TestLoginMissingPublicKey(t *testing.T) {
+	_, err := runLogin(context.Background(), http.DefaultClient, config.LoginConfig{
+		Issuer:    "http://127.0.0.1:1",
+		ClientID:  "podspawn",
+		SignerURL: "http://127.0.0.1:1",
+		Identity:  filepath.Join(t.TempDir(), "id_ed25519"),
+	}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "ssh-keygen") {
+		t.Errorf("expected hint to create a key, got %v", err)
+	}
+}
+
+func TestLoginOptionsFromClientConfig(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".podspawn"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	clientYAML := "login:\n  issuer: https://id.example.com\n  client_id: podspawn\n  signer_url: https://ca.example.com\n"
+	if err := os.WriteFile(filepath.Join(home, ".podspawn", "config.yaml"), []byte(clientYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loginCmd.Flags().Set("client-id", "override") //nolint:errcheck
+	defer loginCmd.Flags().Set("client-id", "")   //nolint:errcheck
+
+	opts, err := loginOptions(loginCmd, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Issuer != "https://id.example.com" || opts.ClientID != "override" {
+		t.Errorf("opts = %+v, want issuer from config and client ID from flag", opts)
+	}
+	if opts.Identity != filepath.Join(home, ".ssh", "id_ed25519") {
+		t.Errorf("identity = %s, want the default key", opts.Identity)
+	}
+}
+
+func TestLoginOptionsRequireIssuer(t *testing.T) {
+	if _, err := loginOptions(loginCmd, t.TempDir()); err == nil {
+		t.Error("expected error without issuer, client ID and signer")
+	}
+}
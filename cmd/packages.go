@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/podspawn/podspawn/internal/podfile"
+	"github.com/spf13/cobra"
+)
+
+var packagesCmd = &cobra.Command{
+	Use:   "packages",
+	Short: "Inspect the recipes behind versioned Podfile packages",
+}
+
+var packagesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tools that can be installed as name@version",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := podfile.LoadRecipes(cfg.PackagesDir); err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tVERSIONS\tDISTROS\tSOURCE\tDESCRIPTION") //nolint:errcheck
+		for _, r := range podfile.Recipes() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", //nolint:errcheck
+				r.Name, strings.Join(r.VersionKeys(), ","), strings.Join(r.DistroNames(), ","), r.Source, r.Description)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	packagesCmd.AddCommand(packagesListCmd)
+	rootCmd.AddCommand(packagesCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/adduser"
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/cleanup"
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/keyfile"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var removeUserCmd = &cobra.Command{
+	Use:   "remove-user <username>",
+	Short: "Remove a user's keys, overrides and sessions",
+	Long: `Deletes the user's key file and per-user overrides so they can no longer
+log in with a registered key, then terminates all of their sessions,
+connected or not. With --purge their persistent volumes and hibernate
+snapshots are deleted too; without it they are kept in case the user
+comes back.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := args[0]
+		if err := adduser.ValidateUsername(username); err != nil {
+			return err
+		}
+		purge, _ := cmd.Flags().GetBool("purge")
+
+		failed := 0
+		report := func(kind, name string, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  FAIL  %s %s: %v\n", kind, name, err)
+				failed++
+				return
+			}
+			fmt.Fprintf(os.Stderr, "  OK    %s %s\n", kind, name)
+		}
+
+		// Files first, so no new session can start while we tear down.
+		// The key source entry goes before the key file: a sync that
+		// is running re-reads the registry under the key file lock, so
+		// it can't bring the file back.
+		if err := unregisterKeySources(username, report); err != nil {
+			return err
+		}
+		for _, f := range []struct {
+			kind, path string
+			remove     func(string) error
+		}{
+			{"key file", filepath.Join(cfg.Auth.KeyDir, username), keyfile.Delete},
+			{"overrides", config.UserOverridesPath("/etc/podspawn", username), os.Remove},
+		} {
+			err := f.remove(f.path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			report(f.kind, f.path, err)
+		}
+
+		rt, err := runtime.NewDockerRuntime()
+		if err != nil {
+			return err
+		}
+		store, err := state.Open(cfg.State.DBPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = store.Close() }()
+
+		reaper := &cleanup.Reaper{Runtime: rt, Store: store, LockDir: cfg.State.LockDir, AgentDir: cfg.State.AgentDir}
+		removals, err := reaper.RemoveUser(cmd.Context(), username, purge)
+		for _, r := range removals {
+			report(r.Kind, r.Name, r.Err)
+		}
+		if err != nil {
+			return err
+		}
+
+		auditAdmin(audit.Event{Action: "remove-user", User: username, Detail: fmt.Sprintf("purge=%t, %d item(s) failed", purge, failed)})
+		if failed > 0 {
+			return fmt.Errorf("%d item(s) could not be removed", failed)
+		}
+		fmt.Fprintf(os.Stderr, "\nremoved %s\n", username)
+		if !purge {
+			fmt.Fprintln(os.Stderr, "persistent volumes and snapshots were kept; use --purge to delete them")
+		}
+		return nil
+	},
+}
+
+func init() {
+	removeUserCmd.Flags().Bool("purge", false, "also delete the user's persistent volumes and hibernate snapshots")
+	rootCmd.AddCommand(removeUserCmd)
+}
+
+// unregisterKeySources drops username from the key source registry so
+// sync doesn't recreate the key file.
+func unregisterKeySources(username string, report func(kind, name string, err error)) error {
+	registry, err := config.LoadKeySources(cfg.Auth.KeySourcesFile)
+	if err != nil {
+		return err
+	}
+	sources, ok := registry[username]
+	if !ok {
+		return nil
+	}
+	delete(registry, username)
+	report("key sources", strings.Join(sources, ", "), config.SaveKeySources(cfg.Auth.KeySourcesFile, registry))
+	return nil
+}
@@ -21,7 +21,9 @@ var rootCmd = &cobra.Command{
 		configPath, _ := cmd.Flags().GetString("config")
 		loaded, err := config.Load(configPath)
 		if err != nil {
-			if cmd.Name() == "help" || cmd.Name() == "completion" || cmd.Name() == "connect" || cmd.Name() == "setup" || cmd.Name() == "server-setup" || cmd.Name() == "version" || !cmd.HasParent() {
+			// auth-keys and emergency must keep working with a broken
+			// config: that is when break-glass access is needed.
+			if cmd.Name() == "help" || cmd.Name() == "completion" || cmd.Name() == "connect" || cmd.Name() == "login" || cmd.Name() == "setup" || cmd.Name() == "server-setup" || cmd.Name() == "version" || cmd.Name() == "auth-keys" || cmd.Name() == "emergency" || !cmd.HasParent() {
 				slog.Warn("config load failed, using defaults", "path", configPath, "error", err)
 				loaded = config.Defaults()
 			} else {
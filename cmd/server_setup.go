@@ -26,6 +26,7 @@ var serverSetupCmd = &cobra.Command{
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
 		paths := serversetup.DefaultPaths(binaryPath)
+		paths.AuditLog = cfg.Audit.Path
 		if sshdConfig != "" {
 			paths.SSHDConfig = sshdConfig
 		}
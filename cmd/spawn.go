@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
@@ -39,6 +40,7 @@ var spawnCmd = &cobra.Command{
 		memory, _ := config.ParseMemory(cfg.Defaults.Memory)
 		gracePeriod, _ := time.ParseDuration(cfg.Session.GracePeriod)
 		maxLifetime, _ := time.ParseDuration(cfg.Session.MaxLifetime)
+		snapshotTTL, _ := time.ParseDuration(cfg.Session.SnapshotTTL)
 
 		store, err := state.Open(cfg.State.DBPath)
 		if err != nil {
@@ -56,21 +58,37 @@ var spawnCmd = &cobra.Command{
 			Shell:       cfg.Defaults.Shell,
 			CPUs:        cfg.Defaults.CPUs,
 			Memory:      memory,
+			OCIRuntime:  cfg.Defaults.Runtime,
+			Security:    cfg.Security,
+			DevUser:     cfg.Defaults.DevUser,
 			LockDir:     cfg.State.LockDir,
 			GracePeriod: gracePeriod,
 			MaxLifetime: maxLifetime,
 			Mode:        cfg.Session.Mode,
+			SnapshotTTL: snapshotTTL,
+			Persist:     cfg.Defaults.Persist,
+			Quota:       cfg.Quota,
+		}
+		if cfg.Session.AgentForwarding {
+			sess.AgentDir = cfg.State.AgentDir
 		}
 		if store != nil {
 			sess.Store = store
 		}
+		sess.Audit = newAuditLog(store)
 
 		if project != "" {
 			projects, loadErr := config.LoadProjects(cfg.ProjectsFile)
 			if loadErr != nil {
 				slog.Warn("failed to load projects", "error", loadErr)
 			} else if p, ok := projects[project]; ok {
+				if err := checkProjectAccess(user, project, p, cfg.GroupsFile); err != nil {
+					slog.Warn("project access denied", "user", user, "project", project)
+					fmt.Fprintf(os.Stderr, "podspawn: %v\n", err)
+					os.Exit(1)
+				}
 				sess.Project = &p
+				sess.Podfiles = podfileResolver(projects, p)
 			}
 		}
 
@@ -87,6 +105,24 @@ var spawnCmd = &cobra.Command{
 	},
 }
 
+// checkProjectAccess enforces the project's allowed_users and
+// allowed_groups. The groups file is only read for projects that use
+// groups; if it can't be read, group membership grants nothing.
+func checkProjectAccess(user, name string, p config.ProjectConfig, groupsFile string) error {
+	var groups map[string][]string
+	if len(p.AllowedGroups) > 0 {
+		loaded, err := config.LoadGroups(groupsFile)
+		if err != nil {
+			slog.Error("failed to load groups, denying group access", "error", err)
+		}
+		groups = loaded
+	}
+	if !p.Allows(user, groups) {
+		return fmt.Errorf("access denied: %s is not a member of project %s", user, name)
+	}
+	return nil
+}
+
 func init() {
 	spawnCmd.Flags().String("user", "", "username for the session")
 	spawnCmd.Flags().String("project", "", "project name for podfile-aware sessions")
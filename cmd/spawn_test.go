@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/config"
+)
+
+func TestCheckProjectAccess(t *testing.T) {
+	groupsFile := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(groupsFile, []byte("acme: [carol]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := config.ProjectConfig{AllowedUsers: []string{"alice"}, AllowedGroups: []string{"acme"}}
+
+	for _, user := range []string{"alice", "carol"} {
+		if err := checkProjectAccess(user, "acme-web", p, groupsFile); err != nil {
+			t.Errorf("%s: %v", user, err)
+		}
+	}
+	err := checkProjectAccess("bob", "acme-web", p, groupsFile)
+	if err == nil || !strings.Contains(err.Error(), "acme-web") {
+		t.Errorf("bob should be denied with the project named, got %v", err)
+	}
+}
+
+func TestCheckProjectAccessUnreadableGroups(t *testing.T) {
+	dir := t.TempDir() // a directory can't be read as the groups file
+	p := config.ProjectConfig{AllowedGroups: []string{"acme"}}
+	if err := checkProjectAccess("carol", "acme-web", p, dir); err == nil {
+		t.Error("group access should fail closed when the groups file is unreadable")
+	}
+}
@@ -2,19 +2,97 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/cleanup"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
 	"github.com/spf13/cobra"
 )
 
 var stopCmd = &cobra.Command{
-	Use:   "stop <session>",
+	Use:   "stop [<user>[/<project>]]",
 	Short: "Stop a session and destroy its container",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return fmt.Errorf("stop %q: not implemented", args[0])
+		all, _ := cmd.Flags().GetBool("all")
+		userFlag, _ := cmd.Flags().GetString("user")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		force, _ := cmd.Flags().GetBool("force")
+
+		selectors := 0
+		if len(args) == 1 {
+			selectors++
+		}
+		if all {
+			selectors++
+		}
+		if userFlag != "" {
+			selectors++
+		}
+		if selectors != 1 {
+			return fmt.Errorf("specify exactly one of <user>[/<project>], --user, or --all")
+		}
+
+		rt, err := runtime.NewDockerRuntime()
+		if err != nil {
+			return err
+		}
+		store, err := state.Open(cfg.State.DBPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = store.Close() }()
+
+		reaper := &cleanup.Reaper{Runtime: rt, Store: store, LockDir: cfg.State.LockDir, AgentDir: cfg.State.AgentDir}
+		opts := cleanup.StopOptions{Timeout: timeout, Force: force}
+
+		if len(args) == 1 {
+			user, project, _ := strings.Cut(args[0], "/")
+			if err := reaper.Stop(cmd.Context(), user, project, opts); err != nil {
+				return err
+			}
+			auditAdmin(audit.Event{Action: "stop", User: user, Project: project})
+			fmt.Fprintf(os.Stderr, "stopped %s\n", cleanup.SessionLabel(user, project))
+			return nil
+		}
+
+		sessions, err := store.ListSessions()
+		if err != nil {
+			return fmt.Errorf("listing sessions: %w", err)
+		}
+
+		stopped, failed := 0, 0
+		for _, sess := range sessions {
+			if userFlag != "" && sess.User != userFlag {
+				continue
+			}
+			label := cleanup.SessionLabel(sess.User, sess.Project)
+			if err := reaper.Stop(cmd.Context(), sess.User, sess.Project, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "  FAIL  %s: %v\n", label, err)
+				failed++
+				continue
+			}
+			auditAdmin(audit.Event{Action: "stop", User: sess.User, Project: sess.Project})
+			fmt.Fprintf(os.Stderr, "  OK    %s\n", label)
+			stopped++
+		}
+
+		fmt.Fprintf(os.Stderr, "\n%d stopped, %d failed\n", stopped, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d session(s) could not be stopped", failed)
+		}
+		return nil
 	},
 }
 
 func init() {
+	stopCmd.Flags().Bool("all", false, "stop every session")
+	stopCmd.Flags().String("user", "", "stop every session belonging to this user")
+	stopCmd.Flags().Duration("timeout", 10*time.Second, "time to wait for the container to exit before killing it")
+	stopCmd.Flags().Bool("force", false, "stop sessions even if clients are still connected")
 	rootCmd.AddCommand(stopCmd)
 }
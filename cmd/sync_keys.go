@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/keysync"
+	"github.com/spf13/cobra"
+)
+
+var syncKeysCmd = &cobra.Command{
+	Use:   "sync-keys [<username>]",
+	Short: "Refresh keys from users' registered GitHub, GitLab and URL sources",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources, err := config.LoadKeySources(cfg.Auth.KeySourcesFile)
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			userSources, ok := sources[args[0]]
+			if !ok {
+				return fmt.Errorf("%s has no registered key sources", args[0])
+			}
+			sources = map[string][]string{args[0]: userSources}
+		}
+
+		results := newKeySyncer().SyncAll(cmd.Context(), sources)
+		if failed := reportKeySync(results); failed > 0 {
+			return fmt.Errorf("%d source(s) could not be synced", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncKeysCmd)
+}
+
+func newKeySyncer() *keysync.Syncer {
+	return &keysync.Syncer{
+		Client: http.DefaultClient,
+		KeyDir: cfg.Auth.KeyDir,
+		Registry: func() (map[string][]string, error) {
+			return config.LoadKeySources(cfg.Auth.KeySourcesFile)
+		},
+	}
+}
+
+// reportKeySync prints one line per result and returns the failures.
+func reportKeySync(results []keysync.Result) int {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "  FAIL  %s %s: %v\n", r.User, r.Source, r.Err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  OK    %s %s (+%d -%d)\n", r.User, r.Source, r.Added, r.Removed)
+	}
+	return failed
+}
@@ -1,12 +1,16 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/podspawn/podspawn/internal/audit"
 	"github.com/podspawn/podspawn/internal/config"
 	"github.com/podspawn/podspawn/internal/podfile"
 	"github.com/podspawn/podspawn/internal/runtime"
@@ -36,44 +40,97 @@ var updateProjectCmd = &cobra.Command{
 			return err
 		}
 
-		raw, err := podfile.FindAndRead(proj.LocalPath)
-		if err != nil {
+		if err := podfile.LoadRecipes(cfg.PackagesDir); err != nil {
 			return err
 		}
-
-		newHash := podfile.ComputeTag(name, raw)
-		if newHash == proj.PodfileHash {
-			fmt.Fprintf(os.Stderr, "project %s: podfile unchanged, skipping rebuild\n", name)
-			return nil
-		}
-
-		pf, err := podfile.Parse(bytes.NewReader(raw))
+		rt, err := runtime.NewDockerRuntime()
 		if err != nil {
 			return err
 		}
 
-		rt, err := runtime.NewDockerRuntime()
+		rebuilt, err := rebuildProject(ctx, rt, projects, name)
 		if err != nil {
 			return err
 		}
+		if !rebuilt {
+			fmt.Fprintf(os.Stderr, "project %s: podfile unchanged, skipping rebuild\n", name)
+			return nil
+		}
 
-		tag, err := podfile.BuildImageFromPodfile(ctx, rt, pf, raw, name)
-		if err != nil {
-			return err
+		// Projects extending this one bake it into their images, so
+		// they are rebuilt too (from their current checkout).
+		var failed []string
+		for _, dep := range dependents(projects, name) {
+			depCtx, cancel := context.WithTimeout(cmd.Context(), 10*time.Minute)
+			_, err := rebuildProject(depCtx, rt, projects, dep)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "project %s extends %s but failed to rebuild: %v\n", dep, name, err)
+				failed = append(failed, dep)
+			}
 		}
 
-		proj.PodfileHash = newHash
-		proj.ImageTag = tag
-		projects[name] = proj
 		if err := config.SaveProjects(cfg.ProjectsFile, projects); err != nil {
 			return err
 		}
-
-		fmt.Fprintf(os.Stderr, "project %s rebuilt, image: %s\n", name, tag)
+		if len(failed) > 0 {
+			return fmt.Errorf("dependent project(s) not rebuilt: %s", strings.Join(failed, ", "))
+		}
 		return nil
 	},
 }
 
+// rebuildProject loads the project's Podfile and builds its image if
+// the tag changed, recording the new tag in projects. Reports whether
+// it rebuilt.
+func rebuildProject(ctx context.Context, rt runtime.Runtime, projects map[string]config.ProjectConfig, name string) (bool, error) {
+	proj := projects[name]
+	src, err := loadPodfile(proj.LocalPath, projects, proj)
+	if err != nil {
+		return false, err
+	}
+	printWarnings(src.Warnings)
+
+	newHash, err := src.Tag(name)
+	if err != nil {
+		return false, err
+	}
+	if newHash == proj.PodfileHash {
+		return false, nil
+	}
+	tag, err := podfile.BuildImageFromPodfile(ctx, rt, src, name)
+	if err != nil {
+		return false, err
+	}
+
+	proj.PodfileHash = newHash
+	proj.ImageTag = tag
+	projects[name] = proj
+	auditAdmin(audit.Event{Action: "update-project", Project: name, Detail: "image " + tag})
+	fmt.Fprintf(os.Stderr, "project %s rebuilt, image: %s\n", name, tag)
+	return true, nil
+}
+
+// dependents lists, in name order, the projects whose extends chain
+// goes through name. Projects whose Podfile can't be loaded are skipped.
+func dependents(projects map[string]config.ProjectConfig, name string) []string {
+	var out []string
+	for _, other := range slices.Sorted(maps.Keys(projects)) {
+		if other == name {
+			continue
+		}
+		src, err := podfile.Load(projects[other].LocalPath, podfileResolver(projects, projects[other]))
+		if err != nil {
+			slog.Warn("can't tell whether project extends the updated one", "project", other, "parent", name, "error", err)
+			continue
+		}
+		if slices.Contains(src.Parents, name) {
+			out = append(out, other)
+		}
+	}
+	return out
+}
+
 func init() {
 	rootCmd.AddCommand(updateProjectCmd)
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/podspawn/podspawn/internal/runtime"
@@ -39,12 +41,24 @@ var checks = []imageCheck{
 	},
 }
 
+// sudoCheck is added when the dev user gets sudo, which the session
+// setup refuses to configure without the binary.
+var sudoCheck = imageCheck{
+	name: "sudo",
+	cmd:  []string{"sudo", "--version"},
+	fix:  "apt-get install -y sudo, or set defaults.dev_user.sudo: false",
+}
+
 var verifyImageCmd = &cobra.Command{
 	Use:   "verify-image <image>",
 	Short: "Check if a container image is compatible with podspawn",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		imageRef := args[0]
+		ociRuntime, _ := cmd.Flags().GetString("runtime")
+		if !cmd.Flags().Changed("runtime") {
+			ociRuntime = cfg.Defaults.Runtime
+		}
 
 		rt, err := runtime.NewDockerRuntime()
 		if err != nil {
@@ -54,11 +68,21 @@ var verifyImageCmd = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
 		defer cancel()
 
+		if ociRuntime != "" {
+			if err := checkRuntimeRegistered(ctx, rt, ociRuntime); err != nil {
+				fmt.Fprintf(os.Stderr, "  FAIL  runtime %s\n", ociRuntime)
+				fmt.Fprintf(os.Stderr, "        fix: %s\n", err)
+				return fmt.Errorf("runtime %q is not available", ociRuntime)
+			}
+			fmt.Fprintf(os.Stderr, "  OK    runtime %s\n", ociRuntime)
+		}
+
 		containerName := "podspawn-verify-" + fmt.Sprintf("%d", time.Now().UnixNano()%100000)
 		_, err = rt.CreateContainer(ctx, runtime.ContainerOpts{
-			Name:  containerName,
-			Image: imageRef,
-			Cmd:   []string{"sleep", "30"},
+			Name:    containerName,
+			Image:   imageRef,
+			Cmd:     []string{"sleep", "30"},
+			Runtime: ociRuntime,
 		})
 		if err != nil {
 			return fmt.Errorf("creating container from %s: %w", imageRef, err)
@@ -70,8 +94,13 @@ var verifyImageCmd = &cobra.Command{
 			_ = rt.RemoveContainer(context.Background(), containerName)
 		}()
 
+		imageChecks := checks
+		if cfg.Defaults.DevUser.Enabled && cfg.Defaults.DevUser.Sudo {
+			imageChecks = append(slices.Clone(checks), sudoCheck)
+		}
+
 		passed, failed := 0, 0
-		for _, check := range checks {
+		for _, check := range imageChecks {
 			exitCode, execErr := rt.Exec(ctx, containerName, runtime.ExecOpts{
 				Cmd:    check.cmd,
 				Stdout: os.Stdout,
@@ -95,6 +124,21 @@ var verifyImageCmd = &cobra.Command{
 	},
 }
 
+// checkRuntimeRegistered returns an error describing how to fix things
+// when the daemon doesn't know the OCI runtime name.
+func checkRuntimeRegistered(ctx context.Context, rt runtime.Runtime, name string) error {
+	registered, err := rt.ListRuntimes(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(registered, name) {
+		return nil
+	}
+	return fmt.Errorf("register it under \"runtimes\" in /etc/docker/daemon.json and restart dockerd (registered: %s)",
+		strings.Join(registered, ", "))
+}
+
 func init() {
+	verifyImageCmd.Flags().String("runtime", "", "OCI runtime to verify with (default: defaults.runtime from config)")
 	rootCmd.AddCommand(verifyImageCmd)
 }
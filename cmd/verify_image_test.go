@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+func TestCheckRuntimeRegistered(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	fake.OCIRuntimes = []string{"io.containerd.runc.v2", "runc", "runsc"}
+
+	if err := checkRuntimeRegistered(context.Background(), fake, "runsc"); err != nil {
+		t.Errorf("runsc is registered, got %v", err)
+	}
+
+	err := checkRuntimeRegistered(context.Background(), fake, "kata-runtime")
+	if err == nil {
+		t.Fatal("expected error for unregistered runtime")
+	}
+	if !strings.Contains(err.Error(), "daemon.json") || !strings.Contains(err.Error(), "runsc") {
+		t.Errorf("error should explain the fix and list runtimes, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+var volumesCmd = &cobra.Command{
+	Use:   "volumes",
+	Short: "Manage persistent home and workspace volumes",
+}
+
+var volumesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List persistent volumes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userFilter, _ := cmd.Flags().GetString("user")
+		withSize, _ := cmd.Flags().GetBool("size")
+
+		vols, err := listPersistentVolumes(cmd.Context(), withSize)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tUSER\tPROJECT\tKIND\tSIZE\tCREATED") //nolint:errcheck
+		for _, v := range vols {
+			if userFilter != "" && v.Labels["podspawn-user"] != userFilter {
+				continue
+			}
+			project := v.Labels["podspawn-project"]
+			if project == "" {
+				project = "-"
+			}
+			created := "-"
+			if !v.CreatedAt.IsZero() {
+				created = v.CreatedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", //nolint:errcheck
+				v.Name, v.Labels["podspawn-user"], project, v.Labels["podspawn-volume"], formatBytes(v.Size), created)
+		}
+		return tw.Flush()
+	},
+}
+
+var volumesInspectCmd = &cobra.Command{
+	Use:   "inspect <name>",
+	Short: "Show details and disk usage of a persistent volume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vols, err := listPersistentVolumes(cmd.Context(), true)
+		if err != nil {
+			return err
+		}
+		for _, v := range vols {
+			if v.Name != args[0] {
+				continue
+			}
+			fmt.Printf("name:     %s\n", v.Name)
+			fmt.Printf("user:     %s\n", v.Labels["podspawn-user"])
+			fmt.Printf("project:  %s\n", v.Labels["podspawn-project"])
+			fmt.Printf("kind:     %s\n", v.Labels["podspawn-volume"])
+			fmt.Printf("size:     %s\n", formatBytes(v.Size))
+			if v.RefCount >= 0 {
+				fmt.Printf("in use:   %d container(s)\n", v.RefCount)
+			}
+			if !v.CreatedAt.IsZero() {
+				fmt.Printf("created:  %s\n", v.CreatedAt.Local().Format(time.DateTime))
+			}
+			return nil
+		}
+		return fmt.Errorf("no podspawn volume named %q", args[0])
+	},
+}
+
+var volumesRemoveCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "Delete persistent volumes (their data is lost)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := runtime.NewDockerRuntime()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), time.Minute)
+		defer cancel()
+
+		vols, err := rt.ListVolumes(ctx, persistentVolumeLabels, false)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(vols))
+		for _, v := range vols {
+			if v.Labels["podspawn-volume"] != "" {
+				known[v.Name] = true
+			}
+		}
+
+		failed := 0
+		for _, name := range args {
+			if !known[name] {
+				fmt.Fprintf(os.Stderr, "  FAIL  %s: not a podspawn volume\n", name)
+				failed++
+				continue
+			}
+			if err := rt.RemoveVolume(ctx, name); err != nil {
+				fmt.Fprintf(os.Stderr, "  FAIL  %s: %v\n", name, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "  OK    %s\n", name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d volume(s) could not be removed", failed)
+		}
+		return nil
+	},
+}
+
+var persistentVolumeLabels = map[string]string{"managed-by": "podspawn"}
+
+// listPersistentVolumes returns podspawn's home/workspace volumes, skipping
+// any other volumes that happen to carry the managed-by label.
+func listPersistentVolumes(ctx context.Context, withSize bool) ([]runtime.VolumeInfo, error) {
+	rt, err := runtime.NewDockerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	vols, err := rt.ListVolumes(ctx, persistentVolumeLabels, withSize)
+	if err != nil {
+		return nil, err
+	}
+	out := vols[:0]
+	for _, v := range vols {
+		if v.Labels["podspawn-volume"] != "" {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// formatBytes renders a byte count with binary units. Negative means
+// the size is unknown.
+func formatBytes(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	volumesListCmd.Flags().String("user", "", "only show volumes for this user")
+	volumesListCmd.Flags().Bool("size", false, "compute disk usage (slower)")
+	volumesCmd.AddCommand(volumesListCmd, volumesInspectCmd, volumesRemoveCmd)
+	rootCmd.AddCommand(volumesCmd)
+}
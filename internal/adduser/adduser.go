@@ -10,6 +10,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/keyfile"
 )
 
 var validKeyPrefixes = []string{
@@ -45,38 +48,49 @@ func ValidateSSHKey(key string) error {
 	return fmt.Errorf("unrecognized key type %q", fields[0])
 }
 
-// WriteKeys appends SSH public keys to the user's key file at
-// keyDir/username. Creates the directory and file if needed.
-// Duplicates are not checked; sshd handles them fine.
-func WriteKeys(keyDir, username string, keys []string) (int, error) {
+// WriteKeys adds SSH public keys to the user's key file at
+// keyDir/username, creating the directory and file if needed. Keys
+// already in the file are not duplicated; re-adding one updates its
+// expiry instead. A zero expires means the keys never expire. The file
+// is rewritten atomically and only if every key is valid. Returns the
+// number of keys that were new.
+func WriteKeys(keyDir, username string, keys []string, expires time.Time) (int, error) {
 	if err := ValidateUsername(username); err != nil {
 		return 0, err
 	}
 
-	if err := os.MkdirAll(keyDir, 0755); err != nil {
-		return 0, fmt.Errorf("creating key directory: %w", err)
-	}
-
-	keyFile := filepath.Join(keyDir, username)
-	f, err := os.OpenFile(keyFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return 0, fmt.Errorf("opening key file: %w", err)
-	}
-	defer f.Close() //nolint:errcheck // write errors caught below
-
-	n := 0
+	var parsed []*keyfile.Key
 	for _, key := range keys {
 		key = strings.TrimSpace(key)
 		if key == "" {
 			continue
 		}
 		if err := ValidateSSHKey(key); err != nil {
-			return n, fmt.Errorf("invalid key: %w", err)
+			return 0, fmt.Errorf("invalid key: %w", err)
 		}
-		if _, err := fmt.Fprintln(f, key); err != nil {
-			return n, fmt.Errorf("writing key: %w", err)
+		k, err := keyfile.ParseKey(key)
+		if err != nil {
+			return 0, fmt.Errorf("invalid key: %w", err)
 		}
-		n++
+		k.Expires = expires
+		parsed = append(parsed, k)
+	}
+
+	if err := os.MkdirAll(keyDir, 0755); err != nil {
+		return 0, fmt.Errorf("creating key directory: %w", err)
+	}
+
+	n := 0
+	err := keyfile.Update(filepath.Join(keyDir, username), func(f *keyfile.File) (bool, error) {
+		for _, k := range parsed {
+			if f.Add(k) {
+				n++
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("writing key file: %w", err)
 	}
 	return n, nil
 }
@@ -85,10 +99,12 @@ func WriteKeys(keyDir, username string, keys []string) (int, error) {
 // endpoint for the given user. Returns validated keys only.
 func FetchGitHubKeys(ctx context.Context, client *http.Client, githubUser string) ([]string, error) {
 	url := fmt.Sprintf("https://github.com/%s.keys", githubUser)
-	return fetchKeysFromURL(ctx, client, url)
+	return FetchKeys(ctx, client, url)
 }
 
-func fetchKeysFromURL(ctx context.Context, client *http.Client, url string) ([]string, error) {
+// FetchKeys downloads authorized_keys-style lines from url and returns
+// the valid keys; comments, blanks and unrecognized lines are dropped.
+func FetchKeys(ctx context.Context, client *http.Client, url string) ([]string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateUsername(t *testing.T) {
@@ -60,7 +61,7 @@ func TestWriteKeys(t *testing.T) {
 		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB deploy@laptop",
 	}
 
-	n, err := WriteKeys(dir, "deploy", keys)
+	n, err := WriteKeys(dir, "deploy", keys, time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -84,10 +85,10 @@ func TestWriteKeysAppends(t *testing.T) {
 	first := []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz first-key"}
 	second := []string{"ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB second-key"}
 
-	if _, err := WriteKeys(dir, "deploy", first); err != nil {
+	if _, err := WriteKeys(dir, "deploy", first, time.Time{}); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := WriteKeys(dir, "deploy", second); err != nil {
+	if _, err := WriteKeys(dir, "deploy", second, time.Time{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -105,7 +106,7 @@ func TestWriteKeysCreatesDirectory(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "nested", "keys")
 	keys := []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz test"}
 
-	n, err := WriteKeys(dir, "deploy", keys)
+	n, err := WriteKeys(dir, "deploy", keys, time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,7 +119,7 @@ func TestWriteKeysRejectsInvalid(t *testing.T) {
 	dir := t.TempDir()
 	keys := []string{"not-a-valid-key"}
 
-	_, err := WriteKeys(dir, "deploy", keys)
+	_, err := WriteKeys(dir, "deploy", keys, time.Time{})
 	if err == nil {
 		t.Fatal("expected error for invalid key")
 	}
@@ -128,7 +129,7 @@ func TestWriteKeysSkipsBlanks(t *testing.T) {
 	dir := t.TempDir()
 	keys := []string{"", "  ", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz test", ""}
 
-	n, err := WriteKeys(dir, "deploy", keys)
+	n, err := WriteKeys(dir, "deploy", keys, time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -137,6 +138,44 @@ func TestWriteKeysSkipsBlanks(t *testing.T) {
 	}
 }
 
+func TestWriteKeysDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz laptop"
+
+	if _, err := WriteKeys(dir, "deploy", []string{key}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	n, err := WriteKeys(dir, "deploy", []string{key}, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("re-adding a key should add nothing, got %d", n)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "deploy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "expires=2027-01-01T00:00:00Z " + key + "\n"
+	if string(data) != want {
+		t.Errorf("key file = %q, want %q", data, want)
+	}
+}
+
+func TestWriteKeysAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	keys := []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz good", "not-a-valid-key"}
+
+	if _, err := WriteKeys(dir, "deploy", keys, time.Time{}); err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deploy")); !os.IsNotExist(err) {
+		t.Error("no key file should be written when a key is invalid")
+	}
+}
+
 func TestFetchGitHubKeys(t *testing.T) {
 	body := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz gh-key-1\nssh-rsa AAAAB3NzaC1yc2EAAAADAQAB gh-key-2\n"
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -145,7 +184,7 @@ func TestFetchGitHubKeys(t *testing.T) {
 	defer srv.Close()
 
 	// Override the URL by using a client that redirects to our test server
-	keys, err := fetchKeysFromURL(context.Background(), srv.Client(), srv.URL)
+	keys, err := FetchKeys(context.Background(), srv.Client(), srv.URL)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -160,7 +199,7 @@ func TestFetchGitHubKeysNotFound(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	_, err := fetchKeysFromURL(context.Background(), srv.Client(), srv.URL)
+	_, err := FetchKeys(context.Background(), srv.Client(), srv.URL)
 	if err == nil {
 		t.Fatal("expected error for 404")
 	}
@@ -176,7 +215,7 @@ func TestFetchGitHubKeysMixedContent(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	keys, err := fetchKeysFromURL(context.Background(), srv.Client(), srv.URL)
+	keys, err := FetchKeys(context.Background(), srv.Client(), srv.URL)
 	if err != nil {
 		t.Fatal(err)
 	}
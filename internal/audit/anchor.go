@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The log file has to be writable by auth-keys and spawn, so anyone
+// who can write it can also rewrite the whole chain. The anchor closes
+// that gap: a root-only file holding the hash of the newest entry root
+// wrote. The chain makes that hash depend on every entry before it, so
+// a rewritten or truncated log no longer contains it.
+
+// writeAnchor replaces the anchor at path with hash. Callers hold the
+// log's flock, so anchors are written in chain order.
+func writeAnchor(path, hash string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing audit anchor: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.WriteString(hash + "\n"); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("writing audit anchor: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing audit anchor: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing audit anchor: %w", err)
+	}
+	return nil
+}
+
+// ReadAnchor returns the anchored hash, or "" if nothing has been
+// anchored yet.
+func ReadAnchor(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading audit anchor: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// CheckAnchor reports whether the anchored entry is still in the log,
+// given the hashes Verify returned for it.
+func CheckAnchor(logHashes []string, anchor string) error {
+	if anchor != "" && !slices.Contains(logHashes, anchor) {
+		return fmt.Errorf("the entry anchored by root is missing; the log was rewritten or truncated")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnchorCatchesRewrittenChain(t *testing.T) {
+	root := testLog(t)
+	root.Anchor = filepath.Join(t.TempDir(), "audit.anchor")
+	writeEvents(t, root, Event{Type: TypeAuth, User: "mallory", Result: "accepted"})
+	user := &Log{Path: root.Path, Now: root.Now}
+	writeEvents(t, user, Event{Type: TypeSessionCreate, User: "mallory"})
+
+	if info, err := os.Stat(root.Anchor); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("anchor = %v, %v, want a 0600 file", info, err)
+	}
+	anchor, err := ReadAnchor(root.Anchor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := verifyFile(t, root.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAnchor(hashes, anchor); err != nil {
+		t.Errorf("unprivileged appends after the anchor should verify, got %v", err)
+	}
+
+	// A writer rebuilds the log without the entry: the chain is intact,
+	// the anchor isn't
+	if err := os.Remove(root.Path); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, user, Event{Type: TypeSessionCreate, User: "mallory"})
+	if hashes, err = verifyFile(t, root.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAnchor(hashes, anchor); err == nil || !strings.Contains(err.Error(), "anchored") {
+		t.Errorf("err = %v, want the missing anchored entry reported", err)
+	}
+}
+
+func TestReadAnchorMissing(t *testing.T) {
+	anchor, err := ReadAnchor(filepath.Join(t.TempDir(), "audit.anchor"))
+	if err != nil || anchor != "" {
+		t.Errorf("ReadAnchor = %q, %v, want nothing anchored", anchor, err)
+	}
+	if err := CheckAnchor(nil, ""); err != nil {
+		t.Errorf("an empty anchor should pass, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package audit keeps an append-only, hash-chained log of
+// authentication, session and admin events as JSON lines. Every entry
+// carries the hash of the one before it, so editing or deleting an
+// entry breaks the chain from that point on. Entries written by root
+// are also anchored in a file only root can write, which catches a
+// chain rewritten from scratch.
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+// Event types.
+const (
+	TypeAuth              = "auth"
+	TypeSessionCreate     = "session.create"
+	TypeSessionReattach   = "session.reattach"
+	TypeSessionDisconnect = "session.disconnect"
+	TypeSessionGrace      = "session.grace"
+	TypeSessionDestroy    = "session.destroy"
+	TypeAdmin             = "admin"
+	TypeEmergency         = "emergency" // host shell opened with a break-glass key
+)
+
+// Event is one audit log entry. Prev and Hash are filled in by Append.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	User        string    `json:"user,omitempty"`
+	Project     string    `json:"project,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // key offered at auth
+	Result      string    `json:"result,omitempty"`
+	Command     string    `json:"command,omitempty"` // SSH_ORIGINAL_COMMAND; empty = interactive shell
+	Actor       string    `json:"actor,omitempty"`   // who ran an admin command
+	Action      string    `json:"action,omitempty"`  // admin command name, e.g. add-user
+	Detail      string    `json:"detail,omitempty"`
+	Prev        string    `json:"prev"`
+	Hash        string    `json:"hash"`
+}
+
+// Mirror keeps a second copy of every entry. The state database
+// implements it, so truncating the log file is caught by Verify too.
+type Mirror interface {
+	SaveAuditRecord(rec *state.AuditRecord) error
+}
+
+// Log appends events to the JSON lines file at Path. A nil Log or an
+// empty Path records nothing.
+type Log struct {
+	Path   string
+	Mirror Mirror // optional
+	Anchor string // optional; root-only file recording the newest entry's hash
+	Now    func() time.Time
+}
+
+// Record appends e, logging rather than returning a failure: a broken
+// audit log must not take logins or sessions down with it.
+func (l *Log) Record(e Event) {
+	if err := l.Append(e); err != nil {
+		slog.Warn("audit log write failed", "type", e.Type, "user", e.User, "error", err)
+	}
+}
+
+// Append stamps e, chains it to the last entry in the file and writes
+// it. Concurrent writers from other processes are serialized with an
+// flock on the file.
+func (l *Log) Append(e Event) error {
+	if l == nil || l.Path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("creating audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("locking audit log: %w", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint:errcheck
+
+	prev, err := lastHash(f)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	e.Time = now().UTC()
+	e.Prev = prev
+	if e.Hash, err = hashEvent(e); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding audit event: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+
+	if l.Mirror != nil {
+		rec := &state.AuditRecord{Hash: e.Hash, Time: e.Time, Type: e.Type, User: e.User, Data: string(line)}
+		if err := l.Mirror.SaveAuditRecord(rec); err != nil {
+			return fmt.Errorf("mirroring audit event: %w", err)
+		}
+	}
+	if l.Anchor != "" {
+		return writeAnchor(l.Anchor, e.Hash)
+	}
+	return nil
+}
+
+// hashEvent is the SHA-256 of e's JSON encoding with Hash empty. Prev
+// is part of it, which is what links each entry to the one before.
+func hashEvent(e Event) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("encoding audit event: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastHash returns the hash of the last entry in f, or "" if f is
+// empty. The file is read backwards so appends stay cheap as it grows.
+func lastHash(f *os.File) (string, error) {
+	line, err := lastLine(f)
+	if err != nil || line == nil {
+		return "", err
+	}
+	var e Event
+	if err := json.Unmarshal(line, &e); err != nil || e.Hash == "" {
+		return "", fmt.Errorf("last audit log entry is unreadable; run podspawn audit --verify")
+	}
+	return e.Hash, nil
+}
+
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	const chunk = 4096
+	var buf []byte
+	for off := info.Size(); off > 0; {
+		n := min(chunk, off)
+		off -= n
+		b := make([]byte, n)
+		if _, err := f.ReadAt(b, off); err != nil {
+			return nil, fmt.Errorf("reading audit log: %w", err)
+		}
+		buf = append(b, buf...)
+
+		trimmed := bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if off == 0 && len(trimmed) > 0 {
+			return trimmed, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+type fakeMirror struct {
+	mu      sync.Mutex
+	records []*state.AuditRecord
+}
+
+func (m *fakeMirror) SaveAuditRecord(rec *state.AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, rec)
+	return nil
+}
+
+func testLog(t *testing.T) *Log {
+	t.Helper()
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	return &Log{
+		Path: filepath.Join(t.TempDir(), "log", "audit.jsonl"),
+		Now: func() time.Time {
+			clock = clock.Add(time.Minute)
+			return clock
+		},
+	}
+}
+
+func writeEvents(t *testing.T, l *Log, events ...Event) {
+	t.Helper()
+	for _, e := range events {
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string) ([]string, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	return Verify(f)
+}
+
+func TestAppendChainsEntries(t *testing.T) {
+	l := testLog(t)
+	mirror := &fakeMirror{}
+	l.Mirror = mirror
+	writeEvents(t, l,
+		Event{Type: TypeAuth, User: "alice", Result: "accepted"},
+		Event{Type: TypeSessionCreate, User: "alice", Command: "git pull"},
+		Event{Type: TypeAdmin, Action: "add-user", User: "bob", Actor: "root"},
+	)
+
+	hashes, err := verifyFile(t, l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("got %d entries, want 3", len(hashes))
+	}
+
+	f, _ := os.Open(l.Path)
+	defer f.Close() //nolint:errcheck
+	events, _ := Read(f, Filter{})
+	if events[0].Prev != "" || events[1].Prev != events[0].Hash || events[2].Prev != events[1].Hash {
+		t.Error("each entry should point at the previous hash")
+	}
+	if !events[1].Time.Equal(time.Date(2026, 3, 1, 9, 2, 0, 0, time.UTC)) {
+		t.Errorf("time = %v", events[1].Time)
+	}
+
+	if len(mirror.records) != 3 || mirror.records[2].Hash != hashes[2] || mirror.records[1].Type != TypeSessionCreate {
+		t.Errorf("mirror = %+v", mirror.records)
+	}
+	if !strings.Contains(mirror.records[1].Data, `"command":"git pull"`) {
+		t.Errorf("mirror should keep the log line, got %s", mirror.records[1].Data)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	l := testLog(t)
+	writeEvents(t, l,
+		Event{Type: TypeAuth, User: "alice", Result: "accepted"},
+		Event{Type: TypeAuth, User: "mallory", Result: "unknown key"},
+		Event{Type: TypeSessionCreate, User: "alice"},
+	)
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	cases := map[string]struct {
+		content []byte
+		want    string
+	}{
+		"edited":  {bytes.Replace(data, []byte("unknown key"), []byte("accepted"), 1), "line 2: hash mismatch"},
+		"deleted": {append(append([]byte{}, lines[0]...), lines[2]...), "line 2: chain broken"},
+		"first":   {append(append([]byte{}, lines[1]...), lines[2]...), "line 1: chain broken"},
+	}
+	for name, tc := range cases {
+		if err := os.WriteFile(l.Path, tc.content, 0640); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifyFile(t, l.Path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestAppendAfterLongEntry(t *testing.T) {
+	l := testLog(t)
+	long := strings.Repeat("x", 10000) // spans several read chunks
+	writeEvents(t, l,
+		Event{Type: TypeSessionCreate, User: "alice", Command: long},
+		Event{Type: TypeSessionDisconnect, User: "alice"},
+	)
+	if _, err := verifyFile(t, l.Path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendRefusesCorruptTail(t *testing.T) {
+	l := testLog(t)
+	writeEvents(t, l, Event{Type: TypeAuth, User: "alice"})
+	f, _ := os.OpenFile(l.Path, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.WriteString(`{"type":"auth","us`)
+	_ = f.Close()
+
+	if err := l.Append(Event{Type: TypeAuth, User: "bob"}); err == nil {
+		t.Error("appending after a torn entry should fail rather than start a new chain")
+	}
+}
+
+func TestConcurrentAppends(t *testing.T) {
+	l := &Log{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Record(Event{Type: TypeAuth, User: "alice"})
+		}()
+	}
+	wg.Wait()
+
+	hashes, err := verifyFile(t, l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 20 {
+		t.Errorf("got %d entries, want 20", len(hashes))
+	}
+}
+
+func TestNilLogRecordsNothing(t *testing.T) {
+	var l *Log
+	if err := l.Append(Event{Type: TypeAuth}); err != nil {
+		t.Errorf("nil log should be a no-op, got %v", err)
+	}
+	l.Record(Event{Type: TypeAuth})
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLine bounds a single entry; SSH_ORIGINAL_COMMAND is the only field
+// that can get long.
+const maxLine = 1 << 20
+
+// Filter selects events. Zero fields match everything.
+type Filter struct {
+	User  string
+	Type  string // exact type, or a prefix like "session"
+	Since time.Time
+	Until time.Time
+}
+
+func (f Filter) match(e *Event) bool {
+	if f.User != "" && e.User != f.User {
+		return false
+	}
+	if f.Type != "" && e.Type != f.Type && !strings.HasPrefix(e.Type, f.Type+".") {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the events in r that match f, oldest first.
+func Read(r io.Reader, f Filter) ([]*Event, error) {
+	var events []*Event
+	err := scan(r, func(n int, e *Event) error {
+		if f.match(e) {
+			events = append(events, e)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Verify walks the chain in r and returns the hashes of its entries in
+// order. The error names the first entry that was altered, or that
+// follows a deleted one.
+func Verify(r io.Reader) ([]string, error) {
+	var hashes []string
+	prev := ""
+	err := scan(r, func(n int, e *Event) error {
+		if e.Prev != prev {
+			return fmt.Errorf("line %d: chain broken, entries before it were removed or altered", n)
+		}
+		sum, err := hashEvent(*e)
+		if err != nil {
+			return err
+		}
+		if sum != e.Hash {
+			return fmt.Errorf("line %d: hash mismatch, entry was modified", n)
+		}
+		prev = e.Hash
+		hashes = append(hashes, e.Hash)
+		return nil
+	})
+	return hashes, err
+}
+
+// CheckMirror reports mirrored entries missing from the log, which is
+// how a truncated log file shows up: the chain of what's left is
+// still intact.
+func CheckMirror(logHashes, mirrored []string) error {
+	have := make(map[string]bool, len(logHashes))
+	for _, h := range logHashes {
+		have[h] = true
+	}
+	missing := 0
+	for _, h := range mirrored {
+		if !have[h] {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d entries in the database mirror are missing from the log file", missing)
+	}
+	return nil
+}
+
+func scan(r io.Reader, fn func(n int, e *Event) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLine)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		if err := fn(n, &e); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadFilters(t *testing.T) {
+	l := testLog(t) // entries at 09:01, 09:02, ...
+	writeEvents(t, l,
+		Event{Type: TypeAuth, User: "alice"},
+		Event{Type: TypeSessionCreate, User: "alice"},
+		Event{Type: TypeSessionCreate, User: "bob"},
+		Event{Type: TypeSessionDestroy, User: "alice"},
+		Event{Type: TypeAdmin, User: "alice", Action: "add-user"},
+	)
+
+	at := func(min int) time.Time { return time.Date(2026, 3, 1, 9, min, 0, 0, time.UTC) }
+	cases := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"all", Filter{}, 5},
+		{"user", Filter{User: "alice"}, 4},
+		{"type prefix", Filter{Type: "session"}, 3},
+		{"exact type", Filter{Type: TypeSessionCreate, User: "alice"}, 1},
+		{"no partial prefix", Filter{Type: "sess"}, 0},
+		{"range", Filter{Since: at(2), Until: at(4)}, 2},
+	}
+	for _, tc := range cases {
+		f, err := os.Open(l.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := Read(f, tc.filter)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != tc.want {
+			t.Errorf("%s: got %d events, want %d", tc.name, len(events), tc.want)
+		}
+	}
+}
+
+func TestCheckMirror(t *testing.T) {
+	if err := CheckMirror([]string{"a", "b", "c"}, []string{"a", "c"}); err != nil {
+		t.Errorf("mirror subset of the log should pass: %v", err)
+	}
+	if err := CheckMirror([]string{"a"}, []string{"a", "b", "c"}); err == nil {
+		t.Error("entries missing from a truncated log should be reported")
+	}
+}
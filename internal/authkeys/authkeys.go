@@ -1,19 +1,28 @@
 package authkeys
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/keyfile"
 )
 
 const keyOptions = "restrict,pty,agent-forwarding,port-forwarding,X11-forwarding"
 
+// expiryTimeFormat is sshd's expiry-time option format, interpreted in
+// the system timezone.
+const expiryTimeFormat = "200601021504"
+
 // Lookup reads SSH public keys for username from keyDir and writes
 // authorized_keys lines to w. Each line gets a command= directive
-// that forces podspawn spawn. Returns the number of keys written.
+// that forces podspawn spawn. Keys past their expires= annotation are
+// skipped; the rest carry it as expiry-time= so sshd also stops
+// accepting them at that point. Returns the number of keys written.
 //
 // If the key file doesn't exist, writes nothing and returns 0 (not
 // an error). sshd interprets empty output as "no keys, fall through."
@@ -22,32 +31,35 @@ func Lookup(username, keyDir, binaryPath string, w io.Writer) (int, error) {
 		return 0, fmt.Errorf("invalid username %q", username)
 	}
 
-	keyFile := filepath.Join(keyDir, username)
-	f, err := os.Open(keyFile)
-	if os.IsNotExist(err) {
+	f, err := keyfile.Read(filepath.Join(keyDir, username))
+	if errors.Is(err, fs.ErrNotExist) {
 		return 0, nil
 	}
 	if err != nil {
 		return 0, fmt.Errorf("reading keys for %s: %w", username, err)
 	}
-	defer f.Close() //nolint:errcheck // read-only file
 
+	command := fmt.Sprintf("%s spawn --user %s", binaryPath, username)
+	return writeKeys(f, command, username, w)
+}
+
+// writeKeys writes the unexpired keys in f as authorized_keys lines
+// forced to run command.
+func writeKeys(f *keyfile.File, command, username string, w io.Writer) (int, error) {
+	now := time.Now()
 	n := 0
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+	for _, k := range f.Keys() {
+		if k.Expired(now) {
 			continue
 		}
-		directive := fmt.Sprintf("command=\"%s spawn --user %s\",%s",
-			binaryPath, username, keyOptions)
-		if _, err := fmt.Fprintf(w, "%s %s\n", directive, line); err != nil {
+		directive := fmt.Sprintf("command=\"%s\",%s", command, keyOptions)
+		if !k.Expires.IsZero() {
+			directive += fmt.Sprintf(",expiry-time=\"%s\"", k.Expires.Local().Format(expiryTimeFormat))
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", directive, k.AuthorizedKey()); err != nil {
 			return n, fmt.Errorf("writing key for %s: %w", username, err)
 		}
 		n++
 	}
-	if err := scanner.Err(); err != nil {
-		return n, fmt.Errorf("reading keys for %s: %w", username, err)
-	}
 	return n, nil
 }
@@ -129,3 +129,70 @@ func TestLookupBinaryPath(t *testing.T) {
 		t.Errorf("binary path not reflected in output: %s", buf.String())
 	}
 }
+
+func TestLookupSkipsExpiredKeys(t *testing.T) {
+	dir := t.TempDir()
+	content := "expires=2020-01-01T00:00:00Z ssh-ed25519 AAAA1 old\n" +
+		"expires=2999-01-01T00:00:00Z ssh-ed25519 AAAA2 current\n" +
+		"ssh-ed25519 AAAA3 forever\n"
+	writeKeyFile(t, dir, "deploy", content)
+
+	var buf bytes.Buffer
+	n, err := Lookup("deploy", dir, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 unexpired keys, got %d", n)
+	}
+
+	got := buf.String()
+	if strings.Contains(got, "AAAA1") {
+		t.Errorf("expired key should be skipped: %s", got)
+	}
+	if strings.Contains(got, "expires=") {
+		t.Errorf("podspawn annotations must not reach sshd: %s", got)
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if !strings.Contains(lines[0], `expiry-time="2999`) {
+		t.Errorf("expiring key should carry expiry-time: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "expiry-time") {
+		t.Errorf("key without expiry should have no expiry-time: %s", lines[1])
+	}
+}
+
+func TestLookupMalformedAnnotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyFile(t, dir, "deploy", "expires=someday ssh-ed25519 AAAA1 laptop\n")
+
+	var buf bytes.Buffer
+	n, err := Lookup("deploy", dir, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("a key with a malformed expiry must not be listed, got %q", buf.String())
+	}
+}
+
+func TestLookupBadLineAmongGoodOnes(t *testing.T) {
+	dir := t.TempDir()
+	content := "ssh-ed25519 AAAA1 laptop\n" +
+		"not a key\n" +
+		`no-pty,from="10.0.0.0/8" ssh-ed25519 AAAAopts ci` + "\n" +
+		"ssh-rsa AAAA2 desktop\n"
+	writeKeyFile(t, dir, "deploy", content)
+
+	var buf bytes.Buffer
+	n, err := Lookup("deploy", dir, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatalf("one bad line must not lock the user out: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected the 2 good keys, got %d: %s", n, buf.String())
+	}
+	if out := buf.String(); strings.Contains(out, "not a key") || strings.Contains(out, "AAAAopts") {
+		t.Errorf("unparseable lines must not be listed: %s", out)
+	}
+}
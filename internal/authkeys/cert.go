@@ -0,0 +1,108 @@
+package authkeys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// IsCertType reports whether an sshd %t key type names an OpenSSH
+// certificate (e.g. ssh-ed25519-cert-v01@openssh.com).
+func IsCertType(keyType string) bool {
+	return strings.HasSuffix(keyType, "-cert-v01@openssh.com")
+}
+
+// LoadCAKeys reads trusted user CA public keys from files in
+// authorized_keys format (one or more keys per file, options ignored).
+func LoadCAKeys(paths []string) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA key %s: %w", path, err)
+		}
+		for len(bytes.TrimSpace(data)) > 0 {
+			key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+			if err != nil {
+				return nil, fmt.Errorf("parsing CA key %s: %w", path, err)
+			}
+			keys = append(keys, key)
+			data = rest
+		}
+	}
+	return keys, nil
+}
+
+// LookupCert checks a certificate presented by the client (sshd's %t
+// and %k) and, if it is a user certificate signed by one of caKeys that
+// is valid now and names username as a principal, writes a
+// cert-authority line for the signing CA with the spawn forced command.
+// sshd still does the full verification itself; the check here keeps
+// certs for other users or CAs from matching anything.
+//
+// A certificate that doesn't qualify writes nothing and returns 0 with
+// the reason as the error, so callers can log it.
+func LookupCert(username, keyType, keyData string, caKeys []ssh.PublicKey, binaryPath string, w io.Writer) (int, error) {
+	if strings.Contains(username, "/") || strings.Contains(username, "..") {
+		return 0, fmt.Errorf("invalid username %q", username)
+	}
+	if !IsCertType(keyType) || len(caKeys) == 0 {
+		return 0, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(keyData)
+	if err != nil {
+		return 0, fmt.Errorf("decoding certificate: %w", err)
+	}
+	pub, err := ssh.ParsePublicKey(raw)
+	if err != nil {
+		return 0, fmt.Errorf("parsing certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return 0, fmt.Errorf("key of type %s is not a certificate", keyType)
+	}
+	if cert.CertType != ssh.UserCert {
+		return 0, fmt.Errorf("certificate %q is not a user certificate", cert.KeyId)
+	}
+	// CheckCert accepts a cert without principals for any user; sshd
+	// doesn't, and neither should we.
+	if len(cert.ValidPrincipals) == 0 {
+		return 0, fmt.Errorf("certificate %q has no principals", cert.KeyId)
+	}
+
+	signer := trustedCA(cert.SignatureKey, caKeys)
+	if signer == nil {
+		return 0, fmt.Errorf("certificate %q is signed by an untrusted CA %s", cert.KeyId, ssh.FingerprintSHA256(cert.SignatureKey))
+	}
+	// CheckCert verifies principal, validity window and signature, but
+	// not the authority; that's trustedCA above.
+	checker := &ssh.CertChecker{
+		// sshd enforces source-address itself for cert-authority keys
+		SupportedCriticalOptions: []string{"source-address"},
+	}
+	if err := checker.CheckCert(username, cert); err != nil {
+		return 0, fmt.Errorf("certificate %q: %w", cert.KeyId, err)
+	}
+
+	directive := fmt.Sprintf("cert-authority,principals=\"%s\",command=\"%s spawn --user %s\",%s",
+		username, binaryPath, username, keyOptions)
+	if _, err := fmt.Fprintf(w, "%s %s", directive, ssh.MarshalAuthorizedKey(signer)); err != nil {
+		return 0, fmt.Errorf("writing CA key for %s: %w", username, err)
+	}
+	return 1, nil
+}
+
+func trustedCA(key ssh.PublicKey, caKeys []ssh.PublicKey) ssh.PublicKey {
+	for _, ca := range caKeys {
+		if bytes.Equal(ca.Marshal(), key.Marshal()) {
+			return ca
+		}
+	}
+	return nil
+}
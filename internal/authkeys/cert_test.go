@@ -0,0 +1,145 @@
+package authkeys
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// issueCert signs a fresh user key with ca and returns sshd's %t and %k.
+func issueCert(t *testing.T, ca ssh.Signer, mutate func(*ssh.Certificate)) (string, string) {
+	t.Helper()
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             newSigner(t).PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "deploy@example.com",
+		ValidPrincipals: []string{"deploy"},
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+	}
+	if mutate != nil {
+		mutate(cert)
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return cert.Type(), base64.StdEncoding.EncodeToString(cert.Marshal())
+}
+
+func TestLookupCertTrustedCA(t *testing.T) {
+	ca := newSigner(t)
+	keyType, keyData := issueCert(t, ca, nil)
+
+	var buf bytes.Buffer
+	n, err := LookupCert("deploy", keyType, keyData, []ssh.PublicKey{newSigner(t).PublicKey(), ca.PublicKey()}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 line, got %d", n)
+	}
+
+	got := buf.String()
+	if !strings.HasPrefix(got, `cert-authority,principals="deploy",command="/usr/local/bin/podspawn spawn --user deploy",restrict`) {
+		t.Errorf("unexpected directive: %s", got)
+	}
+	if !strings.HasSuffix(got, string(ssh.MarshalAuthorizedKey(ca.PublicKey()))) {
+		t.Errorf("line should end with the signing CA key: %s", got)
+	}
+}
+
+func TestLookupCertRejected(t *testing.T) {
+	ca := newSigner(t)
+	hourAgo := uint64(time.Now().Add(-time.Hour).Unix())
+	tests := []struct {
+		name   string
+		signer ssh.Signer
+		mutate func(*ssh.Certificate)
+	}{
+		{"untrusted CA", newSigner(t), nil},
+		{"wrong principal", ca, func(c *ssh.Certificate) { c.ValidPrincipals = []string{"admin"} }},
+		{"no principals", ca, func(c *ssh.Certificate) { c.ValidPrincipals = nil }},
+		{"expired", ca, func(c *ssh.Certificate) { c.ValidAfter = hourAgo - 60; c.ValidBefore = hourAgo }},
+		{"host certificate", ca, func(c *ssh.Certificate) { c.CertType = ssh.HostCert }},
+		{"unknown critical option", ca, func(c *ssh.Certificate) {
+			c.CriticalOptions = map[string]string{"verify-required": ""}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyType, keyData := issueCert(t, tt.signer, tt.mutate)
+			var buf bytes.Buffer
+			n, err := LookupCert("deploy", keyType, keyData, []ssh.PublicKey{ca.PublicKey()}, "/usr/local/bin/podspawn", &buf)
+			if err == nil {
+				t.Error("expected rejection reason")
+			}
+			if n != 0 || buf.Len() != 0 {
+				t.Errorf("expected no output, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestLookupCertIgnoresPlainKeys(t *testing.T) {
+	key := newSigner(t).PublicKey()
+	keyData := base64.StdEncoding.EncodeToString(key.Marshal())
+
+	var buf bytes.Buffer
+	n, err := LookupCert("deploy", key.Type(), keyData, []ssh.PublicKey{newSigner(t).PublicKey()}, "/usr/local/bin/podspawn", &buf)
+	if err != nil || n != 0 {
+		t.Errorf("plain key should be ignored, got n=%d err=%v", n, err)
+	}
+}
+
+func TestLoadCAKeys(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newSigner(t).PublicKey(), newSigner(t).PublicKey()
+	content := "# prod CA\n" + string(ssh.MarshalAuthorizedKey(a)) + "\n" + string(ssh.MarshalAuthorizedKey(b))
+	path := filepath.Join(dir, "user_ca.pub")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadCAKeys([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 CA keys, got %d", len(keys))
+	}
+
+	if _, err := LoadCAKeys([]string{filepath.Join(dir, "missing.pub")}); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
+
+func TestIsCertType(t *testing.T) {
+	if !IsCertType("ssh-ed25519-cert-v01@openssh.com") {
+		t.Error("ed25519 cert type not recognized")
+	}
+	if IsCertType("ssh-ed25519") {
+		t.Error("plain key type treated as a certificate")
+	}
+}
@@ -0,0 +1,48 @@
+package authkeys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/keyfile"
+)
+
+// LookupEmergency writes the break-glass keys from path that are bound
+// to username with a user= annotation. Their forced command is podspawn
+// emergency, which opens a shell on the host instead of a container, so
+// an admin can still get in when Docker or podspawn itself is broken.
+// Key files use the same format as the per-user ones (expires= works);
+// keys without user= are ignored, so one admin's key can never open a
+// shell as another. A non-empty admins further limits who may use the
+// file at all.
+//
+// A missing or empty file, or a user not in admins, writes nothing and
+// returns 0.
+func LookupEmergency(username, path string, admins []string, binaryPath string, w io.Writer) (int, error) {
+	if strings.Contains(username, "/") || strings.Contains(username, "..") {
+		return 0, fmt.Errorf("invalid username %q", username)
+	}
+	if path == "" || len(admins) > 0 && !slices.Contains(admins, username) {
+		return 0, nil
+	}
+
+	f, err := keyfile.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading emergency keys: %w", err)
+	}
+	bound := &keyfile.File{}
+	for _, k := range f.Keys() {
+		if k.User == username {
+			bound.Add(k)
+		}
+	}
+	command := fmt.Sprintf("%s emergency --user %s", binaryPath, username)
+	return writeKeys(bound, command, username, w)
+}
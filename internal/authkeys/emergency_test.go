@@ -0,0 +1,101 @@
+package authkeys
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEmergencyKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "emergency.keys")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookupEmergencyAdmin(t *testing.T) {
+	path := writeEmergencyKeys(t, "user=ops ssh-ed25519 AAAAbreakglass ops@vault\n")
+
+	var buf bytes.Buffer
+	n, err := LookupEmergency("ops", path, []string{"ops"}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 key, got %d", n)
+	}
+	got := buf.String()
+	if !strings.Contains(got, `command="/usr/local/bin/podspawn emergency --user ops"`) {
+		t.Errorf("missing emergency command directive in: %s", got)
+	}
+	if !strings.Contains(got, "ssh-ed25519 AAAAbreakglass") {
+		t.Errorf("missing key data in: %s", got)
+	}
+}
+
+func TestLookupEmergencyNotAdmin(t *testing.T) {
+	path := writeEmergencyKeys(t, "user=ops ssh-ed25519 AAAAbreakglass ops@vault\n")
+
+	var buf bytes.Buffer
+	n, err := LookupEmergency("alice", path, []string{"ops"}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("non-admin got %d emergency keys: %q", n, buf.String())
+	}
+
+	n, _ = LookupEmergency("ops", path, []string{"root-admin"}, "/usr/local/bin/podspawn", &buf)
+	if n != 0 {
+		t.Errorf("got %d keys for a user not in emergency_users, want 0", n)
+	}
+}
+
+func TestLookupEmergencyBoundToUser(t *testing.T) {
+	path := writeEmergencyKeys(t, "user=ops ssh-ed25519 AAAAops ops\nuser=sre ssh-ed25519 AAAAsre sre\nssh-ed25519 AAAAunbound anyone\n")
+
+	var buf bytes.Buffer
+	n, err := LookupEmergency("sre", path, []string{"ops", "sre"}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || !strings.Contains(buf.String(), "AAAAsre") {
+		t.Errorf("want only sre's key, got %d: %s", n, buf.String())
+	}
+
+	// Without emergency_users (e.g. the config failed to load) the
+	// user= binding alone decides.
+	buf.Reset()
+	n, _ = LookupEmergency("ops", path, nil, "/usr/local/bin/podspawn", &buf)
+	if n != 1 || !strings.Contains(buf.String(), "AAAAops") {
+		t.Errorf("want only ops's key, got %d: %s", n, buf.String())
+	}
+}
+
+func TestLookupEmergencyMissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := LookupEmergency("ops", filepath.Join(t.TempDir(), "nope"), []string{"ops"}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatalf("missing file should not be an error, got: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 keys, got %d", n)
+	}
+}
+
+func TestLookupEmergencySkipsExpired(t *testing.T) {
+	path := writeEmergencyKeys(t, "expires=2020-01-01T00:00:00Z user=ops ssh-ed25519 AAAAold old\nuser=ops ssh-ed25519 AAAAnew new\n")
+
+	var buf bytes.Buffer
+	n, err := LookupEmergency("ops", path, []string{"ops"}, "/usr/local/bin/podspawn", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || strings.Contains(buf.String(), "AAAAold") {
+		t.Errorf("expired key listed (n=%d): %s", n, buf.String())
+	}
+}
@@ -0,0 +1,134 @@
+// Package certsign issues short-lived SSH user certificates to holders
+// of a valid OIDC ID token. The server side runs as `podspawn ca-server`;
+// `podspawn login` is the client.
+package certsign
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/adduser"
+	"github.com/podspawn/podspawn/internal/oidc"
+	"golang.org/x/crypto/ssh"
+)
+
+// backdate covers clients whose clock runs a little behind the server's.
+const backdate = 5 * time.Minute
+
+// TokenVerifier checks an ID token and returns its claims.
+// *oidc.Verifier implements it.
+type TokenVerifier interface {
+	Verify(ctx context.Context, raw string) (oidc.Claims, error)
+}
+
+// Signer maps a verified ID token to a podspawn username and signs the
+// caller's public key for that username.
+type Signer struct {
+	CA            ssh.Signer
+	Verifier      TokenVerifier
+	UsernameClaim string // e.g. preferred_username or email
+	// AllowedDomains are the only domains an email-style claim value may
+	// have; without them such values are refused.
+	AllowedDomains []string
+	TTL            time.Duration // certificate lifetime
+	Now            func() time.Time
+}
+
+// LoadCAKey reads an unencrypted OpenSSH private key.
+func LoadCAKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// Username extracts the podspawn username from the configured claim.
+// Email-style values map to their local part, and only for a domain in
+// AllowedDomains; the email claim must also be marked verified.
+func (s *Signer) Username(claims oidc.Claims) (string, error) {
+	value := claims.String(s.UsernameClaim)
+	if value == "" {
+		return "", fmt.Errorf("token has no %s claim", s.UsernameClaim)
+	}
+	if s.UsernameClaim == "email" && !emailVerified(claims) {
+		return "", fmt.Errorf("email %s is not verified", value)
+	}
+	username, domain, isEmail := strings.Cut(value, "@")
+	if isEmail && !slices.ContainsFunc(s.AllowedDomains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+		return "", fmt.Errorf("claim %s: domain %q is not in allowed_domains", s.UsernameClaim, domain)
+	}
+	if err := adduser.ValidateUsername(username); err != nil {
+		return "", fmt.Errorf("claim %s: %w", s.UsernameClaim, err)
+	}
+	return username, nil
+}
+
+// emailVerified reports whether the issuer vouches for the email
+// claim. Some issuers send the flag as a string.
+func emailVerified(claims oidc.Claims) bool {
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// Sign verifies idToken and returns a user certificate for pub with the
+// mapped username as its only principal.
+func (s *Signer) Sign(ctx context.Context, idToken string, pub ssh.PublicKey) (*ssh.Certificate, error) {
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf("refusing to sign a certificate")
+	}
+	claims, err := s.Verifier.Verify(ctx, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("verifying ID token: %w", err)
+	}
+	username, err := s.Username(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("generating serial: %w", err)
+	}
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           fmt.Sprintf("%s (oidc sub %s)", username, claims.String("sub")),
+		ValidPrincipals: []string{username},
+		ValidAfter:      uint64(now.Add(-backdate).Unix()),
+		ValidBefore:     uint64(now.Add(s.TTL).Unix()),
+		Permissions: ssh.Permissions{
+			// auth-keys restricts the session further with its key options
+			Extensions: map[string]string{
+				"permit-pty":              "",
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-X11-forwarding":   "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, s.CA); err != nil {
+		return nil, fmt.Errorf("signing certificate: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,146 @@
+package certsign
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/oidc"
+	"github.com/podspawn/podspawn/internal/oidc/oidctest"
+	"golang.org/x/crypto/ssh"
+)
+
+func newKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newSigner returns a Signer that trusts tokens from a fresh stand-in issuer.
+func newSigner(t *testing.T) (*Signer, *oidctest.Issuer) {
+	t.Helper()
+	iss := oidctest.NewIssuer("podspawn")
+	t.Cleanup(iss.Close)
+	provider, err := oidc.Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{
+		CA:            newKey(t),
+		Verifier:      &oidc.Verifier{Provider: provider, ClientID: "podspawn", Client: http.DefaultClient},
+		UsernameClaim: "preferred_username",
+		TTL:           4 * time.Hour,
+	}, iss
+}
+
+func TestSignIssuesUserCert(t *testing.T) {
+	s, iss := newSigner(t)
+	userKey := newKey(t).PublicKey()
+
+	cert, err := s.Sign(context.Background(), iss.IDToken(map[string]any{"preferred_username": "deploy"}), userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.CertType != ssh.UserCert {
+		t.Errorf("cert type = %d, want user", cert.CertType)
+	}
+	if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "deploy" {
+		t.Errorf("principals = %v, want [deploy]", cert.ValidPrincipals)
+	}
+	lifetime := time.Until(time.Unix(int64(cert.ValidBefore), 0))
+	if lifetime > 4*time.Hour || lifetime < 4*time.Hour-time.Minute {
+		t.Errorf("cert valid for %s, want about 4h", lifetime)
+	}
+	if !strings.Contains(cert.KeyId, "user-1") {
+		t.Errorf("key id %q should name the token subject", cert.KeyId)
+	}
+
+	checker := ssh.CertChecker{}
+	if err := checker.CheckCert("deploy", cert); err != nil {
+		t.Errorf("issued cert does not check out: %v", err)
+	}
+}
+
+func TestUsernameRequiresAllowedDomains(t *testing.T) {
+	s := &Signer{UsernameClaim: "email"}
+	_, err := s.Username(oidc.Claims{"email": "alice@example.com", "email_verified": true})
+	if err == nil || !strings.Contains(err.Error(), "allowed_domains") {
+		t.Errorf("email without allowed_domains: error = %v", err)
+	}
+}
+
+func TestSignRejectsBadToken(t *testing.T) {
+	s, iss := newSigner(t)
+	expired := iss.IDToken(map[string]any{
+		"preferred_username": "deploy",
+		"exp":                time.Now().Add(-time.Hour).Unix(),
+	})
+	if _, err := s.Sign(context.Background(), expired, newKey(t).PublicKey()); err == nil {
+		t.Error("expected expired token to be refused")
+	}
+}
+
+type stubVerifier struct {
+	claims oidc.Claims
+	err    error
+}
+
+func (v stubVerifier) Verify(context.Context, string) (oidc.Claims, error) {
+	return v.claims, v.err
+}
+
+func TestSignRefusesCertificates(t *testing.T) {
+	s := &Signer{CA: newKey(t), Verifier: stubVerifier{claims: oidc.Claims{"preferred_username": "deploy"}}, UsernameClaim: "preferred_username", TTL: time.Hour}
+	cert, err := s.Sign(context.Background(), "token", newKey(t).PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sign(context.Background(), "token", cert); err == nil {
+		t.Error("signing a certificate should be refused")
+	}
+
+	s.Verifier = stubVerifier{err: errors.New("bad token")}
+	if _, err := s.Sign(context.Background(), "token", newKey(t).PublicKey()); err == nil {
+		t.Error("verifier error should be returned")
+	}
+}
+
+func TestUsernameMapping(t *testing.T) {
+	tests := []struct {
+		claim   string
+		claims  oidc.Claims
+		want    string
+		wantErr bool
+	}{
+		{"preferred_username", oidc.Claims{"preferred_username": "deploy"}, "deploy", false},
+		{"email", oidc.Claims{"email": "alice@example.com", "email_verified": true}, "alice", false},
+		{"email", oidc.Claims{"email": "alice@Example.COM", "email_verified": "true"}, "alice", false},
+		{"email", oidc.Claims{"email": "alice@example.com"}, "", true},
+		{"email", oidc.Claims{"email": "alice@example.com", "email_verified": false}, "", true},
+		{"email", oidc.Claims{"email": "alice@attacker.example", "email_verified": true}, "", true},
+		{"email", oidc.Claims{"email": "alice@sub.example.com", "email_verified": true}, "", true},
+		{"preferred_username", oidc.Claims{"preferred_username": "alice@attacker.example"}, "", true},
+		{"preferred_username", oidc.Claims{"preferred_username": "alice@example.com"}, "alice", false},
+		{"preferred_username", oidc.Claims{"email": "alice@example.com"}, "", true},
+		{"preferred_username", oidc.Claims{"preferred_username": "Root/../x"}, "", true},
+	}
+	for _, tt := range tests {
+		s := &Signer{UsernameClaim: tt.claim, AllowedDomains: []string{"example.com"}}
+		got, err := s.Username(tt.claims)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Username(%v) via %s = %q, %v; want %q", tt.claims, tt.claim, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package certsign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SignPath is the endpoint clients POST to.
+const SignPath = "/v1/sign"
+
+type signRequest struct {
+	PublicKey string `json:"public_key"` // authorized_keys format
+}
+
+type signResponse struct {
+	Certificate string `json:"certificate,omitempty"` // authorized_keys format
+	Error       string `json:"error,omitempty"`
+}
+
+// Handler serves SignPath. The ID token comes in the Authorization
+// header as a bearer token, the public key in the JSON body.
+func (s *Signer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+SignPath, s.serveSign)
+	return mux
+}
+
+func (s *Signer) serveSign(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		writeResponse(w, http.StatusUnauthorized, signResponse{Error: "missing bearer token"})
+		return
+	}
+	var req signRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeResponse(w, http.StatusBadRequest, signResponse{Error: "invalid request body"})
+		return
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, signResponse{Error: "invalid public key"})
+		return
+	}
+
+	cert, err := s.Sign(r.Context(), token, pub)
+	if err != nil {
+		slog.Warn("certificate request refused", "remote", r.RemoteAddr, "error", err)
+		writeResponse(w, http.StatusForbidden, signResponse{Error: err.Error()})
+		return
+	}
+	slog.Info("issued certificate", "key_id", cert.KeyId, "serial", cert.Serial,
+		"principals", cert.ValidPrincipals, "remote", r.RemoteAddr)
+	writeResponse(w, http.StatusOK, signResponse{
+		Certificate: string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(cert))),
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp signResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// RequestCert asks the signing server at baseURL to certify pub,
+// authenticating with idToken.
+func RequestCert(ctx context.Context, client *http.Client, baseURL, idToken string, pub ssh.PublicKey) (*ssh.Certificate, error) {
+	body, err := json.Marshal(signRequest{PublicKey: string(ssh.MarshalAuthorizedKey(pub))})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+SignPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+idToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting certificate: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var sr signResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("requesting certificate: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("certificate refused: %s", sr.Error)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(sr.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("signing server returned a plain key, not a certificate")
+	}
+	return cert, nil
+}
@@ -0,0 +1,70 @@
+package certsign
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestCertRoundTrip(t *testing.T) {
+	s, iss := newSigner(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	userKey := newKey(t).PublicKey()
+	cert, err := RequestCert(context.Background(), http.DefaultClient, srv.URL, iss.IDToken(map[string]any{"preferred_username": "deploy"}), userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert.Key.Marshal(), userKey.Marshal()) {
+		t.Error("certificate is for a different key")
+	}
+	if !bytes.Equal(cert.SignatureKey.Marshal(), s.CA.PublicKey().Marshal()) {
+		t.Error("certificate is not signed by the CA")
+	}
+}
+
+func TestRequestCertRefused(t *testing.T) {
+	s, iss := newSigner(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	_, err := RequestCert(context.Background(), http.DefaultClient, srv.URL, iss.IDToken(map[string]any{"aud": "grafana", "preferred_username": "deploy"}), newKey(t).PublicKey())
+	if err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Errorf("expected audience refusal, got %v", err)
+	}
+}
+
+func TestHandlerRejectsBadRequests(t *testing.T) {
+	s, _ := newSigner(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		auth   string
+		body   string
+		status int
+	}{
+		{"no token", "", `{"public_key":"x"}`, http.StatusUnauthorized},
+		{"bad body", "Bearer t", `not json`, http.StatusBadRequest},
+		{"bad key", "Bearer t", `{"public_key":"ssh-ed25519 !!!"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+SignPath, strings.NewReader(tt.body))
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/lock"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/spawn"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+// Reaper enforces session TTLs for all users. spawn only reconciles the
+// user who is connecting, so sessions of users who never come back are
+// reaped here instead.
+type Reaper struct {
+	Runtime runtime.Runtime
+	Store   state.SessionStore
+	LockDir string
+
+	// IdleTimeout destroys running sessions with no I/O for this long.
+	// Zero disables idle reaping.
+	IdleTimeout time.Duration
+
+	// Hibernate snapshots sessions whose grace period expired before
+	// destroying them, keeping each snapshot for SnapshotTTL.
+	Hibernate   bool
+	SnapshotTTL time.Duration
+
+	// OrphanMinAge is how old a labeled container or network must be
+	// before SweepOrphans will treat it as an orphan.
+	OrphanMinAge time.Duration
+
+	// AgentDir holds the per-session agent relay directories, removed
+	// along with their sessions. Empty = none to remove.
+	AgentDir string
+
+	Audit *audit.Log // nil = reaped sessions aren't audited
+}
+
+// RunOnce destroys every session whose grace period or max lifetime has
+// expired, or that has been idle past IdleTimeout. Returns the number of
+// sessions reaped.
+func (r *Reaper) RunOnce(ctx context.Context) (int, error) {
+	reaped := 0
+
+	graceExpired, err := r.Store.ExpiredGracePeriods()
+	if err != nil {
+		return reaped, fmt.Errorf("listing expired grace periods: %w", err)
+	}
+	for _, candidate := range graceExpired {
+		if r.reap(ctx, candidate, "grace period expired") {
+			reaped++
+		}
+	}
+
+	lifetimeExpired, err := r.Store.ExpiredLifetimes()
+	if err != nil {
+		return reaped, fmt.Errorf("listing expired lifetimes: %w", err)
+	}
+	for _, candidate := range lifetimeExpired {
+		if r.reap(ctx, candidate, "max lifetime reached") {
+			reaped++
+		}
+	}
+
+	if r.IdleTimeout > 0 {
+		idle, err := r.Store.IdleSessions(time.Now().Add(-r.IdleTimeout))
+		if err != nil {
+			return reaped, fmt.Errorf("listing idle sessions: %w", err)
+		}
+		for _, candidate := range idle {
+			if r.reap(ctx, candidate, "idle timeout") {
+				reaped++
+			}
+		}
+	}
+
+	return reaped, nil
+}
+
+// Loop runs RunOnce, SweepOrphans and PruneSnapshots immediately and then every interval
+// until ctx is cancelled. Errors are logged, not returned, so one bad
+// pass doesn't stop the daemon.
+func (r *Reaper) Loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := r.RunOnce(ctx); err != nil {
+			slog.Error("cleanup pass failed", "error", err)
+		} else if n > 0 {
+			slog.Info("cleanup pass complete", "reaped", n)
+		}
+		if _, err := r.SweepOrphans(ctx, false); err != nil {
+			slog.Error("orphan sweep failed", "error", err)
+		}
+		if n, err := r.PruneSnapshots(ctx); err != nil {
+			slog.Error("snapshot prune failed", "error", err)
+		} else if n > 0 {
+			slog.Info("pruned expired snapshots", "removed", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap destroys a single session under the user's lock. The row is
+// re-read after locking because spawn may have reattached (cancelling
+// the grace period) between the listing query and now.
+func (r *Reaper) reap(ctx context.Context, candidate *state.Session, reason string) bool {
+	unlock, err := lock.Acquire(r.LockDir, candidate.User)
+	if err != nil {
+		slog.Error("cleanup: failed to acquire lock", "user", candidate.User, "error", err)
+		return false
+	}
+	defer unlock()
+
+	sess, err := r.Store.GetSession(candidate.User, candidate.Project)
+	if err != nil {
+		slog.Error("cleanup: failed to read session", "user", candidate.User, "project", candidate.Project, "error", err)
+		return false
+	}
+	if sess == nil || !r.expired(sess, time.Now()) {
+		return false
+	}
+
+	if r.Hibernate && graceExpired(sess, time.Now()) {
+		slog.Info("cleanup: hibernating session", "user", sess.User, "project", sess.Project, "container", sess.ContainerName)
+		if err := spawn.HibernateSession(ctx, r.Runtime, r.Store, sess, r.SnapshotTTL); err != nil {
+			slog.Error("cleanup: hibernate failed, will retry", "user", sess.User, "project", sess.Project, "error", err)
+			return false
+		}
+		reason = "hibernated"
+	} else {
+		slog.Info("cleanup: destroying session", "user", sess.User, "project", sess.Project, "container", sess.ContainerName, "reason", reason)
+		spawn.CleanupSessionResources(ctx, r.Runtime, sess)
+	}
+	spawn.RemoveAgentDir(r.AgentDir, sess.ContainerName)
+	if err := r.Store.DeleteSession(sess.User, sess.Project); err != nil {
+		slog.Error("cleanup: failed to delete session", "user", sess.User, "project", sess.Project, "error", err)
+		return false
+	}
+	r.Audit.Record(audit.Event{Type: audit.TypeSessionDestroy, User: sess.User, Project: sess.Project, Detail: reason})
+	return true
+}
+
+func (r *Reaper) expired(sess *state.Session, now time.Time) bool {
+	if graceExpired(sess, now) {
+		return true
+	}
+	if r.IdleTimeout > 0 && sess.Status == "running" && sess.LastActivity.Before(now.Add(-r.IdleTimeout)) {
+		return true
+	}
+	return sess.MaxLifetime.Before(now)
+}
+
+func graceExpired(sess *state.Session, now time.Time) bool {
+	return sess.Status == "grace_period" && sess.GraceExpiry.Valid && sess.GraceExpiry.Time.Before(now)
+}
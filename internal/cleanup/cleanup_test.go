@@ -0,0 +1,260 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func testReaper(t *testing.T) (*Reaper, *runtime.FakeRuntime, *state.FakeStore) {
+	t.Helper()
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	return &Reaper{Runtime: fake, Store: store, LockDir: t.TempDir()}, fake, store
+}
+
+func addSession(t *testing.T, fake *runtime.FakeRuntime, store *state.FakeStore, user string, maxLifetime time.Time) {
+	t.Helper()
+	now := time.Now().UTC()
+	name := "podspawn-" + user
+	fake.Containers[name] = true
+	if err := store.CreateSession(&state.Session{
+		User:          user,
+		ContainerID:   name,
+		ContainerName: name,
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		Connections:   0,
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   maxLifetime,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunOnceReapsExpiredGracePeriod(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "gone", time.Now().Add(8*time.Hour))
+	_ = store.SetGracePeriod("gone", "", time.Now().Add(-time.Second))
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("reaped = %d, want 1", n)
+	}
+	if _, ok := fake.Containers["podspawn-gone"]; ok {
+		t.Error("container should be removed")
+	}
+	if got, _ := store.GetSession("gone", ""); got != nil {
+		t.Error("session row should be deleted")
+	}
+}
+
+func TestRunOnceReapsExpiredLifetime(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "old", time.Now().Add(-time.Minute))
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("reaped = %d, want 1", n)
+	}
+	if _, ok := fake.Containers["podspawn-old"]; ok {
+		t.Error("container should be removed")
+	}
+}
+
+func TestRunOnceSkipsLiveSessions(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "active", time.Now().Add(8*time.Hour))
+	addSession(t, fake, store, "grace", time.Now().Add(8*time.Hour))
+	_ = store.SetGracePeriod("grace", "", time.Now().Add(time.Minute))
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("reaped = %d, want 0", n)
+	}
+	if len(store.Sessions) != 2 {
+		t.Errorf("sessions = %d, want 2", len(store.Sessions))
+	}
+}
+
+func TestRunOnceReapsIdleSessions(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	reaper.IdleTimeout = 30 * time.Minute
+	addSession(t, fake, store, "abandoned", time.Now().Add(8*time.Hour))
+	addSession(t, fake, store, "typing", time.Now().Add(8*time.Hour))
+	_, _ = store.UpdateConnections("abandoned", "", 1)
+	store.Sessions["abandoned|"].LastActivity = time.Now().Add(-time.Hour)
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("reaped = %d, want 1", n)
+	}
+	if _, ok := fake.Containers["podspawn-abandoned"]; ok {
+		t.Error("idle container should be removed even with a connection open")
+	}
+	if got, _ := store.GetSession("typing", ""); got == nil {
+		t.Error("active session should survive")
+	}
+}
+
+func TestRunOnceIgnoresIdleWhenDisabled(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "quiet", time.Now().Add(8*time.Hour))
+	store.Sessions["quiet|"].LastActivity = time.Now().Add(-24 * time.Hour)
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("reaped = %d, want 0 with idle timeout disabled", n)
+	}
+}
+
+func TestRunOnceCleansUpServicesAndNetwork(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	fake.Containers["svc-pg"] = true
+	now := time.Now().UTC()
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		Project:       "backend",
+		ContainerID:   "abc",
+		ContainerName: "podspawn-deploy-backend",
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   now.Add(-time.Second),
+		NetworkID:     "net-1",
+		ServiceIDs:    "svc-pg",
+	})
+
+	if _, err := reaper.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.Containers["svc-pg"]; ok {
+		t.Error("service container should be removed")
+	}
+	if len(fake.RemoveNetworkCalls) != 1 || fake.RemoveNetworkCalls[0] != "net-1" {
+		t.Errorf("network should be removed, got %v", fake.RemoveNetworkCalls)
+	}
+}
+
+func TestReapSkipsCancelledGracePeriod(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "back", time.Now().Add(8*time.Hour))
+	_ = store.SetGracePeriod("back", "", time.Now().Add(-time.Second))
+
+	candidates, _ := store.ExpiredGracePeriods()
+	// User reconnects between the listing query and the lock
+	_ = store.CancelGracePeriod("back", "")
+
+	if reaper.reap(context.Background(), candidates[0], "test") {
+		t.Error("reap should skip a session whose grace period was cancelled")
+	}
+	if _, ok := fake.Containers["podspawn-back"]; !ok {
+		t.Error("container should survive")
+	}
+}
+
+func TestLoopStopsOnCancel(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "old", time.Now().Add(-time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reaper.Loop(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		if got, _ := store.GetSession("old", ""); got == nil {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("loop did not reap expired session")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("loop did not stop after cancel")
+	}
+}
+
+func TestRunOnceHibernatesExpiredGracePeriod(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	reaper.Hibernate = true
+	reaper.SnapshotTTL = time.Hour
+	addSession(t, fake, store, "sleepy", time.Now().Add(8*time.Hour))
+	_ = store.SetGracePeriod("sleepy", "", time.Now().Add(-time.Second))
+
+	n, err := reaper.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("reaped = %d, want 1", n)
+	}
+	if len(fake.CommitCalls) != 1 {
+		t.Fatalf("expected one commit, got %v", fake.CommitCalls)
+	}
+	if snap, _ := store.GetSnapshot("sleepy", ""); snap == nil {
+		t.Error("snapshot should be recorded")
+	}
+	if _, ok := fake.Containers["podspawn-sleepy"]; ok {
+		t.Error("container should be removed after snapshotting")
+	}
+}
+
+func TestRunOnceDoesNotHibernateExpiredLifetime(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	reaper.Hibernate = true
+	addSession(t, fake, store, "old", time.Now().Add(-time.Minute))
+
+	if _, err := reaper.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.CommitCalls) != 0 {
+		t.Errorf("max lifetime should destroy, not hibernate; commits = %v", fake.CommitCalls)
+	}
+}
+
+func TestRunOnceKeepsSessionWhenHibernateFails(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	reaper.Hibernate = true
+	fake.CommitErr = errors.New("no space left on device")
+	addSession(t, fake, store, "sleepy", time.Now().Add(8*time.Hour))
+	_ = store.SetGracePeriod("sleepy", "", time.Now().Add(-time.Second))
+
+	n, _ := reaper.RunOnce(context.Background())
+	if n != 0 {
+		t.Errorf("reaped = %d, want 0", n)
+	}
+	if _, ok := fake.Containers["podspawn-sleepy"]; !ok {
+		t.Error("container should be kept for a retry")
+	}
+}
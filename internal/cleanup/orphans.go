@@ -0,0 +1,155 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+var managedLabels = map[string]string{"managed-by": "podspawn"}
+
+// Orphan is a podspawn-labeled Docker resource that no session row
+// references.
+type Orphan struct {
+	Kind string // "container" | "network" | "agent-dir"
+	ID   string
+	Name string
+}
+
+func (o Orphan) String() string {
+	if o.ID == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s (%s)", o.Kind, o.Name, shortID(o.ID))
+}
+
+// SweepOrphans finds containers and networks labeled managed-by=podspawn
+// that have no matching row in the sessions table (e.g. after the state
+// DB was wiped by a schema migration) and removes them, along with agent
+// directories left behind by containers that no longer exist. With dryRun set,
+// orphans are returned but left in place.
+//
+// Resources younger than r.OrphanMinAge are skipped: spawn creates the
+// container before it records the session row, so a brand-new container
+// is briefly indistinguishable from an orphan.
+func (r *Reaper) SweepOrphans(ctx context.Context, dryRun bool) ([]Orphan, error) {
+	containers, err := r.Runtime.ListContainers(ctx, managedLabels)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := r.Runtime.ListNetworks(ctx, managedLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions are listed after the runtime so that anything recorded
+	// in between is seen as owned rather than orphaned.
+	sessions, err := r.Store.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	ownedContainers, ownedNetworks := ownedResources(sessions)
+
+	cutoff := time.Now().Add(-r.OrphanMinAge)
+	var orphans []Orphan
+	for _, c := range containers {
+		if ownedContainers[c.ID] || ownedContainers[c.Name] || c.Created.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: "container", ID: c.ID, Name: c.Name})
+	}
+	for _, n := range networks {
+		if ownedNetworks[n.ID] || n.Created.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: "network", ID: n.ID, Name: n.Name})
+	}
+	orphans = append(orphans, r.orphanAgentDirs(ownedContainers, cutoff)...)
+
+	if dryRun {
+		return orphans, nil
+	}
+
+	// Containers first: Docker refuses to remove a network that still
+	// has endpoints attached.
+	var removed []Orphan
+	for _, o := range orphans {
+		var err error
+		switch o.Kind {
+		case "container":
+			err = r.Runtime.RemoveContainer(ctx, o.ID)
+		case "network":
+			err = r.Runtime.RemoveNetwork(ctx, o.ID)
+		case "agent-dir":
+			err = os.RemoveAll(filepath.Join(r.AgentDir, o.Name))
+		}
+		if err != nil {
+			slog.Warn("cleanup: failed to remove orphan", "kind", o.Kind, "name", o.Name, "error", err)
+			continue
+		}
+		slog.Info("cleanup: removed orphan", "kind", o.Kind, "name", o.Name, "id", o.ID)
+		removed = append(removed, o)
+	}
+	return removed, nil
+}
+
+// orphanAgentDirs lists agent directories whose container no session
+// row references. Like containers, recent ones are skipped: spawn
+// creates the directory before the container exists.
+func (r *Reaper) orphanAgentDirs(owned map[string]bool, cutoff time.Time) []Orphan {
+	if r.AgentDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(r.AgentDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("cleanup: listing agent directories failed", "dir", r.AgentDir, "error", err)
+		}
+		return nil
+	}
+	var orphans []Orphan
+	for _, e := range entries {
+		if !e.IsDir() || owned[e.Name()] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: "agent-dir", Name: e.Name()})
+	}
+	return orphans
+}
+
+// ownedResources collects every container name/ID and network ID that a
+// session row references.
+func ownedResources(sessions []*state.Session) (containers, networks map[string]bool) {
+	containers = make(map[string]bool)
+	networks = make(map[string]bool)
+	for _, sess := range sessions {
+		containers[sess.ContainerID] = true
+		containers[sess.ContainerName] = true
+		if sess.ServiceIDs != "" {
+			for _, id := range strings.Split(sess.ServiceIDs, ",") {
+				containers[id] = true
+			}
+		}
+		if sess.NetworkID != "" {
+			networks[sess.NetworkID] = true
+		}
+	}
+	return containers, networks
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
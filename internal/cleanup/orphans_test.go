@@ -0,0 +1,154 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func labeledContainer(fake *runtime.FakeRuntime, name string) {
+	fake.Containers[name] = true
+	fake.ContainerLabels[name] = map[string]string{"managed-by": "podspawn"}
+}
+
+func TestSweepOrphansRemovesUnknownResources(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	ctx := context.Background()
+
+	labeledContainer(fake, "podspawn-ghost")
+	labeledContainer(fake, "podspawn-deploy-backend")
+	labeledContainer(fake, "podspawn-deploy-backend-postgres")
+	fake.Containers["unmanaged"] = true
+	ownedNet, _ := fake.CreateNetwork(ctx, "podspawn-deploy-backend-net")
+	orphanNet, _ := fake.CreateNetwork(ctx, "podspawn-ghost-net")
+
+	now := time.Now().UTC()
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		Project:       "backend",
+		ContainerID:   "podspawn-deploy-backend",
+		ContainerName: "podspawn-deploy-backend",
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   now.Add(8 * time.Hour),
+		NetworkID:     ownedNet,
+		ServiceIDs:    "podspawn-deploy-backend-postgres",
+	})
+
+	removed, err := reaper.SweepOrphans(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("removed %d orphans, want 2: %v", len(removed), removed)
+	}
+	if removed[0].Kind != "container" || removed[0].Name != "podspawn-ghost" {
+		t.Errorf("removed[0] = %v, want container podspawn-ghost", removed[0])
+	}
+	if removed[1].Kind != "network" || removed[1].ID != orphanNet {
+		t.Errorf("removed[1] = %v, want network %s", removed[1], orphanNet)
+	}
+
+	for _, name := range []string{"podspawn-deploy-backend", "podspawn-deploy-backend-postgres", "unmanaged"} {
+		if _, ok := fake.Containers[name]; !ok {
+			t.Errorf("%s should not be removed", name)
+		}
+	}
+	if !fake.Networks[ownedNet] {
+		t.Error("owned network should not be removed")
+	}
+}
+
+func TestSweepOrphansDryRunLeavesResources(t *testing.T) {
+	reaper, fake, _ := testReaper(t)
+	labeledContainer(fake, "podspawn-ghost")
+
+	orphans, err := reaper.SweepOrphans(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 {
+		t.Fatalf("found %d orphans, want 1", len(orphans))
+	}
+	if _, ok := fake.Containers["podspawn-ghost"]; !ok {
+		t.Error("dry run should not remove containers")
+	}
+}
+
+func TestSweepOrphansSkipsYoungResources(t *testing.T) {
+	reaper, fake, _ := testReaper(t)
+	reaper.OrphanMinAge = time.Hour
+	ctx := context.Background()
+
+	_, _ = fake.CreateContainer(ctx, runtime.ContainerOpts{
+		Name:   "podspawn-new",
+		Labels: map[string]string{"managed-by": "podspawn"},
+	})
+	fake.Containers["podspawn-new"] = true
+	labeledContainer(fake, "podspawn-old")
+
+	orphans, err := reaper.SweepOrphans(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].Name != "podspawn-old" {
+		t.Errorf("only the old container should be swept, got %v", orphans)
+	}
+	if _, ok := fake.Containers["podspawn-new"]; !ok {
+		t.Error("young container should be skipped")
+	}
+}
+
+func TestSweepOrphansRemovesStaleAgentDirs(t *testing.T) {
+	reaper, _, store := testReaper(t)
+	reaper.AgentDir = t.TempDir()
+	reaper.OrphanMinAge = time.Hour
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"podspawn-ghost", "podspawn-deploy-backend", "podspawn-new"} {
+		dir := filepath.Join(reaper.AgentDir, name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if name != "podspawn-new" {
+			_ = os.Chtimes(dir, old, old)
+		}
+	}
+	now := time.Now().UTC()
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		Project:       "backend",
+		ContainerID:   "abc",
+		ContainerName: "podspawn-deploy-backend",
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   now.Add(8 * time.Hour),
+	})
+
+	removed, err := reaper.SweepOrphans(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Kind != "agent-dir" || removed[0].Name != "podspawn-ghost" {
+		t.Fatalf("removed = %v, want agent-dir podspawn-ghost", removed)
+	}
+	if removed[0].String() != "agent-dir podspawn-ghost" {
+		t.Errorf("String() = %q", removed[0].String())
+	}
+	if _, err := os.Stat(filepath.Join(reaper.AgentDir, "podspawn-ghost")); !os.IsNotExist(err) {
+		t.Error("stale agent dir should be removed")
+	}
+	for _, name := range []string{"podspawn-deploy-backend", "podspawn-new"} {
+		if _, err := os.Stat(filepath.Join(reaper.AgentDir, name)); err != nil {
+			t.Errorf("%s should be kept: %v", name, err)
+		}
+	}
+}
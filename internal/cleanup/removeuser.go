@@ -0,0 +1,86 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/lock"
+	"github.com/podspawn/podspawn/internal/spawn"
+)
+
+// Removal is one resource RemoveUser tried to delete.
+type Removal struct {
+	Kind string // "session" | "container" | "volume" | "snapshot"
+	Name string
+	Err  error
+}
+
+// RemoveUser tears down everything podspawn runs for user: every
+// session, connected or not, and any other container labeled for them.
+// With purge set it also deletes their persistent volumes and hibernate
+// snapshots. It keeps going past individual failures, which are
+// reported in the returned removals; the error is for failing to find
+// the user's resources at all.
+func (r *Reaper) RemoveUser(ctx context.Context, user string, purge bool) ([]Removal, error) {
+	var removals []Removal
+
+	sessions, err := r.Store.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	for _, sess := range sessions {
+		if sess.User != user {
+			continue
+		}
+		err := r.Stop(ctx, user, sess.Project, StopOptions{Timeout: 10 * time.Second, Force: true})
+		if errors.Is(err, ErrNoSession) {
+			continue // ended on its own meanwhile
+		}
+		removals = append(removals, Removal{Kind: "session", Name: SessionLabel(user, sess.Project), Err: err})
+	}
+
+	unlock, err := lock.Acquire(r.LockDir, user)
+	if err != nil {
+		return removals, fmt.Errorf("acquiring lock: %w", err)
+	}
+	defer unlock()
+
+	// Containers whose session row is gone, e.g. after a state DB reset
+	containers, err := r.Runtime.ListContainers(ctx, map[string]string{"managed-by": "podspawn", "podspawn-user": user})
+	if err != nil {
+		return removals, fmt.Errorf("listing containers: %w", err)
+	}
+	for _, c := range containers {
+		err := r.Runtime.RemoveContainer(ctx, c.ID)
+		spawn.RemoveAgentDir(r.AgentDir, c.Name)
+		removals = append(removals, Removal{Kind: "container", Name: c.Name, Err: err})
+	}
+
+	if !purge {
+		return removals, nil
+	}
+
+	vols, err := r.Runtime.ListVolumes(ctx, map[string]string{"managed-by": "podspawn", "podspawn-user": user}, false)
+	if err != nil {
+		return removals, fmt.Errorf("listing volumes: %w", err)
+	}
+	for _, v := range vols {
+		err := r.Runtime.RemoveVolume(ctx, v.Name)
+		removals = append(removals, Removal{Kind: "volume", Name: v.Name, Err: err})
+	}
+
+	snaps, err := r.Store.UserSnapshots(user)
+	if err != nil {
+		return removals, fmt.Errorf("listing snapshots: %w", err)
+	}
+	for _, snap := range snaps {
+		err := spawn.DiscardSnapshot(ctx, r.Runtime, r.Store, snap)
+		removals = append(removals, Removal{Kind: "snapshot", Name: snap.Image, Err: err})
+	}
+
+	slog.Info("removed user resources", "user", user, "purge", purge, "count", len(removals))
+	return removals, nil
+}
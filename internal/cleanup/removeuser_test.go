@@ -0,0 +1,134 @@
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/podfile"
+	"github.com/podspawn/podspawn/internal/spawn"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func setupUserResources(t *testing.T) (*Reaper, map[string]bool) {
+	t.Helper()
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "alice", time.Now().Add(time.Hour))
+	addSession(t, fake, store, "bob", time.Now().Add(time.Hour))
+	_, _ = store.UpdateConnections("alice", "", 1)
+
+	// Untracked container left behind by a state reset
+	fake.Containers["podspawn-alice-stray"] = false
+	fake.ContainerLabels["podspawn-alice-stray"] = map[string]string{"managed-by": "podspawn", "podspawn-user": "alice"}
+
+	fake.Volumes[spawn.VolumeName("alice", "", "home")] = spawn.VolumeLabels("alice", "", "home")
+	fake.Volumes[spawn.VolumeName("bob", "", "home")] = spawn.VolumeLabels("bob", "", "home")
+	ref := spawn.SnapshotRef("alice", "backend", time.Now())
+	fake.Images[ref] = true
+	_ = store.SaveSnapshot(&state.Snapshot{User: "alice", Project: "backend", Image: ref, BaseImage: "ubuntu:24.04", ExpiresAt: time.Now().Add(time.Hour)})
+
+	return reaper, fake.Containers
+}
+
+func TestRemoveUserStopsSessionsAndKeepsData(t *testing.T) {
+	reaper, containers := setupUserResources(t)
+	fake := reaper.Runtime
+
+	removals, err := reaper.RemoveUser(context.Background(), "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[string]int{}
+	for _, r := range removals {
+		if r.Err != nil {
+			t.Errorf("%s %s: %v", r.Kind, r.Name, r.Err)
+		}
+		kinds[r.Kind]++
+	}
+	if kinds["session"] != 1 || kinds["container"] != 1 || kinds["volume"] != 0 || kinds["snapshot"] != 0 {
+		t.Errorf("removals = %+v", removals)
+	}
+	if _, ok := containers["podspawn-alice"]; ok {
+		t.Error("connected session should be stopped anyway")
+	}
+	if _, ok := containers["podspawn-bob"]; !ok {
+		t.Error("other users' sessions must be left alone")
+	}
+	if sess, _ := reaper.Store.GetSession("alice", ""); sess != nil {
+		t.Error("session row should be deleted")
+	}
+	vols, _ := fake.ListVolumes(context.Background(), map[string]string{"podspawn-user": "alice"}, false)
+	if len(vols) != 1 {
+		t.Error("volumes should be kept without purge")
+	}
+}
+
+func TestRemoveUserPurge(t *testing.T) {
+	reaper, _ := setupUserResources(t)
+
+	removals, err := reaper.RemoveUser(context.Background(), "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var volumes, snapshots []string
+	for _, r := range removals {
+		switch r.Kind {
+		case "volume":
+			volumes = append(volumes, r.Name)
+		case "snapshot":
+			snapshots = append(snapshots, r.Name)
+		}
+	}
+	if len(volumes) != 1 || volumes[0] != "podspawn-alice-home" {
+		t.Errorf("volumes removed = %v", volumes)
+	}
+	if len(snapshots) != 1 {
+		t.Errorf("snapshots removed = %v", snapshots)
+	}
+	if snap, _ := reaper.Store.GetSnapshot("alice", "backend"); snap != nil {
+		t.Error("snapshot record should be deleted")
+	}
+	if vols, _ := reaper.Runtime.ListVolumes(context.Background(), map[string]string{"podspawn-user": "bob"}, false); len(vols) != 1 {
+		t.Error("other users' volumes must be left alone")
+	}
+}
+
+func TestRemoveUserWithNothing(t *testing.T) {
+	reaper, _, _ := testReaper(t)
+	removals, err := reaper.RemoveUser(context.Background(), "ghost", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 0 {
+		t.Errorf("removals = %+v, want none", removals)
+	}
+}
+
+func TestRemoveUserRemovesUntrackedServices(t *testing.T) {
+	reaper, fake, _ := testReaper(t)
+	ctx := context.Background()
+	// Services of a session whose row is gone, e.g. after a state reset
+	services := []podfile.ServiceConfig{{Name: "postgres", Image: "postgres:16"}}
+	if _, err := podfile.StartServices(ctx, fake, services, "net-1", "podspawn-alice-backend", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := podfile.StartServices(ctx, fake, services, "net-2", "podspawn-bob-backend", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	removals, err := reaper.RemoveUser(ctx, "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 1 || removals[0].Kind != "container" || removals[0].Name != "podspawn-alice-backend-postgres" {
+		t.Errorf("removals = %+v, want the orphaned postgres service", removals)
+	}
+	if _, ok := fake.Containers["podspawn-alice-backend-postgres"]; ok {
+		t.Error("alice's service should be removed")
+	}
+	if _, ok := fake.Containers["podspawn-bob-backend-postgres"]; !ok {
+		t.Error("other users' services must be left alone")
+	}
+}
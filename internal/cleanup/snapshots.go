@@ -0,0 +1,49 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/lock"
+	"github.com/podspawn/podspawn/internal/spawn"
+)
+
+// PruneSnapshots deletes hibernate snapshots past their retention. A
+// snapshot whose session is currently running is kept: the container
+// was restored from it and the image can't be removed while in use.
+// Returns the number of snapshots removed.
+func (r *Reaper) PruneSnapshots(ctx context.Context) (int, error) {
+	expired, err := r.Store.ExpiredSnapshots()
+	if err != nil {
+		return 0, fmt.Errorf("listing expired snapshots: %w", err)
+	}
+
+	removed := 0
+	for _, snap := range expired {
+		unlock, err := lock.Acquire(r.LockDir, snap.User)
+		if err != nil {
+			slog.Error("cleanup: failed to acquire lock", "user", snap.User, "error", err)
+			continue
+		}
+		// Re-read under the lock: the user may have hibernated again since
+		current, err := r.Store.GetSnapshot(snap.User, snap.Project)
+		if err != nil || current == nil || current.ExpiresAt.After(time.Now()) {
+			unlock()
+			continue
+		}
+		if sess, _ := r.Store.GetSession(snap.User, snap.Project); sess != nil {
+			unlock()
+			continue
+		}
+		slog.Info("cleanup: removing expired snapshot", "user", snap.User, "project", snap.Project, "image", snap.Image)
+		if err := spawn.DiscardSnapshot(ctx, r.Runtime, r.Store, current); err != nil {
+			slog.Error("cleanup: failed to delete snapshot", "user", snap.User, "project", snap.Project, "error", err)
+		} else {
+			removed++
+		}
+		unlock()
+	}
+	return removed, nil
+}
@@ -0,0 +1,47 @@
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	now := time.Now()
+	for _, s := range []struct {
+		user    string
+		expires time.Time
+	}{
+		{"gone", now.Add(-time.Hour)},
+		{"fresh", now.Add(time.Hour)},
+		{"inuse", now.Add(-time.Hour)},
+	} {
+		ref := "podspawn/snapshot:" + s.user
+		fake.Images[ref] = true
+		_ = store.SaveSnapshot(&state.Snapshot{User: s.user, Image: ref, BaseImage: "ubuntu:24.04", CreatedAt: now, ExpiresAt: s.expires})
+	}
+	// inuse was restored and is running from its snapshot image
+	addSession(t, fake, store, "inuse", now.Add(8*time.Hour))
+
+	n, err := reaper.PruneSnapshots(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("removed = %d, want 1", n)
+	}
+	if fake.Images["podspawn/snapshot:gone"] {
+		t.Error("expired snapshot image should be removed")
+	}
+	if snap, _ := store.GetSnapshot("gone", ""); snap != nil {
+		t.Error("expired snapshot record should be deleted")
+	}
+	for _, user := range []string{"fresh", "inuse"} {
+		if snap, _ := store.GetSnapshot(user, ""); snap == nil {
+			t.Errorf("%s snapshot should be kept", user)
+		}
+	}
+}
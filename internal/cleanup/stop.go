@@ -0,0 +1,67 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/lock"
+	"github.com/podspawn/podspawn/internal/spawn"
+)
+
+var (
+	ErrNoSession     = errors.New("no such session")
+	ErrSessionActive = errors.New("session has active connections")
+)
+
+type StopOptions struct {
+	Timeout time.Duration // grace given to the container's processes before SIGKILL
+	Force   bool          // stop even when clients are still connected
+}
+
+// Stop gracefully stops a session's container, tears down its companion
+// services and network, and deletes the session row. Holds the user's
+// lock throughout so it never races with spawn.
+func (r *Reaper) Stop(ctx context.Context, user, project string, opts StopOptions) error {
+	unlock, err := lock.Acquire(r.LockDir, user)
+	if err != nil {
+		return fmt.Errorf("acquiring lock: %w", err)
+	}
+	defer unlock()
+
+	sess, err := r.Store.GetSession(user, project)
+	if err != nil {
+		return fmt.Errorf("reading session %s: %w", SessionLabel(user, project), err)
+	}
+	if sess == nil {
+		return fmt.Errorf("%s: %w", SessionLabel(user, project), ErrNoSession)
+	}
+	if sess.Connections > 0 && !opts.Force {
+		return fmt.Errorf("%s has %d connection(s), use --force to stop anyway: %w",
+			SessionLabel(user, project), sess.Connections, ErrSessionActive)
+	}
+
+	slog.Info("stopping session", "user", user, "project", project, "container", sess.ContainerName, "connections", sess.Connections)
+	if err := r.Runtime.StopContainer(ctx, sess.ContainerName, opts.Timeout); err != nil {
+		// Removal below force-kills anyway; a failed graceful stop
+		// shouldn't leave the session half torn down.
+		slog.Warn("graceful stop failed, removing anyway", "container", sess.ContainerName, "error", err)
+	}
+	spawn.CleanupSessionResources(ctx, r.Runtime, sess)
+	spawn.RemoveAgentDir(r.AgentDir, sess.ContainerName)
+	if err := r.Store.DeleteSession(user, project); err != nil {
+		return fmt.Errorf("deleting session %s: %w", SessionLabel(user, project), err)
+	}
+	return nil
+}
+
+// SessionLabel formats a session key the way admins type it: user or
+// user/project.
+func SessionLabel(user, project string) string {
+	if project == "" {
+		return user
+	}
+	return user + "/" + project
+}
@@ -0,0 +1,94 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func TestStopRemovesSessionAndResources(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	reaper.AgentDir = t.TempDir()
+	agentDir := filepath.Join(reaper.AgentDir, "podspawn-deploy-backend")
+	if err := os.MkdirAll(agentDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	fake.Containers["podspawn-deploy-backend"] = true
+	fake.Containers["svc-pg"] = true
+	now := time.Now().UTC()
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		Project:       "backend",
+		ContainerID:   "abc",
+		ContainerName: "podspawn-deploy-backend",
+		Image:         "ubuntu:24.04",
+		Status:        "grace_period",
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   now.Add(8 * time.Hour),
+		NetworkID:     "net-1",
+		ServiceIDs:    "svc-pg",
+	})
+
+	if err := reaper.Stop(context.Background(), "deploy", "backend", StopOptions{Timeout: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetSession("deploy", "backend"); got != nil {
+		t.Error("session row should be deleted")
+	}
+	if _, ok := fake.Containers["podspawn-deploy-backend"]; ok {
+		t.Error("container should be removed")
+	}
+	if _, ok := fake.Containers["svc-pg"]; ok {
+		t.Error("service should be removed")
+	}
+	if len(fake.RemoveNetworkCalls) != 1 {
+		t.Errorf("network should be removed, got %v", fake.RemoveNetworkCalls)
+	}
+	if _, err := os.Stat(agentDir); !os.IsNotExist(err) {
+		t.Errorf("agent dir should be removed, stat err = %v", err)
+	}
+}
+
+func TestStopRefusesActiveSessionWithoutForce(t *testing.T) {
+	reaper, fake, store := testReaper(t)
+	addSession(t, fake, store, "deploy", time.Now().Add(8*time.Hour))
+	_, _ = store.UpdateConnections("deploy", "", 1)
+
+	err := reaper.Stop(context.Background(), "deploy", "", StopOptions{})
+	if !errors.Is(err, ErrSessionActive) {
+		t.Fatalf("expected ErrSessionActive, got %v", err)
+	}
+	if got, _ := store.GetSession("deploy", ""); got == nil {
+		t.Error("session should survive a refused stop")
+	}
+
+	if err := reaper.Stop(context.Background(), "deploy", "", StopOptions{Force: true}); err != nil {
+		t.Fatalf("forced stop: %v", err)
+	}
+	if got, _ := store.GetSession("deploy", ""); got != nil {
+		t.Error("forced stop should delete the session")
+	}
+}
+
+func TestStopMissingSession(t *testing.T) {
+	reaper, _, _ := testReaper(t)
+	err := reaper.Stop(context.Background(), "nobody", "", StopOptions{})
+	if !errors.Is(err, ErrNoSession) {
+		t.Fatalf("expected ErrNoSession, got %v", err)
+	}
+}
+
+func TestSessionLabel(t *testing.T) {
+	if got := SessionLabel("deploy", ""); got != "deploy" {
+		t.Errorf("got %q, want deploy", got)
+	}
+	if got := SessionLabel("deploy", "backend"); got != "deploy/backend" {
+		t.Errorf("got %q, want deploy/backend", got)
+	}
+}
@@ -12,6 +12,16 @@ import (
 
 type ClientConfig struct {
 	Servers ServerRouting `yaml:"servers"`
+	Login   LoginConfig   `yaml:"login"`
+}
+
+// LoginConfig holds the `podspawn login` settings so they don't have to
+// be passed as flags every time.
+type LoginConfig struct {
+	Issuer    string `yaml:"issuer"`
+	ClientID  string `yaml:"client_id"`
+	SignerURL string `yaml:"signer_url"` // podspawn ca-server base URL
+	Identity  string `yaml:"identity"`   // private key path; the cert is written next to it
 }
 
 type ServerRouting struct {
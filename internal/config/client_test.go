@@ -147,3 +147,19 @@ func TestResolveHostBarePodSuffix(t *testing.T) {
 		t.Errorf("bare .pod should fall back to default, got %q", got)
 	}
 }
+
+func TestLoadClientLogin(t *testing.T) {
+	yaml := `
+login:
+  issuer: https://id.example.com
+  client_id: podspawn
+  signer_url: https://ca.example.com
+`
+	cfg, err := LoadClient(writeClientTemp(t, yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Login.Issuer != "https://id.example.com" || cfg.Login.SignerURL != "https://ca.example.com" {
+		t.Errorf("login = %+v", cfg.Login)
+	}
+}
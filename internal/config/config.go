@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -14,58 +15,158 @@ type Config struct {
 	Auth         AuthConfig     `yaml:"auth"`
 	Defaults     DefaultsConfig `yaml:"defaults"`
 	Session      SessionConfig  `yaml:"session"`
+	Security     SecurityConfig `yaml:"security"`
+	Quota        QuotaConfig    `yaml:"quota"`
 	State        StateConfig    `yaml:"state"`
 	Log          LogConfig      `yaml:"log"`
+	Audit        AuditConfig    `yaml:"audit"`
 	ProjectsFile string         `yaml:"projects_file"`
+	GroupsFile   string         `yaml:"groups_file"`   // group name to members, for project allowed_groups
+	TemplatesDir string         `yaml:"templates_dir"` // shared Podfiles for extends: template:<name>
+	PackagesDir  string         `yaml:"packages_dir"`  // package recipes added to the built-ins
 }
 
 type AuthConfig struct {
 	KeyDir string `yaml:"key_dir"`
+
+	// CAKeys are files holding trusted user CA public keys. Users who
+	// present a certificate signed by one of them, naming their username
+	// as a principal, get in without a key file.
+	CAKeys []string `yaml:"ca_keys"`
+
+	OIDC OIDCConfig `yaml:"oidc"`
+
+	// KeySourcesFile registers GitHub, GitLab or URL key sources per
+	// user; sync-keys and the cleanup daemon refresh them into KeyDir
+	// every KeySyncInterval (empty = only when sync-keys is run).
+	KeySourcesFile  string `yaml:"key_sources_file"`
+	KeySyncInterval string `yaml:"key_sync_interval"`
+
+	// EmergencyKeys are break-glass keys, each bound to one admin with
+	// a user= annotation; a non-empty EmergencyUsers limits who may use
+	// them. They open a shell on the host rather than a container, for
+	// when Docker or podspawn is broken, and every use is logged.
+	EmergencyKeys  string   `yaml:"emergency_keys"`
+	EmergencyUsers []string `yaml:"emergency_users"`
+}
+
+// OIDCConfig configures `podspawn ca-server`, which trades ID tokens
+// from Issuer for short-lived certificates signed by CAKey. The CA's
+// public key goes in CAKeys so auth-keys accepts what it signs.
+type OIDCConfig struct {
+	Issuer        string `yaml:"issuer"`
+	ClientID      string `yaml:"client_id"`
+	UsernameClaim string `yaml:"username_claim"` // claim holding the podspawn username; emails map to their local part
+	// AllowedDomains are the email domains accepted when the claim
+	// holds an email address. Required for username_claim: email.
+	AllowedDomains []string `yaml:"allowed_domains"`
+	CAKey          string   `yaml:"ca_key"` // unencrypted OpenSSH private key
+	CertTTL        string   `yaml:"cert_ttl"`
+	Listen         string   `yaml:"listen"`
+	TLSCert        string   `yaml:"tls_cert"` // empty = plain HTTP, for use behind a TLS-terminating proxy
+	TLSKey         string   `yaml:"tls_key"`
 }
 
 type DefaultsConfig struct {
-	Image  string  `yaml:"image"`
-	Shell  string  `yaml:"shell"`
-	CPUs   float64 `yaml:"cpus"`
-	Memory string  `yaml:"memory"`
+	Image   string        `yaml:"image"`
+	Shell   string        `yaml:"shell"`
+	CPUs    float64       `yaml:"cpus"`
+	Memory  string        `yaml:"memory"`
+	Runtime string        `yaml:"runtime"` // OCI runtime (runsc, kata-runtime); empty = daemon default
+	Persist PersistConfig `yaml:"persist"`
+	DevUser DevUserConfig `yaml:"dev_user"`
+}
+
+// DevUserConfig runs the developer's shell, SFTP and setup commands as a
+// non-root user named after the SSH user, created when the container is.
+type DevUserConfig struct {
+	Enabled bool `yaml:"enabled"`
+	UID     int  `yaml:"uid"`  // 0 = the host account's uid
+	GID     int  `yaml:"gid"`  // 0 = the host account's primary gid
+	Sudo    bool `yaml:"sudo"` // passwordless sudo
+}
+
+// PersistConfig backs parts of the container filesystem with named
+// volumes keyed on user+project, so they survive container teardown.
+type PersistConfig struct {
+	Home      bool   `yaml:"home"`
+	Workspace string `yaml:"workspace"` // absolute path; empty = not persisted
 }
 
 type SessionConfig struct {
 	GracePeriod string `yaml:"grace_period"`
 	MaxLifetime string `yaml:"max_lifetime"`
-	Mode        string `yaml:"mode"`
+	Mode        string `yaml:"mode"`         // "grace-period" | "destroy-on-disconnect" | "hibernate"
+	IdleTimeout string `yaml:"idle_timeout"` // empty = never reap idle sessions
+	SnapshotTTL string `yaml:"snapshot_ttl"` // how long hibernate snapshots are kept
+
+	// AgentForwarding exposes the client's forwarded ssh-agent inside the
+	// container when the connection has one.
+	AgentForwarding bool `yaml:"agent_forwarding"`
 }
 
 type StateConfig struct {
-	DBPath  string `yaml:"db_path"`
-	LockDir string `yaml:"lock_dir"`
+	DBPath   string `yaml:"db_path"`
+	LockDir  string `yaml:"lock_dir"`
+	AgentDir string `yaml:"agent_dir"` // per-session agent relay sockets
 }
 
 type LogConfig struct {
 	File string `yaml:"file"`
 }
 
+// AuditConfig controls the hash-chained audit log of logins, sessions
+// and admin commands. auth-keys runs as sshd's AuthorizedKeysCommandUser
+// and spawn as the SSH user, so Path must be writable by both;
+// server-setup creates it that way.
+type AuditConfig struct {
+	Path     string `yaml:"path"`     // JSON lines; empty = no audit log
+	Database bool   `yaml:"database"` // also mirror entries into the state db
+	Anchor   string `yaml:"anchor"`   // root-only file anchoring the chain; empty = none
+}
+
 func Defaults() *Config {
 	return &Config{
 		Auth: AuthConfig{
-			KeyDir: "/etc/podspawn/keys",
+			KeyDir:          "/etc/podspawn/keys",
+			KeySourcesFile:  "/etc/podspawn/key_sources.yaml",
+			KeySyncInterval: "1h",
+			EmergencyKeys:   "/etc/podspawn/emergency.keys",
+			OIDC: OIDCConfig{
+				UsernameClaim: "preferred_username",
+				CertTTL:       "4h",
+				Listen:        ":7443",
+			},
 		},
 		Defaults: DefaultsConfig{
 			Image:  "ubuntu:24.04",
 			Shell:  "/bin/bash",
 			CPUs:   2.0,
 			Memory: "2g",
+			DevUser: DevUserConfig{
+				Sudo: true,
+			},
 		},
 		Session: SessionConfig{
-			GracePeriod: "60s",
-			MaxLifetime: "8h",
-			Mode:        "grace-period",
+			GracePeriod:     "60s",
+			MaxLifetime:     "8h",
+			Mode:            "grace-period",
+			SnapshotTTL:     "168h",
+			AgentForwarding: true,
 		},
 		State: StateConfig{
-			DBPath:  "/var/lib/podspawn/state.db",
-			LockDir: "/var/lib/podspawn/locks",
+			DBPath:   "/var/lib/podspawn/state.db",
+			LockDir:  "/var/lib/podspawn/locks",
+			AgentDir: "/var/lib/podspawn/agent",
+		},
+		Audit: AuditConfig{
+			Path:   "/var/log/podspawn/audit.jsonl",
+			Anchor: "/var/lib/podspawn/audit.anchor",
 		},
 		ProjectsFile: "/etc/podspawn/projects.yaml",
+		GroupsFile:   "/etc/podspawn/groups.yaml",
+		TemplatesDir: "/etc/podspawn/templates",
+		PackagesDir:  "/etc/podspawn/packages.d",
 	}
 }
 
@@ -76,9 +177,59 @@ func (c *Config) Validate() error {
 	if _, err := time.ParseDuration(c.Session.MaxLifetime); err != nil {
 		return fmt.Errorf("invalid session.max_lifetime %q: must include time unit (e.g. 60s, 8h)", c.Session.MaxLifetime)
 	}
+	if c.Session.IdleTimeout != "" {
+		if _, err := time.ParseDuration(c.Session.IdleTimeout); err != nil {
+			return fmt.Errorf("invalid session.idle_timeout %q: must include time unit (e.g. 30m, 2h)", c.Session.IdleTimeout)
+		}
+	}
+	switch c.Session.Mode {
+	case "grace-period", "destroy-on-disconnect", "hibernate":
+	default:
+		return fmt.Errorf("invalid session.mode %q: must be grace-period, destroy-on-disconnect, or hibernate", c.Session.Mode)
+	}
+	if _, err := time.ParseDuration(c.Session.SnapshotTTL); err != nil {
+		return fmt.Errorf("invalid session.snapshot_ttl %q: must include time unit (e.g. 24h, 168h)", c.Session.SnapshotTTL)
+	}
 	if _, err := ParseMemory(c.Defaults.Memory); err != nil {
 		return fmt.Errorf("invalid defaults.memory %q: %w", c.Defaults.Memory, err)
 	}
+	if c.Defaults.DevUser.UID < 0 || c.Defaults.DevUser.GID < 0 {
+		return fmt.Errorf("invalid defaults.dev_user: uid and gid must not be negative")
+	}
+	for _, path := range c.Auth.CAKeys {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid auth.ca_keys entry %q: must be an absolute path", path)
+		}
+	}
+	if c.Auth.KeySyncInterval != "" {
+		if d, err := time.ParseDuration(c.Auth.KeySyncInterval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid auth.key_sync_interval %q: must be a positive duration (e.g. 1h)", c.Auth.KeySyncInterval)
+		}
+	}
+	if p := c.Auth.EmergencyKeys; p != "" && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("invalid auth.emergency_keys %q: must be an absolute path", p)
+	}
+	if err := c.Auth.OIDC.Validate(); err != nil {
+		return fmt.Errorf("invalid auth.oidc config: %w", err)
+	}
+	if err := c.Security.Validate(); err != nil {
+		return fmt.Errorf("invalid security config: %w", err)
+	}
+	if err := c.Security.CheckDevUser(c.Defaults.DevUser); err != nil {
+		return fmt.Errorf("invalid defaults.dev_user: %w", err)
+	}
+	if err := c.Quota.Validate(); err != nil {
+		return fmt.Errorf("invalid quota config: %w", err)
+	}
+	if p := c.Audit.Path; p != "" && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("invalid audit.path %q: must be an absolute path", p)
+	}
+	if p := c.Audit.Anchor; p != "" && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("invalid audit.anchor %q: must be an absolute path", p)
+	}
+	if w := c.Defaults.Persist.Workspace; w != "" && !strings.HasPrefix(w, "/") {
+		return fmt.Errorf("invalid defaults.persist.workspace %q: must be an absolute path", w)
+	}
 	return nil
 }
 
@@ -106,3 +257,24 @@ func Load(path string) (*Config, error) {
 
 	return cfg, nil
 }
+
+func (c *OIDCConfig) Validate() error {
+	if _, err := time.ParseDuration(c.CertTTL); err != nil {
+		return fmt.Errorf("invalid cert_ttl %q: must include time unit (e.g. 4h)", c.CertTTL)
+	}
+	if c.Issuer != "" && (c.ClientID == "" || c.CAKey == "" || c.UsernameClaim == "") {
+		return fmt.Errorf("issuer %s needs client_id, ca_key and username_claim", c.Issuer)
+	}
+	if c.Issuer != "" && c.UsernameClaim == "email" && len(c.AllowedDomains) == 0 {
+		return fmt.Errorf("username_claim email needs allowed_domains, or any account the issuer signs in maps to a local user")
+	}
+	for _, d := range c.AllowedDomains {
+		if d == "" || strings.ContainsAny(d, "@ ") {
+			return fmt.Errorf("invalid allowed_domains entry %q", d)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	return nil
+}
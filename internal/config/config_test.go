@@ -179,6 +179,205 @@ session:
 	}
 }
 
+func TestLoadIdleTimeout(t *testing.T) {
+	if Defaults().Session.IdleTimeout != "" {
+		t.Error("idle_timeout should be disabled by default")
+	}
+
+	cfg, err := Load(writeTemp(t, "session:\n  idle_timeout: \"45m\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Session.IdleTimeout != "45m" {
+		t.Errorf("idle_timeout = %q, want 45m", cfg.Session.IdleTimeout)
+	}
+
+	_, err = Load(writeTemp(t, "session:\n  idle_timeout: \"45\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "session.idle_timeout") {
+		t.Errorf("expected idle_timeout validation error, got %v", err)
+	}
+}
+
+func TestLoadPersistConfig(t *testing.T) {
+	cfg, err := Load(writeTemp(t, "defaults:\n  persist:\n    home: true\n    workspace: /workspace\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Defaults.Persist.Home || cfg.Defaults.Persist.Workspace != "/workspace" {
+		t.Errorf("persist = %+v, want home + /workspace", cfg.Defaults.Persist)
+	}
+
+	_, err = Load(writeTemp(t, "defaults:\n  persist:\n    workspace: workspace\n"))
+	if err == nil || !strings.Contains(err.Error(), "defaults.persist.workspace") {
+		t.Errorf("expected relative workspace to be rejected, got %v", err)
+	}
+}
+
+func TestLoadHibernateMode(t *testing.T) {
+	cfg, err := Load(writeTemp(t, "session:\n  mode: hibernate\n  snapshot_ttl: \"72h\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Session.Mode != "hibernate" || cfg.Session.SnapshotTTL != "72h" {
+		t.Errorf("session = %+v", cfg.Session)
+	}
+
+	_, err = Load(writeTemp(t, "session:\n  mode: sleep\n"))
+	if err == nil || !strings.Contains(err.Error(), "session.mode") {
+		t.Errorf("expected unknown mode to be rejected, got %v", err)
+	}
+}
+
+func TestLoadAgentForwarding(t *testing.T) {
+	if !Defaults().Session.AgentForwarding {
+		t.Error("agent forwarding should be on by default")
+	}
+	cfg, err := Load(writeTemp(t, "session:\n  agent_forwarding: false\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Session.AgentForwarding {
+		t.Error("agent_forwarding: false should disable forwarding")
+	}
+}
+
+func TestLoadDevUser(t *testing.T) {
+	if d := Defaults().Defaults.DevUser; d.Enabled || !d.Sudo {
+		t.Errorf("dev user should be off with sudo on by default, got %+v", d)
+	}
+	cfg, err := Load(writeTemp(t, "defaults:\n  dev_user:\n    enabled: true\n    uid: 2000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cfg.Defaults.DevUser; !d.Enabled || d.UID != 2000 || d.GID != 0 || !d.Sudo {
+		t.Errorf("dev_user = %+v", d)
+	}
+
+	_, err = Load(writeTemp(t, "defaults:\n  dev_user:\n    gid: -1\n"))
+	if err == nil || !strings.Contains(err.Error(), "defaults.dev_user") {
+		t.Errorf("expected negative gid to be rejected, got %v", err)
+	}
+
+	_, err = Load(writeTemp(t, "defaults:\n  dev_user:\n    enabled: true\nsecurity:\n  read_only_rootfs: true\n"))
+	if err == nil || !strings.Contains(err.Error(), "defaults.dev_user") {
+		t.Errorf("expected dev_user on a read-only rootfs to be rejected, got %v", err)
+	}
+}
+
+func TestLoadCAKeys(t *testing.T) {
+	cfg, err := Load(writeTemp(t, "auth:\n  ca_keys:\n    - /etc/ssh/user_ca.pub\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Auth.CAKeys) != 1 || cfg.Auth.CAKeys[0] != "/etc/ssh/user_ca.pub" {
+		t.Errorf("ca_keys = %v", cfg.Auth.CAKeys)
+	}
+
+	_, err = Load(writeTemp(t, "auth:\n  ca_keys:\n    - user_ca.pub\n"))
+	if err == nil || !strings.Contains(err.Error(), "auth.ca_keys") {
+		t.Errorf("expected relative CA path to be rejected, got %v", err)
+	}
+}
+
+func TestLoadOIDC(t *testing.T) {
+	d := Defaults().Auth.OIDC
+	if d.UsernameClaim != "preferred_username" || d.CertTTL != "4h" {
+		t.Errorf("oidc defaults = %+v", d)
+	}
+
+	yaml := `
+auth:
+  oidc:
+    issuer: https://id.example.com
+    client_id: podspawn
+    ca_key: /etc/podspawn/ca
+    username_claim: email
+    allowed_domains: [example.com]
+`
+	cfg, err := Load(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.OIDC.UsernameClaim != "email" || cfg.Auth.OIDC.CertTTL != "4h" {
+		t.Errorf("oidc = %+v", cfg.Auth.OIDC)
+	}
+
+	for _, bad := range []string{
+		"auth:\n  oidc:\n    issuer: https://id.example.com\n",
+		"auth:\n  oidc:\n    cert_ttl: forever\n",
+		"auth:\n  oidc:\n    tls_cert: /etc/podspawn/tls.crt\n",
+		"auth:\n  oidc:\n    issuer: https://id.example.com\n    client_id: podspawn\n    ca_key: /etc/podspawn/ca\n    username_claim: email\n",
+	} {
+		if _, err := Load(writeTemp(t, bad)); err == nil || !strings.Contains(err.Error(), "auth.oidc") {
+			t.Errorf("expected auth.oidc error for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoadKeySync(t *testing.T) {
+	d := Defaults()
+	if d.Auth.KeySourcesFile != "/etc/podspawn/key_sources.yaml" || d.Auth.KeySyncInterval != "1h" {
+		t.Errorf("defaults = %q, %q", d.Auth.KeySourcesFile, d.Auth.KeySyncInterval)
+	}
+
+	cfg, err := Load(writeTemp(t, "auth:\n  key_sync_interval: \"\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.KeySyncInterval != "" {
+		t.Errorf("key_sync_interval = %q, want empty to disable periodic sync", cfg.Auth.KeySyncInterval)
+	}
+
+	for _, bad := range []string{"60", "-1h"} {
+		_, err := Load(writeTemp(t, "auth:\n  key_sync_interval: \""+bad+"\"\n"))
+		if err == nil || !strings.Contains(err.Error(), "auth.key_sync_interval") {
+			t.Errorf("key_sync_interval %q: expected validation error, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoadAudit(t *testing.T) {
+	if Defaults().Audit.Path != "/var/log/podspawn/audit.jsonl" {
+		t.Errorf("audit.path default = %q", Defaults().Audit.Path)
+	}
+
+	cfg, err := Load(writeTemp(t, "audit:\n  path: /srv/audit.jsonl\n  database: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Audit.Path != "/srv/audit.jsonl" || !cfg.Audit.Database {
+		t.Errorf("audit = %+v", cfg.Audit)
+	}
+
+	_, err = Load(writeTemp(t, "audit:\n  path: audit.jsonl\n"))
+	if err == nil || !strings.Contains(err.Error(), "audit.path") {
+		t.Errorf("expected audit.path validation error, got %v", err)
+	}
+	_, err = Load(writeTemp(t, "audit:\n  anchor: audit.anchor\n"))
+	if err == nil || !strings.Contains(err.Error(), "audit.anchor") {
+		t.Errorf("expected audit.anchor validation error, got %v", err)
+	}
+}
+
+func TestLoadEmergency(t *testing.T) {
+	if Defaults().Auth.EmergencyKeys != "/etc/podspawn/emergency.keys" {
+		t.Errorf("auth.emergency_keys default = %q", Defaults().Auth.EmergencyKeys)
+	}
+
+	cfg, err := Load(writeTemp(t, "auth:\n  emergency_users: [ops, root]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Auth.EmergencyUsers) != 2 || cfg.Auth.EmergencyUsers[0] != "ops" {
+		t.Errorf("emergency_users = %v, want [ops root]", cfg.Auth.EmergencyUsers)
+	}
+
+	_, err = Load(writeTemp(t, "auth:\n  emergency_keys: emergency.keys\n"))
+	if err == nil || !strings.Contains(err.Error(), "auth.emergency_keys") {
+		t.Errorf("expected auth.emergency_keys validation error, got %v", err)
+	}
+}
+
 func TestLoadRejectsInvalidMemory(t *testing.T) {
 	yaml := `
 defaults:
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadGroups reads the group definition file, which maps group names
+// to their member usernames:
+//
+//	backend: [alice, bob]
+//	client-acme: [carol]
+//
+// Returns an empty map if the file doesn't exist.
+func LoadGroups(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string][]string), nil
+		}
+		return nil, fmt.Errorf("reading groups file: %w", err)
+	}
+
+	var groups map[string][]string
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("parsing groups file: %w", err)
+	}
+	if groups == nil {
+		groups = make(map[string][]string)
+	}
+	return groups, nil
+}
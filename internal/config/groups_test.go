@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadGroupsMissing(t *testing.T) {
+	groups, err := LoadGroups(filepath.Join(t.TempDir(), "groups.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("expected empty map, got %v", groups)
+	}
+}
+
+func TestLoadGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(path, []byte("backend: [alice, bob]\nclient-acme:\n  - carol\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := LoadGroups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(groups["backend"], []string{"alice", "bob"}) || !slices.Equal(groups["client-acme"], []string{"carol"}) {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func TestLoadGroupsInvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(path, []byte("backend: alice"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGroups(path); err == nil {
+		t.Error("expected parse error for a group that isn't a list")
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadKeySources reads the key source registry: username to the
+// sources (github:<user>, gitlab:<user>, or an https URL) that
+// sync-keys pulls their keys from. Returns an empty map if the file
+// doesn't exist.
+func LoadKeySources(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string][]string), nil
+		}
+		return nil, fmt.Errorf("reading key sources file: %w", err)
+	}
+
+	var sources map[string][]string
+	if err := yaml.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("parsing key sources file: %w", err)
+	}
+	if sources == nil {
+		sources = make(map[string][]string)
+	}
+	return sources, nil
+}
+
+// SaveKeySources writes the key source registry atomically.
+func SaveKeySources(path string, sources map[string][]string) error {
+	data, err := yaml.Marshal(sources)
+	if err != nil {
+		return fmt.Errorf("marshaling key sources: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadKeySourcesMissing(t *testing.T) {
+	sources, err := LoadKeySources(filepath.Join(t.TempDir(), "key_sources.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 0 {
+		t.Errorf("expected empty map, got %v", sources)
+	}
+}
+
+func TestSaveAndLoadKeySources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "etc", "key_sources.yaml")
+	sources := map[string][]string{
+		"alice": {"github:alice", "https://keys.example.com/alice"},
+		"bob":   {"gitlab:bob"},
+	}
+	if err := SaveKeySources(path, sources); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadKeySources(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded["alice"], sources["alice"]) || !slices.Equal(loaded["bob"], sources["bob"]) {
+		t.Errorf("loaded = %v, want %v", loaded, sources)
+	}
+}
+
+func TestLoadKeySourcesInvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key_sources.yaml")
+	if err := os.WriteFile(path, []byte("alice: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeySources(path); err == nil {
+		t.Error("expected parse error")
+	}
+}
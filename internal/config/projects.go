@@ -6,6 +6,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +17,58 @@ type ProjectConfig struct {
 	LocalPath   string `yaml:"local_path"`
 	PodfileHash string `yaml:"podfile_hash"`
 	ImageTag    string `yaml:"image_tag"`
+
+	Security *SecurityConfig `yaml:"security,omitempty"` // overrides the server's security settings
+
+	// AllowedUsers and AllowedGroups restrict who may open a session in
+	// the project. Both empty = any registered user.
+	AllowedUsers  []string `yaml:"allowed_users,omitempty"`
+	AllowedGroups []string `yaml:"allowed_groups,omitempty"`
+}
+
+// Restricted reports whether the project has an access list.
+func (p ProjectConfig) Restricted() bool {
+	return len(p.AllowedUsers) > 0 || len(p.AllowedGroups) > 0
+}
+
+// Allows reports whether username may use the project, either by name
+// or through membership of one of its groups.
+func (p ProjectConfig) Allows(username string, groups map[string][]string) bool {
+	if !p.Restricted() {
+		return true
+	}
+	if slices.Contains(p.AllowedUsers, username) {
+		return true
+	}
+	for _, g := range p.AllowedGroups {
+		if slices.Contains(groups[g], username) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether everyone other may admit is also allowed by
+// p: p is unrestricted, or other is restricted and each of its users is
+// allowed by p and each of its groups is one of p's groups.
+func (p ProjectConfig) Covers(other ProjectConfig, groups map[string][]string) bool {
+	if !p.Restricted() {
+		return true
+	}
+	if !other.Restricted() {
+		return false
+	}
+	for _, u := range other.AllowedUsers {
+		if !p.Allows(u, groups) {
+			return false
+		}
+	}
+	for _, g := range other.AllowedGroups {
+		if !slices.Contains(p.AllowedGroups, g) {
+			return false
+		}
+	}
+	return true
 }
 
 // LoadProjects reads the project registry from a YAML file.
@@ -44,13 +98,17 @@ func SaveProjects(path string, projects map[string]ProjectConfig) error {
 	if err != nil {
 		return fmt.Errorf("marshaling projects: %w", err)
 	}
+	return writeFileAtomic(path, data)
+}
 
+func writeFileAtomic(path string, data []byte) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("creating directory %s: %w", dir, err)
 	}
 
-	tmp, err := os.CreateTemp(dir, ".projects-*.yaml")
+	base := filepath.Base(path)
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimSuffix(base, filepath.Ext(base))+"-*"+filepath.Ext(base))
 	if err != nil {
 		return fmt.Errorf("creating temp file: %w", err)
 	}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -107,3 +108,59 @@ func TestSaveProjectsCreatesDir(t *testing.T) {
 		t.Errorf("file should exist: %v", err)
 	}
 }
+
+func TestProjectAllows(t *testing.T) {
+	groups := map[string][]string{"acme": {"carol", "dave"}}
+
+	open := ProjectConfig{}
+	if !open.Allows("anyone", nil) {
+		t.Error("project without an access list should allow everyone")
+	}
+
+	p := ProjectConfig{AllowedUsers: []string{"alice"}, AllowedGroups: []string{"acme", "missing"}}
+	for user, want := range map[string]bool{"alice": true, "carol": true, "bob": false} {
+		if got := p.Allows(user, groups); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", user, got, want)
+		}
+	}
+	if p.Allows("carol", nil) {
+		t.Error("group access should be refused when groups are unavailable")
+	}
+}
+
+func TestProjectCovers(t *testing.T) {
+	groups := map[string][]string{"acme": {"carol", "dave"}}
+	parent := ProjectConfig{AllowedUsers: []string{"alice"}, AllowedGroups: []string{"acme"}}
+
+	tests := []struct {
+		name  string
+		child ProjectConfig
+		want  bool
+	}{
+		{"unrestricted child", ProjectConfig{}, false},
+		{"listed user", ProjectConfig{AllowedUsers: []string{"alice"}}, true},
+		{"user through a group", ProjectConfig{AllowedUsers: []string{"carol"}}, true},
+		{"same group", ProjectConfig{AllowedGroups: []string{"acme"}}, true},
+		{"other user", ProjectConfig{AllowedUsers: []string{"alice", "bob"}}, false},
+		{"other group", ProjectConfig{AllowedGroups: []string{"contractors"}}, false},
+	}
+	for _, tt := range tests {
+		if got := parent.Covers(tt.child, groups); got != tt.want {
+			t.Errorf("%s: Covers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(ProjectConfig{}).Covers(ProjectConfig{AllowedUsers: []string{"bob"}}, nil) {
+		t.Error("an unrestricted parent covers everyone")
+	}
+}
+
+func TestSaveProjectsOmitsEmptyACL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.yaml")
+	if err := SaveProjects(path, map[string]ProjectConfig{"open": {Repo: "r"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "allowed_") {
+		t.Errorf("unrestricted project should not write access lists:\n%s", data)
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import "fmt"
+
+// QuotaConfig caps what one user can hold across all their sessions at
+// once. Zero values are unlimited. Set at the top level of config.yaml
+// and per user in users/<name>.yaml, which wins field by field.
+type QuotaConfig struct {
+	MaxSessions int     `yaml:"max_sessions"`
+	MaxCPUs     float64 `yaml:"max_cpus"`   // summed over the user's dev and service containers; unlimited ones never fit
+	MaxMemory   string  `yaml:"max_memory"` // e.g. "8g"; summed like max_cpus
+}
+
+// Merge returns c with every field that is set in o replacing c's.
+// A nil o returns c unchanged.
+func (c QuotaConfig) Merge(o *QuotaConfig) QuotaConfig {
+	if o == nil {
+		return c
+	}
+	if o.MaxSessions != 0 {
+		c.MaxSessions = o.MaxSessions
+	}
+	if o.MaxCPUs != 0 {
+		c.MaxCPUs = o.MaxCPUs
+	}
+	if o.MaxMemory != "" {
+		c.MaxMemory = o.MaxMemory
+	}
+	return c
+}
+
+func (c *QuotaConfig) Validate() error {
+	if c.MaxSessions < 0 {
+		return fmt.Errorf("max_sessions must not be negative, got %d", c.MaxSessions)
+	}
+	if c.MaxCPUs < 0 {
+		return fmt.Errorf("max_cpus must not be negative, got %g", c.MaxCPUs)
+	}
+	if c.MaxMemory != "" {
+		if _, err := ParseMemory(c.MaxMemory); err != nil {
+			return fmt.Errorf("invalid max_memory %q: %w", c.MaxMemory, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuotaMerge(t *testing.T) {
+	base := QuotaConfig{MaxSessions: 3, MaxCPUs: 4, MaxMemory: "8g"}
+
+	got := base.Merge(&QuotaConfig{MaxSessions: 10})
+	if got.MaxSessions != 10 {
+		t.Errorf("max_sessions = %d, want the user's 10", got.MaxSessions)
+	}
+	if got.MaxCPUs != 4 || got.MaxMemory != "8g" {
+		t.Errorf("unset fields should keep base values, got %+v", got)
+	}
+	if same := base.Merge(nil); same != base {
+		t.Errorf("nil override should be a no-op, got %+v", same)
+	}
+}
+
+func TestQuotaValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		quota   QuotaConfig
+		wantErr string
+	}{
+		{"unlimited", QuotaConfig{}, ""},
+		{"limited", QuotaConfig{MaxSessions: 2, MaxCPUs: 3.5, MaxMemory: "6g"}, ""},
+		{"negative sessions", QuotaConfig{MaxSessions: -1}, "max_sessions"},
+		{"negative cpus", QuotaConfig{MaxCPUs: -2}, "max_cpus"},
+		{"bad memory", QuotaConfig{MaxMemory: "lots"}, "max_memory"},
+	}
+	for _, tc := range cases {
+		err := tc.quota.Validate()
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error = %v, want mention of %s", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLoadQuotaConfig(t *testing.T) {
+	cfg, err := Load(writeTemp(t, "quota:\n  max_sessions: 3\n  max_memory: \"8g\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Quota.MaxSessions != 3 || cfg.Quota.MaxMemory != "8g" || cfg.Quota.MaxCPUs != 0 {
+		t.Errorf("quota = %+v", cfg.Quota)
+	}
+
+	_, err = Load(writeTemp(t, "quota:\n  max_cpus: -1\n"))
+	if err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("expected quota validation error, got %v", err)
+	}
+}
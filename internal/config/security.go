@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecurityConfig hardens dev containers. The zero value keeps Docker's
+// defaults. Set at the top level of config.yaml, per project in
+// projects.yaml, and per user in users/<name>.yaml; the more specific
+// level wins field by field.
+type SecurityConfig struct {
+	// Capabilities is the allowlist kept after dropping ALL. nil keeps
+	// Docker's default set; an explicit empty list keeps none.
+	Capabilities    []string `yaml:"capabilities"`
+	NoNewPrivileges *bool    `yaml:"no_new_privileges"`
+	PidsLimit       int64    `yaml:"pids_limit"`       // 0 = unlimited
+	ReadOnlyRootfs  *bool    `yaml:"read_only_rootfs"` // /tmp gets a tmpfs
+	SeccompProfile  string   `yaml:"seccomp_profile"`  // host path to a JSON profile, or "unconfined"
+	AppArmorProfile string   `yaml:"apparmor_profile"` // name of a profile loaded on the host
+	UsernsMode      string   `yaml:"userns_mode"`      // "" = daemon's userns-remap setting, "host" = opt out
+}
+
+// Merge returns c with every field that is set in o replacing c's.
+// A nil o returns c unchanged.
+func (c SecurityConfig) Merge(o *SecurityConfig) SecurityConfig {
+	if o == nil {
+		return c
+	}
+	if o.Capabilities != nil {
+		c.Capabilities = o.Capabilities
+	}
+	if o.NoNewPrivileges != nil {
+		c.NoNewPrivileges = o.NoNewPrivileges
+	}
+	if o.PidsLimit != 0 {
+		c.PidsLimit = o.PidsLimit
+	}
+	if o.ReadOnlyRootfs != nil {
+		c.ReadOnlyRootfs = o.ReadOnlyRootfs
+	}
+	if o.SeccompProfile != "" {
+		c.SeccompProfile = o.SeccompProfile
+	}
+	if o.AppArmorProfile != "" {
+		c.AppArmorProfile = o.AppArmorProfile
+	}
+	if o.UsernsMode != "" {
+		c.UsernsMode = o.UsernsMode
+	}
+	return c
+}
+
+func (c *SecurityConfig) Validate() error {
+	if c.PidsLimit < 0 {
+		return fmt.Errorf("pids_limit must not be negative, got %d", c.PidsLimit)
+	}
+	if p := c.SeccompProfile; p != "" && p != "unconfined" && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("seccomp_profile must be an absolute path or \"unconfined\", got %q", p)
+	}
+	if c.UsernsMode != "" && c.UsernsMode != "host" {
+		return fmt.Errorf("userns_mode must be empty or \"host\", got %q (remapping itself is enabled with userns-remap in daemon.json)", c.UsernsMode)
+	}
+	return nil
+}
+
+// CheckDevUser reports settings that would make creating dev user d
+// fail: it is added with useradd and chown as root once the container
+// is up, and sudo needs setuid to work.
+func (c SecurityConfig) CheckDevUser(d DevUserConfig) error {
+	if !d.Enabled {
+		return nil
+	}
+	if c.ReadOnlyRootfs != nil && *c.ReadOnlyRootfs {
+		return fmt.Errorf("dev_user can't be added to a read_only_rootfs container")
+	}
+	need := []string{"CHOWN"}
+	if d.Sudo {
+		need = append(need, "SETUID", "SETGID")
+	}
+	if c.Capabilities != nil {
+		for _, capName := range need {
+			if !c.hasCapability(capName) {
+				return fmt.Errorf("dev_user needs %s in capabilities", capName)
+			}
+		}
+	}
+	if d.Sudo && c.NoNewPrivileges != nil && *c.NoNewPrivileges {
+		return fmt.Errorf("dev_user.sudo doesn't work with no_new_privileges")
+	}
+	return nil
+}
+
+// hasCapability reports whether the allowlist keeps capName, spelled
+// the ways Docker accepts: any case, with or without CAP_, or ALL.
+func (c SecurityConfig) hasCapability(capName string) bool {
+	for _, have := range c.Capabilities {
+		have = strings.TrimPrefix(strings.ToUpper(have), "CAP_")
+		if have == "ALL" || have == capName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecurityMerge(t *testing.T) {
+	yes, no := true, false
+	base := SecurityConfig{
+		Capabilities:    []string{"CHOWN", "SETUID"},
+		NoNewPrivileges: &yes,
+		PidsLimit:       512,
+		AppArmorProfile: "podspawn-default",
+	}
+
+	got := base.Merge(&SecurityConfig{
+		Capabilities:    []string{},
+		NoNewPrivileges: &no,
+		ReadOnlyRootfs:  &yes,
+	})
+	if got.Capabilities == nil || len(got.Capabilities) != 0 {
+		t.Errorf("explicit empty allowlist should replace base, got %v", got.Capabilities)
+	}
+	if *got.NoNewPrivileges {
+		t.Error("override should be able to turn no_new_privileges off")
+	}
+	if got.PidsLimit != 512 || got.AppArmorProfile != "podspawn-default" {
+		t.Errorf("unset fields should keep base values, got %+v", got)
+	}
+	if got.ReadOnlyRootfs == nil || !*got.ReadOnlyRootfs {
+		t.Error("read_only_rootfs should come from the override")
+	}
+
+	if same := base.Merge(nil); same.PidsLimit != 512 || len(same.Capabilities) != 2 {
+		t.Errorf("nil override should be a no-op, got %+v", same)
+	}
+}
+
+func TestSecurityValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sec     SecurityConfig
+		wantErr string
+	}{
+		{"zero value", SecurityConfig{}, ""},
+		{"unconfined seccomp", SecurityConfig{SeccompProfile: "unconfined"}, ""},
+		{"absolute seccomp", SecurityConfig{SeccompProfile: "/etc/podspawn/seccomp.json"}, ""},
+		{"relative seccomp", SecurityConfig{SeccompProfile: "seccomp.json"}, "seccomp_profile"},
+		{"negative pids", SecurityConfig{PidsLimit: -1}, "pids_limit"},
+		{"host userns", SecurityConfig{UsernsMode: "host"}, ""},
+		{"bogus userns", SecurityConfig{UsernsMode: "private"}, "userns_mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sec.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurityCheckDevUser(t *testing.T) {
+	on := true
+	dev := DevUserConfig{Enabled: true, Sudo: true}
+	tests := []struct {
+		name    string
+		sec     SecurityConfig
+		dev     DevUserConfig
+		wantErr string
+	}{
+		{"defaults", SecurityConfig{}, dev, ""},
+		{"disabled", SecurityConfig{ReadOnlyRootfs: &on, Capabilities: []string{}}, DevUserConfig{}, ""},
+		{"read-only rootfs", SecurityConfig{ReadOnlyRootfs: &on}, dev, "read_only_rootfs"},
+		{"no chown", SecurityConfig{Capabilities: []string{"SETUID", "SETGID"}}, dev, "CHOWN"},
+		{"no setuid", SecurityConfig{Capabilities: []string{"CHOWN", "SETGID"}}, dev, "SETUID"},
+		{"no setuid without sudo", SecurityConfig{Capabilities: []string{"chown"}}, DevUserConfig{Enabled: true}, ""},
+		{"spellings", SecurityConfig{Capabilities: []string{"cap_chown", "CAP_SETUID", "setgid"}}, dev, ""},
+		{"all", SecurityConfig{Capabilities: []string{"ALL"}}, dev, ""},
+		{"no new privileges", SecurityConfig{NoNewPrivileges: &on}, dev, "no_new_privileges"},
+		{"no new privileges without sudo", SecurityConfig{NoNewPrivileges: &on}, DevUserConfig{Enabled: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sec.CheckDevUser(tt.dev)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSecurityConfig(t *testing.T) {
+	cfg, err := Load(writeTemp(t, `
+security:
+  capabilities: [CHOWN, DAC_OVERRIDE, SETUID, SETGID]
+  no_new_privileges: true
+  pids_limit: 1024
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Security.Capabilities) != 4 || cfg.Security.PidsLimit != 1024 {
+		t.Errorf("security = %+v", cfg.Security)
+	}
+	if cfg.Security.NoNewPrivileges == nil || !*cfg.Security.NoNewPrivileges {
+		t.Error("no_new_privileges should be set")
+	}
+
+	_, err = Load(writeTemp(t, "security:\n  pids_limit: -5\n"))
+	if err == nil || !strings.Contains(err.Error(), "security") {
+		t.Errorf("expected security validation error, got %v", err)
+	}
+}
@@ -14,9 +14,12 @@ type UserOverrides struct {
 	Image    string            `yaml:"image"`
 	CPUs     float64           `yaml:"cpus"`
 	Memory   string            `yaml:"memory"`
+	Runtime  string            `yaml:"runtime"`
 	Shell    string            `yaml:"shell"`
 	Env      map[string]string `yaml:"env"`
 	Dotfiles *DotfilesOverride `yaml:"dotfiles"`
+	Security *SecurityConfig   `yaml:"security"` // applied over server and project settings
+	Quota    *QuotaConfig      `yaml:"quota"`    // applied over the server-wide quota
 }
 
 type DotfilesOverride struct {
@@ -24,10 +27,15 @@ type DotfilesOverride struct {
 	Install string `yaml:"install"`
 }
 
+// UserOverridesPath is where a user's overrides live under baseDir.
+func UserOverridesPath(baseDir, username string) string {
+	return filepath.Join(baseDir, "users", username+".yaml")
+}
+
 // LoadUserOverrides reads per-user config from /etc/podspawn/users/<username>.yaml.
 // Returns nil without error if the file doesn't exist.
 func LoadUserOverrides(baseDir, username string) (*UserOverrides, error) {
-	path := filepath.Join(baseDir, "users", username+".yaml")
+	path := UserOverridesPath(baseDir, username)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -34,6 +34,8 @@ env:
 dotfiles:
   repo: github.com/user/dots
   install: ./install.sh
+quota:
+  max_sessions: 5
 `
 	if err := os.WriteFile(filepath.Join(usersDir, "deploy.yaml"), []byte(content), 0644); err != nil {
 		t.Fatal(err)
@@ -64,6 +66,9 @@ dotfiles:
 	if uo.Dotfiles == nil || uo.Dotfiles.Repo != "github.com/user/dots" {
 		t.Error("dotfiles not parsed")
 	}
+	if uo.Quota == nil || uo.Quota.MaxSessions != 5 {
+		t.Errorf("quota = %+v", uo.Quota)
+	}
 }
 
 func TestLoadUserOverridesPartial(t *testing.T) {
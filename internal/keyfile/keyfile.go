@@ -0,0 +1,387 @@
+// Package keyfile reads and rewrites the per-user key files in
+// auth.key_dir. Each line is a public key in authorized_keys format
+// (type, data, comment; no options), optionally prefixed by podspawn
+// annotations:
+//
+//	expires=2026-12-31T00:00:00Z ssh-ed25519 AAAAC3Nza... laptop
+//	source=github:alice ssh-ed25519 AAAAC3Nza... alice@github
+//	user=ops ssh-ed25519 AAAAC3Nza... ops@vault
+//
+// Keys with a source are owned by key sync and replaced on every sync
+// of that source; keys without one were added by hand. user binds a
+// key in the shared emergency key file to one admin.
+//
+// Comment and blank lines are kept as they are when a file is rewritten,
+// and so are lines podspawn can't parse, including ones carrying
+// authorized_keys options: they are logged and otherwise left alone.
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/lock"
+)
+
+const (
+	expiresPrefix = "expires="
+	sourcePrefix  = "source="
+	userPrefix    = "user="
+)
+
+// Key is one public key line.
+type Key struct {
+	Type    string
+	Data    string // base64 key blob
+	Comment string
+	Expires time.Time // zero = never
+	Source  string    // key sync source that owns the key; empty = added by hand
+	User    string    // the only user the key is for; emergency keys only
+}
+
+// ParseKey parses one non-comment line.
+func ParseKey(line string) (*Key, error) {
+	fields := strings.Fields(line)
+	k := &Key{}
+annotations:
+	for len(fields) > 0 {
+		switch {
+		case strings.HasPrefix(fields[0], expiresPrefix):
+			t, err := parseTime(strings.TrimPrefix(fields[0], expiresPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expires annotation: %w", err)
+			}
+			k.Expires = t
+		case strings.HasPrefix(fields[0], sourcePrefix):
+			k.Source = strings.TrimPrefix(fields[0], sourcePrefix)
+		case strings.HasPrefix(fields[0], userPrefix):
+			k.User = strings.TrimPrefix(fields[0], userPrefix)
+		default:
+			break annotations
+		}
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("key must have at least type and data fields")
+	}
+	if !isKeyType(fields[0]) {
+		return nil, fmt.Errorf("unrecognized key type %q (authorized_keys options are not supported)", fields[0])
+	}
+	k.Type, k.Data = fields[0], fields[1]
+	k.Comment = strings.Join(fields[2:], " ")
+	return k, nil
+}
+
+// isKeyType reports whether s looks like an SSH public key algorithm
+// rather than an authorized_keys option list.
+func isKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-sha2-") || strings.HasPrefix(s, "sk-")
+}
+
+// AuthorizedKey is the key in plain authorized_keys form, without
+// podspawn annotations.
+func (k *Key) AuthorizedKey() string {
+	s := k.Type + " " + k.Data
+	if k.Comment != "" {
+		s += " " + k.Comment
+	}
+	return s
+}
+
+// String is the key as written to a key file.
+func (k *Key) String() string {
+	s := k.AuthorizedKey()
+	if k.User != "" {
+		s = userPrefix + k.User + " " + s
+	}
+	if k.Source != "" {
+		s = sourcePrefix + k.Source + " " + s
+	}
+	if !k.Expires.IsZero() {
+		s = expiresPrefix + k.Expires.UTC().Format(time.RFC3339) + " " + s
+	}
+	return s
+}
+
+// Fingerprint returns the OpenSSH SHA256 fingerprint of the key blob,
+// or "" if the data isn't valid base64.
+func (k *Key) Fingerprint() string {
+	blob, err := base64.StdEncoding.DecodeString(k.Data)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Expired reports whether the key has an expiry at or before now.
+func (k *Key) Expired(now time.Time) bool {
+	return !k.Expires.IsZero() && !now.Before(k.Expires)
+}
+
+// ParseExpiry reads a user-supplied expiry: a date (midnight UTC at
+// the start of that day), an RFC 3339 timestamp, or a duration from now
+// such as 720h.
+func ParseExpiry(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("expiry duration must be positive, got %s", s)
+		}
+		return now.Add(d).UTC().Truncate(time.Second), nil
+	}
+	t, err := parseTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q: use YYYY-MM-DD, RFC 3339, or a duration like 720h", s)
+	}
+	return t, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// File is a parsed key file.
+type File struct {
+	lines []line
+}
+
+type line struct {
+	text string // verbatim, for comments, blanks and unparseable lines
+	key  *Key
+}
+
+// Parse reads a key file. A line that isn't a key podspawn understands
+// is logged and kept verbatim, so one bad line neither locks the user
+// out nor gets dropped on the next rewrite.
+func Parse(r io.Reader) (*File, error) {
+	return parse(r, "")
+}
+
+func parse(r io.Reader, path string) (*File, error) {
+	f := &File{}
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			f.lines = append(f.lines, line{text: text})
+			continue
+		}
+		k, err := ParseKey(text)
+		if err != nil {
+			slog.Warn("keyfile: keeping unparseable line as is", "path", path, "line", n, "error", err)
+			f.lines = append(f.lines, line{text: text})
+			continue
+		}
+		f.lines = append(f.lines, line{key: k})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Read parses the key file at path. A missing file returns an error
+// satisfying errors.Is(err, fs.ErrNotExist).
+func Read(path string) (*File, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close() //nolint:errcheck // read-only file
+	f, err := parse(fh, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Keys returns the file's keys in order.
+func (f *File) Keys() []*Key {
+	var keys []*Key
+	for _, l := range f.lines {
+		if l.key != nil {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Add appends k unless the same key (type and data) is already present,
+// in which case the existing entry takes k's expiry and source and, if
+// given, its comment. Reports whether k was new.
+func (f *File) Add(k *Key) bool {
+	for _, existing := range f.Keys() {
+		if existing.Type == k.Type && existing.Data == k.Data {
+			existing.Expires = k.Expires
+			existing.Source = k.Source
+			if k.Comment != "" {
+				existing.Comment = k.Comment
+			}
+			return false
+		}
+	}
+	f.lines = append(f.lines, line{key: k})
+	return true
+}
+
+// ReplaceSource swaps the keys owned by source for keys, which are
+// tagged with it. A fetched key that is already in the file by hand
+// stays a hand-added key. Returns how many keys were added and removed.
+func (f *File) ReplaceSource(source string, keys []*Key) (added, removed int) {
+	incoming := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		incoming[k.Type+" "+k.Data] = true
+	}
+
+	kept := f.lines[:0]
+	have := make(map[string]bool)
+	for _, l := range f.lines {
+		if l.key != nil && l.key.Source == source {
+			id := l.key.Type + " " + l.key.Data
+			if !incoming[id] {
+				removed++
+				continue
+			}
+			have[id] = true
+		} else if l.key != nil {
+			have[l.key.Type+" "+l.key.Data] = true
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+
+	for _, k := range keys {
+		id := k.Type + " " + k.Data
+		if have[id] {
+			continue
+		}
+		have[id] = true
+		k.Source = source
+		f.lines = append(f.lines, line{key: k})
+		added++
+	}
+	return added, removed
+}
+
+// Remove deletes every key with the given fingerprint (the SHA256:
+// prefix is optional) and returns how many were removed.
+func (f *File) Remove(fingerprint string) int {
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		fingerprint = "SHA256:" + fingerprint
+	}
+	kept := f.lines[:0]
+	removed := 0
+	for _, l := range f.lines {
+		if l.key != nil && l.key.Fingerprint() == fingerprint {
+			removed++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+	return removed
+}
+
+// Bytes renders the file.
+func (f *File) Bytes() []byte {
+	var b bytes.Buffer
+	for _, l := range f.lines {
+		if l.key != nil {
+			b.WriteString(l.key.String())
+		} else {
+			b.WriteString(l.text)
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Write replaces the file at path atomically, so sshd's auth-keys never
+// sees a half-written file.
+func Write(path string, f *File) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(f.Bytes()); err != nil {
+		tmp.Close()        //nolint:errcheck
+		os.Remove(tmpPath) //nolint:errcheck
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()        //nolint:errcheck
+		os.Remove(tmpPath) //nolint:errcheck
+		return fmt.Errorf("setting permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return fmt.Errorf("renaming %s to %s: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// Update applies fn to the key file at path while holding an flock on
+// it, so add-user, keys remove, key sync and remove-user can't lose
+// each other's changes. A missing file starts empty. The file is
+// written back only if fn reports a change.
+func Update(path string, fn func(f *File) (changed bool, err error)) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		f = &File{}
+	} else if err != nil {
+		return err
+	}
+	changed, err := fn(f)
+	if err != nil || !changed {
+		return err
+	}
+	return Write(path, f)
+}
+
+// Delete removes the key file at path under the same lock as Update. A
+// missing file returns an error satisfying errors.Is(err, fs.ErrNotExist).
+func Delete(path string) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return os.Remove(path)
+}
+
+// lockFile takes the flock for the key file at path. The lock file sits
+// next to it as .<name>.lock; usernames can't start with a dot, so it
+// is never mistaken for a key file.
+func lockFile(path string) (func(), error) {
+	return lock.Acquire(filepath.Dir(path), "."+filepath.Base(path))
+}
@@ -0,0 +1,258 @@
+package keyfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// ssh-keygen -lf reports SHA256:i+01W+UDKnVS5S6VIjOAEp31yFsR8Np0LIWIqC78fyc for this key
+	testKey         = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDWizsqWrLPW+1E7N3GdnBjvwRBcVPCUhMIsDOPKm3Bl laptop"
+	testFingerprint = "SHA256:i+01W+UDKnVS5S6VIjOAEp31yFsR8Np0LIWIqC78fyc"
+)
+
+func TestParseKey(t *testing.T) {
+	k, err := ParseKey("expires=2026-12-31T12:00:00Z " + testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != "ssh-ed25519" || k.Comment != "laptop" {
+		t.Errorf("key = %+v", k)
+	}
+	if want := time.Date(2026, 12, 31, 12, 0, 0, 0, time.UTC); !k.Expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", k.Expires, want)
+	}
+	if k.AuthorizedKey() != testKey {
+		t.Errorf("AuthorizedKey() = %q", k.AuthorizedKey())
+	}
+	if !strings.HasPrefix(k.String(), "expires=2026-12-31T12:00:00Z ssh-ed25519 ") {
+		t.Errorf("String() = %q", k.String())
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	for _, bad := range []string{"ssh-ed25519", "expires=tomorrow " + testKey, "expires=2026-12-31", "no-pty " + testKey} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestParseKeepsUnparseableLines(t *testing.T) {
+	content := "# by hand\n" + testKey + "\n" + `restrict,command="backup" ssh-ed25519 AAAAopts backup` + "\nexpires=someday ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\n"
+	f, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := f.Keys(); len(keys) != 1 || keys[0].Comment != "laptop" {
+		t.Errorf("keys = %+v, want only the laptop key", keys)
+	}
+	if f.Remove(testFingerprint) != 1 {
+		t.Fatal("laptop key should be removable")
+	}
+	want := "# by hand\n" + `restrict,command="backup" ssh-ed25519 AAAAopts backup` + "\nexpires=someday ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("rewrite = %q, want %q", got, want)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	k, _ := ParseKey(testKey)
+	if got := k.Fingerprint(); got != testFingerprint {
+		t.Errorf("fingerprint = %s, want %s", got, testFingerprint)
+	}
+	k.Data = "!!!"
+	if k.Fingerprint() != "" {
+		t.Error("invalid key data should have no fingerprint")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	k := &Key{}
+	if k.Expired(now) {
+		t.Error("key without expiry should never expire")
+	}
+	k.Expires = now
+	if !k.Expired(now) {
+		t.Error("key should be expired at its expiry time")
+	}
+	k.Expires = now.Add(time.Second)
+	if k.Expired(now) {
+		t.Error("key should be valid before its expiry time")
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2026-12-31", time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"2026-12-31T08:00:00+02:00", time.Date(2026, 12, 31, 6, 0, 0, 0, time.UTC)},
+		{"720h", now.Add(720 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := ParseExpiry(tt.in, now)
+		if err != nil {
+			t.Errorf("ParseExpiry(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseExpiry(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"soon", "-1h", "31/12/2026"} {
+		if _, err := ParseExpiry(bad, now); err == nil {
+			t.Errorf("ParseExpiry(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestAddRemoveKeepsComments(t *testing.T) {
+	f, err := Parse(strings.NewReader("# work laptop\n" + testKey + "\n\nssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, _ := ParseKey(testKey)
+	k.Expires = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	if f.Add(k) {
+		t.Error("existing key should not be added again")
+	}
+	if n := f.Remove(strings.TrimPrefix(testFingerprint, "SHA256:")); n != 1 {
+		t.Errorf("removed %d keys, want 1", n)
+	}
+
+	want := "# work laptop\n\nssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+}
+
+func TestWriteReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deploy")
+	if err := os.WriteFile(path, []byte("ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := Parse(strings.NewReader(testKey + "\n"))
+	if err := Write(path, f); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := got.Keys(); len(keys) != 1 || keys[0].Comment != "laptop" {
+		t.Errorf("keys = %+v", keys)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644 so sshd's AuthorizedKeysCommandUser can read it", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp file left behind: %v", entries)
+	}
+}
+
+func TestSourceAnnotationRoundTrip(t *testing.T) {
+	line := "expires=2027-01-01T00:00:00Z source=github:alice " + testKey
+	k, err := ParseKey(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Source != "github:alice" || k.Comment != "laptop" {
+		t.Errorf("key = %+v", k)
+	}
+	if got := k.String(); got != line {
+		t.Errorf("String() = %q, want %q", got, line)
+	}
+}
+
+func TestUserAnnotationRoundTrip(t *testing.T) {
+	line := "expires=2027-01-01T00:00:00Z user=ops " + testKey
+	k, err := ParseKey(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.User != "ops" || k.Type != "ssh-ed25519" {
+		t.Errorf("key = %+v", k)
+	}
+	if got := k.String(); got != line {
+		t.Errorf("String() = %q, want %q", got, line)
+	}
+}
+
+func TestReplaceSource(t *testing.T) {
+	f, err := Parse(strings.NewReader("# by hand\nssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\nsource=github:alice ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz old\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := ParseKey(testKey)
+	manual, _ := ParseKey("ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB")
+
+	added, removed := f.ReplaceSource("github:alice", []*Key{fresh, manual})
+	if added != 1 || removed != 1 {
+		t.Errorf("added, removed = %d, %d; want 1, 1", added, removed)
+	}
+	want := "# by hand\nssh-rsa AAAAB3NzaC1yc2EAAAADAQAB desktop\nsource=github:alice " + testKey + "\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+
+	if added, removed := f.ReplaceSource("github:alice", []*Key{fresh}); added != 0 || removed != 0 {
+		t.Errorf("unchanged sync = %d added, %d removed", added, removed)
+	}
+}
+
+func TestUpdateConcurrentWritersKeepEveryKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy")
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(path, func(f *File) (bool, error) {
+				k, _ := ParseKey(fmt.Sprintf("ssh-ed25519 AAAA%d key%d", i, i))
+				return f.Add(k), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	f, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.Keys()); n != 20 {
+		t.Errorf("got %d keys, want 20: concurrent updates lost writes", n)
+	}
+}
+
+func TestUpdateUnchangedDoesNotCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy")
+	if err := Update(path, func(*File) (bool, error) { return false, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unchanged update should not create the file, stat err = %v", err)
+	}
+	if err := Delete(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete of a missing file = %v, want ErrNotExist", err)
+	}
+}
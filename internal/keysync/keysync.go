@@ -0,0 +1,205 @@
+// Package keysync refreshes users' key files from registered key
+// sources (GitHub, GitLab, or an HTTPS URL serving authorized_keys
+// lines). auth-keys never touches the network; it only reads what sync
+// wrote ahead of time.
+package keysync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/adduser"
+	"github.com/podspawn/podspawn/internal/keyfile"
+)
+
+// ParseSource checks and normalizes a source: github:<user>,
+// gitlab:<user>, or an https:// URL.
+func ParseSource(s string) (string, error) {
+	kind, name, ok := strings.Cut(s, ":")
+	switch {
+	case ok && (kind == "github" || kind == "gitlab"):
+		if name == "" || strings.ContainsAny(name, "/?# ") {
+			return "", fmt.Errorf("invalid %s username %q", kind, name)
+		}
+		return kind + ":" + name, nil
+	case kind == "https":
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid key source URL %q", s)
+		}
+		return u.String(), nil
+	}
+	return "", fmt.Errorf("invalid key source %q: use github:<user>, gitlab:<user>, or an https:// URL", s)
+}
+
+// Syncer fetches key sources into key files under KeyDir.
+type Syncer struct {
+	Client    *http.Client
+	KeyDir    string
+	GitHubURL string // default https://github.com
+	GitLabURL string // default https://gitlab.com
+
+	// Registry, if set, is re-read under the key file lock and only
+	// sources still registered for the user are applied, so a sync
+	// racing remove-user can't bring the key file back.
+	Registry func() (map[string][]string, error)
+}
+
+// Result is the outcome of syncing one source for one user.
+type Result struct {
+	User    string
+	Source  string
+	Added   int
+	Removed int
+	Err     error
+}
+
+// sourceURL maps a normalized source to the URL its keys are served at.
+func (s *Syncer) sourceURL(source string) string {
+	kind, name, _ := strings.Cut(source, ":")
+	switch kind {
+	case "github":
+		return baseOr(s.GitHubURL, "https://github.com") + "/" + name + ".keys"
+	case "gitlab":
+		return baseOr(s.GitLabURL, "https://gitlab.com") + "/" + name + ".keys"
+	}
+	return source
+}
+
+func baseOr(base, def string) string {
+	if base == "" {
+		return def
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// SyncUser refreshes each of user's sources. A source that can't be
+// fetched keeps the keys from its last successful sync, so a GitHub
+// outage doesn't lock anyone out. Keys added by hand are never touched.
+// Sources that don't pass ParseSource are reported and skipped.
+func (s *Syncer) SyncUser(ctx context.Context, user string, sources []string) ([]Result, error) {
+	if err := adduser.ValidateUsername(user); err != nil {
+		return nil, err
+	}
+
+	fetched := make(map[string][]*keyfile.Key)
+	var results []Result
+	for _, source := range sources {
+		normalized, err := ParseSource(source)
+		if err != nil {
+			results = append(results, Result{User: user, Source: source, Err: err})
+			continue
+		}
+		keys, err := s.fetch(ctx, normalized)
+		if err != nil {
+			results = append(results, Result{User: user, Source: source, Err: err})
+			continue
+		}
+		fetched[source] = keys
+	}
+	if len(fetched) == 0 {
+		return results, nil
+	}
+
+	// Fetch first, then read-modify-write, so the file is only held
+	// for as long as the rewrite takes.
+	if err := os.MkdirAll(s.KeyDir, 0755); err != nil {
+		return results, fmt.Errorf("creating key directory: %w", err)
+	}
+	err := keyfile.Update(filepath.Join(s.KeyDir, user), func(f *keyfile.File) (bool, error) {
+		registered := sources
+		if s.Registry != nil {
+			current, err := s.Registry()
+			if err != nil {
+				return false, fmt.Errorf("re-reading key sources: %w", err)
+			}
+			registered = current[user]
+		}
+		changed := false
+		for _, source := range sources {
+			keys, ok := fetched[source]
+			if !ok || !slices.Contains(registered, source) {
+				continue
+			}
+			added, removed := f.ReplaceSource(source, keys)
+			changed = changed || added > 0 || removed > 0
+			results = append(results, Result{User: user, Source: source, Added: added, Removed: removed})
+		}
+		return changed, nil
+	})
+	return results, err
+}
+
+// SyncAll syncs every user in sources, in name order.
+func (s *Syncer) SyncAll(ctx context.Context, sources map[string][]string) []Result {
+	users := make([]string, 0, len(sources))
+	for u := range sources {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	var results []Result
+	for _, u := range users {
+		res, err := s.SyncUser(ctx, u, sources[u])
+		results = append(results, res...)
+		if err != nil {
+			results = append(results, Result{User: u, Err: err})
+		}
+	}
+	return results
+}
+
+// Loop syncs on every interval until ctx is cancelled. load is called
+// each time so registry changes apply without a restart.
+func (s *Syncer) Loop(ctx context.Context, interval time.Duration, load func() (map[string][]string, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sources, err := load()
+		if err != nil {
+			slog.Error("key sync: loading sources failed", "error", err)
+		} else {
+			for _, r := range s.SyncAll(ctx, sources) {
+				if r.Err != nil {
+					slog.Warn("key sync failed", "user", r.User, "source", r.Source, "error", r.Err)
+				} else if r.Added > 0 || r.Removed > 0 {
+					slog.Info("key sync", "user", r.User, "source", r.Source, "added", r.Added, "removed", r.Removed)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) fetch(ctx context.Context, source string) ([]*keyfile.Key, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	lines, err := adduser.FetchKeys(ctx, s.Client, s.sourceURL(source))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*keyfile.Key, 0, len(lines))
+	for _, line := range lines {
+		k, err := keyfile.ParseKey(line)
+		if err != nil {
+			continue
+		}
+		// Annotations in fetched content must not be trusted
+		k.Expires, k.Source = time.Time{}, ""
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,244 @@
+package keysync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/keyfile"
+)
+
+const (
+	laptopKey  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDWizsqWrLPW+1E7N3GdnBjvwRBcVPCUhMIsDOPKm3Bl"
+	desktopKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB"
+	manualKey  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGrz"
+)
+
+// keyServer serves keys per path; a path mapped to "" returns 404.
+type keyServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func newKeyServer(t *testing.T) *keyServer {
+	t.Helper()
+	ks := &keyServer{keys: map[string]string{}}
+	ks.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ks.mu.Lock()
+		body, ok := ks.keys[r.URL.Path]
+		ks.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(ks.Close)
+	return ks
+}
+
+func (ks *keyServer) set(path, body string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[path] = body
+}
+
+func testSyncer(t *testing.T, ks *keyServer) *Syncer {
+	t.Helper()
+	return &Syncer{Client: ks.Client(), KeyDir: t.TempDir(), GitHubURL: ks.URL, GitLabURL: ks.URL + "/gitlab"}
+}
+
+func readKeys(t *testing.T, s *Syncer, user string) []*keyfile.Key {
+	t.Helper()
+	f, err := keyfile.Read(filepath.Join(s.KeyDir, user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Keys()
+}
+
+func TestParseSource(t *testing.T) {
+	valid := map[string]string{
+		"github:alice":                   "github:alice",
+		"gitlab:bob":                     "gitlab:bob",
+		"https://keys.example.com/alice": "https://keys.example.com/alice",
+	}
+	for in, want := range valid {
+		got, err := ParseSource(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSource(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"alice", "github:", "github:a/b", "http://keys.example.com/alice", "ftp://x", "https://"} {
+		if _, err := ParseSource(bad); err == nil {
+			t.Errorf("ParseSource(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestSyncReplacesSourceKeysAndKeepsManual(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	if err := os.WriteFile(filepath.Join(s.KeyDir, "alice"), []byte("# added by hand\n"+manualKey+" yubikey\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ks.set("/alice.keys", laptopKey+"\n"+desktopKey+"\n")
+	results, err := s.SyncUser(context.Background(), "alice", []string{"github:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Added != 2 || results[0].Err != nil {
+		t.Fatalf("results = %+v", results)
+	}
+
+	// Desktop key deleted on GitHub
+	ks.set("/alice.keys", laptopKey+"\n")
+	results, _ = s.SyncUser(context.Background(), "alice", []string{"github:alice"})
+	if results[0].Added != 0 || results[0].Removed != 1 {
+		t.Errorf("second sync = %+v, want one removal", results[0])
+	}
+
+	keys := readKeys(t, s, "alice")
+	if len(keys) != 2 {
+		t.Fatalf("keys = %d, want manual + laptop", len(keys))
+	}
+	if keys[0].Source != "" || keys[0].Comment != "yubikey" {
+		t.Errorf("manual key changed: %+v", keys[0])
+	}
+	if keys[1].Source != "github:alice" || !strings.HasPrefix(keys[1].AuthorizedKey(), laptopKey) {
+		t.Errorf("synced key = %+v", keys[1])
+	}
+	data, _ := os.ReadFile(filepath.Join(s.KeyDir, "alice"))
+	if !strings.HasPrefix(string(data), "# added by hand\n") {
+		t.Errorf("comments should survive sync:\n%s", data)
+	}
+}
+
+func TestSyncFailureKeepsLastKeys(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	ks.set("/gitlab/bob.keys", laptopKey+"\n")
+	if _, err := s.SyncUser(context.Background(), "bob", []string{"gitlab:bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ks.mu.Lock()
+	delete(ks.keys, "/gitlab/bob.keys")
+	ks.mu.Unlock()
+	results, err := s.SyncUser(context.Background(), "bob", []string{"gitlab:bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err == nil {
+		t.Error("expected fetch error to be reported")
+	}
+	if keys := readKeys(t, s, "bob"); len(keys) != 1 {
+		t.Errorf("keys from the last good sync should be kept, got %d", len(keys))
+	}
+}
+
+func TestSyncRejectsInvalidSource(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+
+	results, err := s.SyncUser(context.Background(), "alice", []string{"http://keys.example.com/alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("an http:// source should be reported as invalid, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(s.KeyDir, "alice")); err == nil {
+		t.Error("no key file should be written")
+	}
+}
+
+func TestSyncSkipsUnregisteredUser(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	ks.set("/alice.keys", laptopKey+"\n")
+	// remove-user dropped alice while the sync was fetching
+	s.Registry = func() (map[string][]string, error) { return map[string][]string{}, nil }
+
+	if _, err := s.SyncUser(context.Background(), "alice", []string{"github:alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(s.KeyDir, "alice")); err == nil {
+		t.Error("sync must not recreate the key file of a removed user")
+	}
+}
+
+func TestSyncDoesNotDuplicateManualKey(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	if err := os.WriteFile(filepath.Join(s.KeyDir, "alice"), []byte(laptopKey+" mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ks.set("/keys/alice", laptopKey+"\n")
+
+	if _, err := s.SyncUser(context.Background(), "alice", []string{ks.URL + "/keys/alice"}); err != nil {
+		t.Fatal(err)
+	}
+	keys := readKeys(t, s, "alice")
+	if len(keys) != 1 || keys[0].Source != "" {
+		t.Errorf("keys = %+v, want the single manual key", keys)
+	}
+}
+
+func TestSyncAllMultipleSources(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	ks.set("/alice.keys", laptopKey+"\n")
+	ks.set("/gitlab/alice.keys", desktopKey+"\n")
+	ks.set("/carol.keys", manualKey+"\n")
+
+	results := s.SyncAll(context.Background(), map[string][]string{
+		"alice": {"github:alice", "gitlab:alice"},
+		"carol": {"github:carol"},
+	})
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].User != "alice" || results[2].User != "carol" {
+		t.Errorf("results should be in user order: %+v", results)
+	}
+	if keys := readKeys(t, s, "alice"); len(keys) != 2 {
+		t.Errorf("alice keys = %d, want 2", len(keys))
+	}
+}
+
+func TestLoopSyncsImmediately(t *testing.T) {
+	ks := newKeyServer(t)
+	s := testSyncer(t, ks)
+	ks.set("/alice.keys", laptopKey+"\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Loop(ctx, time.Hour, func() (map[string][]string, error) {
+			return map[string][]string{"alice": {"github:alice"}}, nil
+		})
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(s.KeyDir, "alice")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("loop did not sync on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,157 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceAuth is an in-progress device authorization: the user visits
+// VerificationURI and enters UserCode while the client polls.
+type DeviceAuth struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// ErrAccessDenied is returned by PollToken when the user rejects the
+// request, and ErrExpired when the device code runs out first.
+var (
+	ErrAccessDenied = errors.New("authorization denied")
+	ErrExpired      = errors.New("device code expired before authorization")
+)
+
+// StartDevice begins a device authorization for clientID. The openid
+// scope is always requested since we need an ID token back.
+func (p *Provider) StartDevice(ctx context.Context, client *http.Client, clientID string, scopes []string) (*DeviceAuth, error) {
+	if p.DeviceAuthURL == "" {
+		return nil, fmt.Errorf("issuer %s does not support the device authorization grant", p.Issuer)
+	}
+	scope := "openid"
+	for _, s := range scopes {
+		if s != "openid" {
+			scope += " " + s
+		}
+	}
+	var da DeviceAuth
+	if err := postForm(ctx, client, p.DeviceAuthURL, url.Values{
+		"client_id": {clientID},
+		"scope":     {scope},
+	}, &da); err != nil {
+		return nil, fmt.Errorf("starting device authorization: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, fmt.Errorf("starting device authorization: incomplete response from %s", p.DeviceAuthURL)
+	}
+	return &da, nil
+}
+
+// PollToken polls the token endpoint until the user approves, denies,
+// or the device code expires, and returns the ID token. It polls once
+// right away, then every Interval seconds (5 if the issuer didn't say),
+// backing off on slow_down as RFC 8628 asks.
+func (p *Provider) PollToken(ctx context.Context, client *http.Client, clientID string, da *DeviceAuth) (string, error) {
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if da.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(da.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	form := url.Values{
+		"grant_type":  {deviceGrantType},
+		"device_code": {da.DeviceCode},
+		"client_id":   {clientID},
+	}
+	for {
+		var tok struct {
+			IDToken string `json:"id_token"`
+		}
+		err := postForm(ctx, client, p.TokenURL, form, &tok)
+		var oerr *oauthError
+		switch {
+		case err == nil:
+			if tok.IDToken == "" {
+				return "", fmt.Errorf("token response from %s has no id_token", p.TokenURL)
+			}
+			return tok.IDToken, nil
+		case errors.As(err, &oerr) && oerr.Code == "authorization_pending":
+		case errors.As(err, &oerr) && oerr.Code == "slow_down":
+			interval += 5 * time.Second
+		case errors.As(err, &oerr) && oerr.Code == "access_denied":
+			return "", ErrAccessDenied
+		case errors.As(err, &oerr) && oerr.Code == "expired_token":
+			return "", ErrExpired
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+			return "", ErrExpired
+		default:
+			return "", fmt.Errorf("polling for token: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", ErrExpired
+			}
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// oauthError is an RFC 6749 section 5.2 error response.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oerr oauthError
+		if json.Unmarshal(body, &oerr) == nil && oerr.Code != "" {
+			return &oerr
+		}
+		return fmt.Errorf("POST %s: %s", endpoint, resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding response from %s: %w", endpoint, err)
+	}
+	return nil
+}
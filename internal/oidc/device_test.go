@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/oidc/oidctest"
+)
+
+func startDevice(t *testing.T, iss *oidctest.Issuer) (*Provider, *DeviceAuth) {
+	t.Helper()
+	p, err := Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	da, err := p.StartDevice(context.Background(), http.DefaultClient, iss.ClientID, []string{"profile", "email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, da
+}
+
+func TestDeviceFlowApproved(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	iss.Pending = 1
+	p, da := startDevice(t, iss)
+
+	if da.UserCode == "" || da.VerificationURI == "" {
+		t.Fatalf("device auth = %+v", da)
+	}
+	tok, err := p.PollToken(context.Background(), http.DefaultClient, iss.ClientID, da)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok == "" {
+		t.Fatal("expected an ID token")
+	}
+	if iss.Polls() != 2 {
+		t.Errorf("polls = %d, want 2 (one pending, one approved)", iss.Polls())
+	}
+}
+
+func TestDeviceFlowDenied(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	iss.Deny = true
+	p, da := startDevice(t, iss)
+
+	_, err := p.PollToken(context.Background(), http.DefaultClient, iss.ClientID, da)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("err = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestDeviceFlowExpires(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	iss.Pending = 100
+	p, da := startDevice(t, iss)
+	da.ExpiresIn = 1
+
+	_, err := p.PollToken(context.Background(), http.DefaultClient, iss.ClientID, da)
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("err = %v, want ErrExpired", err)
+	}
+}
+
+func TestStartDeviceUnknownClient(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	p, err := Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.StartDevice(context.Background(), http.DefaultClient, "someone-else", nil); err == nil {
+		t.Error("expected error for an unknown client")
+	}
+}
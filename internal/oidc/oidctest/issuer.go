@@ -0,0 +1,180 @@
+// Package oidctest provides a stand-in OpenID Connect issuer for tests:
+// discovery, JWKS, the device authorization grant and ID tokens signed
+// with a throwaway RSA key.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	keyID      = "test-key"
+	deviceCode = "device-code-1"
+	userCode   = "WDJB-MJHT"
+)
+
+// Issuer is an httptest server acting as an OIDC provider. Fields may
+// be changed between requests.
+type Issuer struct {
+	*httptest.Server
+
+	ClientID string
+	Claims   map[string]any // merged into issued ID tokens; nil values remove defaults
+	Pending  int            // authorization_pending answers before approving
+	Deny     bool           // answer access_denied instead of approving
+	Interval int            // polling interval advertised, in seconds
+
+	mu          sync.Mutex
+	key         *rsa.PrivateKey
+	polls       int
+	keyRequests int
+}
+
+// NewIssuer starts an issuer that accepts clientID. Callers must Close it.
+func NewIssuer(clientID string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("oidctest: generating key: " + err.Error())
+	}
+	iss := &Issuer{ClientID: clientID, Interval: 1, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("GET /jwks", iss.jwks)
+	mux.HandleFunc("POST /device", iss.device)
+	mux.HandleFunc("POST /token", iss.token)
+	iss.Server = httptest.NewServer(mux)
+	return iss
+}
+
+// Polls returns how many token requests the issuer has answered.
+func (i *Issuer) Polls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.polls
+}
+
+// KeyRequests returns how many JWKS requests the issuer has answered.
+func (i *Issuer) KeyRequests() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.keyRequests
+}
+
+// IDToken signs an ID token with the default claims (iss, aud, sub,
+// iat, exp one hour out), then i.Claims, then extra applied on top.
+func (i *Issuer) IDToken(extra map[string]any) string {
+	now := time.Now()
+	claims := map[string]any{
+		"iss": i.URL,
+		"aud": i.ClientID,
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for _, overrides := range []map[string]any{i.Claims, extra} {
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+	}
+	return i.sign(claims)
+}
+
+func (i *Issuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic("oidctest: signing token: " + err.Error())
+	}
+	return input + "." + b64(sig)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                        i.URL,
+		"device_authorization_endpoint": i.URL + "/device",
+		"token_endpoint":                i.URL + "/token",
+		"jwks_uri":                      i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	i.keyRequests++
+	i.mu.Unlock()
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   b64(pub.N.Bytes()),
+		"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (i *Issuer) device(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("client_id") != i.ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          i.URL + "/activate",
+		"verification_uri_complete": i.URL + "/activate?user_code=" + userCode,
+		"expires_in":                600,
+		"interval":                  i.Interval,
+	})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	i.polls++
+	polls := i.polls
+	i.mu.Unlock()
+
+	switch {
+	case r.PostFormValue("grant_type") != "urn:ietf:params:oauth:grant-type:device_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+	case r.PostFormValue("device_code") != deviceCode || r.PostFormValue("client_id") != i.ClientID:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+	case i.Deny:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied"})
+	case polls <= i.Pending:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     i.IDToken(nil),
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,63 @@
+// Package oidc implements the parts of OpenID Connect podspawn needs:
+// discovery, the OAuth 2.0 device authorization grant (RFC 8628) on the
+// client, and ID token verification on the signing server.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Provider holds the endpoints from an issuer's discovery document.
+type Provider struct {
+	Issuer        string `json:"issuer"`
+	DeviceAuthURL string `json:"device_authorization_endpoint"`
+	TokenURL      string `json:"token_endpoint"`
+	JWKSURL       string `json:"jwks_uri"`
+}
+
+// Discover fetches <issuer>/.well-known/openid-configuration. The
+// document's issuer must match the one asked for, as the spec requires.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*Provider, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	var p Provider
+	if err := getJSON(ctx, client, url, &p); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", issuer, err)
+	}
+	if strings.TrimSuffix(p.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("discovering %s: document is for issuer %q", issuer, p.Issuer)
+	}
+	if p.TokenURL == "" || p.JWKSURL == "" {
+		return nil, fmt.Errorf("discovering %s: token_endpoint and jwks_uri are required", issuer)
+	}
+	return &p, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/oidc/oidctest"
+)
+
+func TestDiscover(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+
+	p, err := Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Issuer != iss.URL || p.TokenURL != iss.URL+"/token" || p.DeviceAuthURL != iss.URL+"/device" {
+		t.Errorf("provider = %+v", p)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+
+	// Same server under another name: the document names 127.0.0.1
+	alias := strings.Replace(iss.URL, "127.0.0.1", "localhost", 1)
+	_, err := Discover(context.Background(), http.DefaultClient, alias)
+	if err == nil || !strings.Contains(err.Error(), "document is for issuer") {
+		t.Errorf("expected issuer mismatch error, got %v", err)
+	}
+}
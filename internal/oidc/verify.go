@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is how far exp, nbf and iat may be off from our clock.
+const clockSkew = time.Minute
+
+// jwksRefetchInterval limits how often an unknown key id can make us
+// refetch the JWKS, so a flood of tokens with made-up kids can't turn
+// into a flood of requests to the issuer.
+const jwksRefetchInterval = time.Minute
+
+// Claims are the decoded payload of a verified ID token.
+type Claims map[string]any
+
+// String returns a string claim, or "" if it is missing or not a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Verifier checks ID tokens issued by Provider for ClientID. Signing
+// keys are fetched from the JWKS endpoint and cached; an unknown key id
+// triggers a refetch, at most once per jwksRefetchInterval, so issuer
+// key rotation needs no restart.
+type Verifier struct {
+	Provider *Provider
+	ClientID string
+	Client   *http.Client
+	Now      func() time.Time // nil = time.Now
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time // last JWKS fetch; zero = never
+}
+
+// Verify checks the token's signature (RS256 or ES256), issuer,
+// audience and validity window, and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, raw string) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decoding token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding token signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("unsupported algorithm %q for RSA key", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 {
+			return nil, fmt.Errorf("unsupported algorithm %q for EC key", header.Alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decoding token claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) checkClaims(c Claims) error {
+	if c.String("iss") != v.Provider.Issuer {
+		return fmt.Errorf("token issuer %q, want %q", c.String("iss"), v.Provider.Issuer)
+	}
+	if !audienceContains(c["aud"], v.ClientID) {
+		return fmt.Errorf("token audience does not include %q", v.ClientID)
+	}
+
+	now := v.now()
+	exp, ok := numericDate(c["exp"])
+	if !ok {
+		return errors.New("token has no exp claim")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+	if nbf, ok := numericDate(c["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return fmt.Errorf("token not valid before %s", nbf.Format(time.RFC3339))
+	}
+	if iat, ok := numericDate(c["iat"]); ok && now.Add(clockSkew).Before(iat) {
+		return errors.New("token issued in the future")
+	}
+	return nil
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []any:
+		for _, s := range a {
+			if s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key for kid, refetching the JWKS if it isn't
+// cached and the last fetch is more than jwksRefetchInterval ago.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k, ok := lookupKey(v.keys, kid); ok {
+		return k, nil
+	}
+	now := v.now()
+	if !v.fetched.IsZero() && now.Sub(v.fetched) < jwksRefetchInterval {
+		return nil, fmt.Errorf("no signing key %q in %s", kid, v.Provider.JWKSURL)
+	}
+	keys, err := fetchJWKS(ctx, v.Client, v.Provider.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = now
+	if k, ok := lookupKey(keys, kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("no signing key %q in %s", kid, v.Provider.JWKSURL)
+}
+
+// lookupKey finds kid in keys. A token without kid is fine if the
+// issuer only has one key.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if k, ok := keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, client, url, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue // keys we can't use don't matter unless a token names them
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
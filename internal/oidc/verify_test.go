@@ -0,0 +1,126 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/oidc/oidctest"
+)
+
+func newVerifier(t *testing.T, iss *oidctest.Issuer) *Verifier {
+	t.Helper()
+	p, err := Discover(context.Background(), http.DefaultClient, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{Provider: p, ClientID: iss.ClientID, Client: http.DefaultClient}
+}
+
+func TestVerifyValidToken(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	v := newVerifier(t, iss)
+
+	claims, err := v.Verify(context.Background(), iss.IDToken(map[string]any{"preferred_username": "deploy"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.String("preferred_username") != "deploy" {
+		t.Errorf("claims = %v", claims)
+	}
+}
+
+func TestVerifyAudienceList(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	v := newVerifier(t, iss)
+
+	if _, err := v.Verify(context.Background(), iss.IDToken(map[string]any{"aud": []string{"other", "podspawn"}})); err != nil {
+		t.Errorf("audience list containing the client should pass: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	other := oidctest.NewIssuer("podspawn")
+	defer other.Close()
+	v := newVerifier(t, iss)
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"expired", iss.IDToken(map[string]any{"exp": hourAgo}), "expired"},
+		{"no exp", iss.IDToken(map[string]any{"exp": nil}), "exp"},
+		{"wrong audience", iss.IDToken(map[string]any{"aud": "grafana"}), "audience"},
+		{"wrong issuer", iss.IDToken(map[string]any{"iss": "https://evil.example.com"}), "issuer"},
+		{"not yet valid", iss.IDToken(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()}), "not valid before"},
+		{"foreign signature", other.IDToken(map[string]any{"iss": iss.URL}), "signature"},
+		{"malformed", "not-a-jwt", "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tt.token)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedPayload(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	v := newVerifier(t, iss)
+
+	good := strings.Split(iss.IDToken(map[string]any{"preferred_username": "deploy"}), ".")
+	evil := strings.Split(iss.IDToken(map[string]any{"preferred_username": "root"}), ".")
+	forged := good[0] + "." + evil[1] + "." + good[2]
+	if _, err := v.Verify(context.Background(), forged); err == nil {
+		t.Error("token with swapped payload should be rejected")
+	}
+}
+
+func TestVerifyUnknownKidRefetchesAtMostOncePerInterval(t *testing.T) {
+	iss := oidctest.NewIssuer("podspawn")
+	defer iss.Close()
+	v := newVerifier(t, iss)
+	now := time.Now()
+	v.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, iss.IDToken(nil)); err != nil {
+		t.Fatal(err)
+	}
+	good := strings.SplitN(iss.IDToken(nil), ".", 2)
+	bogus := func(kid string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"`+kid+`"}`)) + "." + good[1]
+	}
+
+	for _, kid := range []string{"bogus-1", "bogus-2", "bogus-3"} {
+		if _, err := v.Verify(ctx, bogus(kid)); err == nil || !strings.Contains(err.Error(), "no signing key") {
+			t.Errorf("kid %s: err = %v, want unknown key", kid, err)
+		}
+	}
+	if got := iss.KeyRequests(); got != 1 {
+		t.Errorf("JWKS fetched %d times within the interval, want 1", got)
+	}
+
+	now = now.Add(jwksRefetchInterval)
+	if _, err := v.Verify(ctx, bogus("bogus-4")); err == nil {
+		t.Error("bogus kid should still be rejected")
+	}
+	if got := iss.KeyRequests(); got != 2 {
+		t.Errorf("JWKS fetched %d times after the interval, want 2", got)
+	}
+	if _, err := v.Verify(ctx, iss.IDToken(nil)); err != nil {
+		t.Errorf("known kid should verify from cache: %v", err)
+	}
+}
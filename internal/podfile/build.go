@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/podspawn/podspawn/internal/runtime"
 )
@@ -19,11 +22,52 @@ func ComputeTag(project string, rawBytes []byte) string {
 	return fmt.Sprintf("podspawn/%s:podfile-%x", project, h[:6])
 }
 
-// BuildImageFromPodfile builds a Docker image from a Podfile, using the
-// raw bytes for cache key computation. Returns the image tag. Skips the
+// Tag returns the image tag for the project: ComputeTag over Raw, the
+// packages.d recipes it installs from and, for a build: Podfile, the
+// contents of the build context, so changing any file docker would see
+// triggers a rebuild. Turning Sudo on changes it too.
+func (s *Source) Tag(project string) (string, error) {
+	data := append(slices.Clone(s.Raw), recipeDigest(s.Podfile)...)
+	if s.Sudo {
+		data = append(data, "\nsudo"...)
+	}
+	if s.Podfile.Build == nil {
+		return ComputeTag(project, data), nil
+	}
+	digest, err := contextDigest(s.contextDir())
+	if err != nil {
+		return "", fmt.Errorf("hashing build context: %w", err)
+	}
+	return ComputeTag(project, append(data, digest...)), nil
+}
+
+// buildPodfile is a copy of the Podfile to build from, with sudo added
+// to its packages when the dev user gets sudo.
+func (s *Source) buildPodfile() Podfile {
+	pf := *s.Podfile
+	if s.Sudo && !slices.ContainsFunc(pf.Packages, func(spec string) bool { return ParsePackage(spec).Name == "sudo" }) {
+		pf.Packages = append(slices.Clone(pf.Packages), "sudo")
+	}
+	return pf
+}
+
+func (s *Source) contextDir() string {
+	root := s.Podfile.Build.root
+	if root == "" {
+		root = s.Dir
+	}
+	return filepath.Join(root, s.Podfile.Build.Context)
+}
+
+// BuildImageFromPodfile builds the project's image: generated from the
+// Podfile, or from the repo's Dockerfile with the Podfile layered on
+// top when it has a build section. Returns the image tag. Skips the
 // build if the image already exists (cache hit).
-func BuildImageFromPodfile(ctx context.Context, rt runtime.Runtime, pf *Podfile, rawBytes []byte, project string) (string, error) {
-	tag := ComputeTag(project, rawBytes)
+func BuildImageFromPodfile(ctx context.Context, rt runtime.Runtime, src *Source, project string) (string, error) {
+	tag, err := src.Tag(project)
+	if err != nil {
+		return "", err
+	}
 
 	exists, err := rt.ImageExists(ctx, tag)
 	if err != nil {
@@ -34,7 +78,15 @@ func BuildImageFromPodfile(ctx context.Context, rt runtime.Runtime, pf *Podfile,
 		return tag, nil
 	}
 
-	dockerfile, err := Generate(pf)
+	if src.Podfile.Build != nil {
+		return tag, buildFromDockerfile(ctx, rt, src, tag)
+	}
+
+	pf := src.buildPodfile()
+	if pf.Distro == "" {
+		pf.Distro = DetectDistro(ctx, rt, pf.Base)
+	}
+	dockerfile, err := Generate(&pf)
 	if err != nil {
 		return "", fmt.Errorf("generating dockerfile: %w", err)
 	}
@@ -45,13 +97,45 @@ func BuildImageFromPodfile(ctx context.Context, rt runtime.Runtime, pf *Podfile,
 	}
 
 	slog.Info("building image", "tag", tag)
-	if err := rt.BuildImage(ctx, buildCtx, tag); err != nil {
+	if err := rt.BuildImage(ctx, buildCtx, runtime.BuildOpts{Tag: tag}); err != nil {
 		return "", fmt.Errorf("building %s: %w", tag, err)
 	}
 
 	return tag, nil
 }
 
+func buildFromDockerfile(ctx context.Context, rt runtime.Runtime, src *Source, tag string) error {
+	b := src.Podfile.Build
+	dir := src.contextDir()
+	data, err := os.ReadFile(filepath.Join(dir, b.Dockerfile))
+	if err != nil {
+		return fmt.Errorf("reading dockerfile: %w", err)
+	}
+	pf := src.buildPodfile()
+	if pf.Distro == "" {
+		if image, ok := dockerfileBaseImage(string(data), b.Target); ok {
+			pf.Distro = DetectDistro(ctx, rt, image)
+		} else {
+			slog.Warn("can't tell which image the dockerfile builds on, assuming debian; set distro: in the podfile", "dockerfile", b.Dockerfile)
+			pf.Distro = DistroDebian
+		}
+	}
+	dockerfile, err := layerDockerfile(string(data), b.Target, &pf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.Dockerfile, err)
+	}
+
+	buildCtx := createDirContext(dir, dockerfile)
+	defer buildCtx.Close() //nolint:errcheck
+
+	slog.Info("building image from repo dockerfile", "tag", tag, "dockerfile", b.Dockerfile, "context", b.Context)
+	opts := runtime.BuildOpts{Tag: tag, Dockerfile: generatedDockerfile, Args: b.Args}
+	if err := rt.BuildImage(ctx, buildCtx, opts); err != nil {
+		return fmt.Errorf("building %s: %w", tag, err)
+	}
+	return nil
+}
+
 func createBuildContext(dockerfile string) (io.Reader, error) {
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
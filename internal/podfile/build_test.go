@@ -1,9 +1,12 @@
 package podfile
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/podspawn/podspawn/internal/runtime"
@@ -41,7 +44,7 @@ func TestBuildImageCacheHit(t *testing.T) {
 	rt.Images[tag] = true
 
 	pf := &Podfile{Base: "ubuntu:24.04", Shell: "/bin/bash"}
-	got, err := BuildImageFromPodfile(context.Background(), rt, pf, raw, "myproject")
+	got, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: raw}, "myproject")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,7 +61,7 @@ func TestBuildImageCacheMiss(t *testing.T) {
 	raw := []byte("base: ubuntu:24.04\n")
 	pf := &Podfile{Base: "ubuntu:24.04", Shell: "/bin/bash"}
 
-	tag, err := BuildImageFromPodfile(context.Background(), rt, pf, raw, "myproject")
+	tag, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: raw}, "myproject")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -73,14 +76,112 @@ func TestBuildImageCacheMiss(t *testing.T) {
 	}
 }
 
+func TestBuildImageInstallsSudo(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	raw := []byte("base: ubuntu:24.04\n")
+	pf := &Podfile{Base: "ubuntu:24.04", Distro: DistroDebian, Shell: "/bin/bash", Packages: []string{"jq"}}
+
+	tag, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: raw, Sudo: true}, "myproject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := tarNames(t, bytes.NewReader(rt.BuildContexts[0]))
+	if !strings.Contains(files["Dockerfile"], "    sudo \\\n") {
+		t.Errorf("dockerfile should install sudo:\n%s", files["Dockerfile"])
+	}
+	if len(pf.Packages) != 1 {
+		t.Error("sudo should not be added to the caller's podfile")
+	}
+	if without, _ := (&Source{Podfile: pf, Raw: raw}).Tag("myproject"); tag == without {
+		t.Error("turning sudo on should change the tag")
+	}
+}
+
 func TestBuildImageError(t *testing.T) {
 	rt := runtime.NewFakeRuntime()
 	rt.BuildErr = fmt.Errorf("disk full")
 	raw := []byte("base: ubuntu:24.04\n")
 	pf := &Podfile{Base: "ubuntu:24.04", Shell: "/bin/bash"}
 
-	_, err := BuildImageFromPodfile(context.Background(), rt, pf, raw, "myproject")
+	_, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: raw}, "myproject")
 	if err == nil {
 		t.Fatal("expected error")
 	}
 }
+
+func TestBuildImageFromRepoDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "docker", "Dockerfile"), "FROM python:3.12\nCOPY . /src\n")
+	writeFile(t, filepath.Join(dir, "docker", "app.py"), "print('hi')\n")
+	writeFile(t, filepath.Join(dir, "podfile.yaml"), "build:\n  context: docker\n  args: {PYTHON: \"3.12\"}\n")
+
+	src, err := Load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := runtime.NewFakeRuntime()
+	tag, err := BuildImageFromPodfile(context.Background(), rt, src, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.Builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(rt.Builds))
+	}
+	opts := rt.Builds[0]
+	if opts.Tag != tag || opts.Dockerfile != generatedDockerfile || opts.Args["PYTHON"] != "3.12" {
+		t.Errorf("build opts = %+v", opts)
+	}
+	files := tarNames(t, bytes.NewReader(rt.BuildContexts[0]))
+	if _, ok := files["app.py"]; !ok {
+		t.Errorf("context should hold the repo's files, got %v", files)
+	}
+	if !strings.Contains(files[generatedDockerfile], "FROM python:3.12 AS podspawn-base") {
+		t.Errorf("generated dockerfile:\n%s", files[generatedDockerfile])
+	}
+
+	// Same podfile, edited context: new tag
+	writeFile(t, filepath.Join(dir, "docker", "app.py"), "print('bye')\n")
+	newTag, err := src.Tag("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTag == tag {
+		t.Error("tag should change with the build context")
+	}
+}
+
+func TestBuildImageDetectsDistro(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	rt.ExecStdout = "NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.20.0\n"
+	pf := &Podfile{Base: "alpine:3.20", Shell: "/bin/bash", Packages: []string{"ripgrep"}}
+
+	if _, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: []byte("base: alpine:3.20\n")}, "tiny"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.ExecCalls) != 1 || strings.Join(rt.ExecCalls[0].Opts.Cmd, " ") != "cat /etc/os-release" {
+		t.Fatalf("expected one os-release read, got %+v", rt.ExecCalls)
+	}
+	if len(rt.Containers) != 0 {
+		t.Errorf("detection container should be removed, have %v", rt.Containers)
+	}
+	files := tarNames(t, bytes.NewReader(rt.BuildContexts[0]))
+	if !strings.Contains(files["Dockerfile"], "RUN apk add --no-cache \\\n    ripgrep\n") {
+		t.Errorf("expected apk install:\n%s", files["Dockerfile"])
+	}
+	if pf.Distro != "" {
+		t.Error("detection should not modify the caller's podfile")
+	}
+}
+
+func TestBuildImageExplicitDistro(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	pf := &Podfile{Base: "registry.access.redhat.com/ubi9/ubi", Distro: DistroFedora, Shell: "/bin/bash"}
+
+	if _, err := BuildImageFromPodfile(context.Background(), rt, &Source{Podfile: pf, Raw: []byte("distro: fedora\n")}, "ubi"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.ExecCalls) != 0 {
+		t.Errorf("distro: should skip detection, got %d execs", len(rt.ExecCalls))
+	}
+}
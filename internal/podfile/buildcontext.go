@@ -0,0 +1,174 @@
+package podfile
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// generatedDockerfile is the name the layered Dockerfile gets in the
+// build context, so it doesn't clash with the repo's own.
+const generatedDockerfile = ".podspawn.Dockerfile"
+
+// walkContext calls fn for each file and directory under dir that
+// docker build would send, in lexical order, skipping what the
+// context's .dockerignore excludes.
+func walkContext(dir string, fn func(rel, path string, d fs.DirEntry) error) error {
+	pm, err := loadDockerignore(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if pm != nil {
+			skip, err := pm.MatchesOrParentMatches(rel)
+			if err != nil {
+				return err
+			}
+			if skip {
+				// With ! exceptions, something below may be let back in
+				if d.IsDir() && !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		return fn(rel, path, d)
+	})
+}
+
+func loadDockerignore(dir string) (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading .dockerignore: %w", err)
+	}
+	pm, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("parsing .dockerignore: %w", err)
+	}
+	return pm, nil
+}
+
+// contextDigest hashes the paths, modes and contents of everything in
+// the build context.
+func contextDigest(dir string) ([]byte, error) {
+	h := sha256.New()
+	err := walkContext(dir, func(rel, path string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%o\x00", rel, info.Mode()) //nolint:errcheck // hash writes don't fail
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, target) //nolint:errcheck
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close() //nolint:errcheck // read-only file
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		h.Write([]byte{0}) //nolint:errcheck
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// createDirContext tars the build context in dir, plus dockerfile as
+// generatedDockerfile. The tar is streamed; close the reader once the
+// build is done so the writer stops if the build ended early.
+func createDirContext(dir, dockerfile string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDirContext(pw, dir, dockerfile)) //nolint:errcheck
+	}()
+	return pr
+}
+
+func writeDirContext(w io.Writer, dir, dockerfile string) error {
+	tw := tar.NewWriter(w)
+	err := walkContext(dir, func(rel, path string, d fs.DirEntry) error {
+		if rel == generatedDockerfile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // sockets, devices: docker skips them too
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() && !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close() //nolint:errcheck // read-only file
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("reading build context: %w", err)
+	}
+	hdr := &tar.Header{Name: generatedDockerfile, Mode: 0644, Size: int64(len(dockerfile))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, dockerfile); err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,81 @@
+package podfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func tarNames(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+}
+
+func TestCreateDirContextHonoursDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".dockerignore"), "# build output\nnode_modules\n*.log\nsecrets/\n!secrets/public.pem\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "debug.log"), "noise")
+	writeFile(t, filepath.Join(dir, "node_modules", "x", "index.js"), "x")
+	writeFile(t, filepath.Join(dir, "secrets", "key.pem"), "private")
+	writeFile(t, filepath.Join(dir, "secrets", "public.pem"), "public")
+
+	rc := createDirContext(dir, "FROM scratch\n")
+	defer rc.Close() //nolint:errcheck
+	files := tarNames(t, rc)
+
+	for _, want := range []string{"main.go", ".dockerignore", "secrets/public.pem", generatedDockerfile} {
+		if _, ok := files[want]; !ok {
+			t.Errorf("%s missing from context", want)
+		}
+	}
+	for _, unwanted := range []string{"debug.log", "node_modules/", "node_modules/x/index.js", "secrets/key.pem"} {
+		if _, ok := files[unwanted]; ok {
+			t.Errorf("%s should be excluded by .dockerignore", unwanted)
+		}
+	}
+	if files[generatedDockerfile] != "FROM scratch\n" {
+		t.Errorf("generated dockerfile = %q", files[generatedDockerfile])
+	}
+}
+
+func TestContextDigest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".dockerignore"), "*.log\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	digest := func() []byte {
+		t.Helper()
+		d, err := contextDigest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	first := digest()
+
+	writeFile(t, filepath.Join(dir, "debug.log"), "noise")
+	if !bytes.Equal(first, digest()) {
+		t.Error("ignored files should not change the digest")
+	}
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main // changed\n")
+	if slices.Equal(first, digest()) {
+		t.Error("editing a context file should change the digest")
+	}
+}
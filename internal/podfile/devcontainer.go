@@ -0,0 +1,433 @@
+package podfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// devcontainerIgnored are keys that only matter to editors or are
+// metadata, so dropping them isn't worth a warning.
+var devcontainerIgnored = map[string]bool{
+	"$schema":        true,
+	"name":           true,
+	"customizations": true,
+}
+
+// devcontainerFeatures maps features from ghcr.io/devcontainers/features
+// to a Podfile package, given the feature's "version" option ("" when
+// unset). An empty result means the version can't be expressed.
+var devcontainerFeatures = map[string]func(version string) string{
+	"node": func(v string) string {
+		if isNumericVersion(v) {
+			return "nodejs@" + v
+		}
+		return "nodejs"
+	},
+	"python": func(v string) string {
+		if isNumericVersion(v) {
+			return "python@" + v
+		}
+		return "python3"
+	},
+	"go": func(v string) string {
+		if isNumericVersion(v) {
+			return "go@" + v
+		}
+		return ""
+	},
+	"rust": func(v string) string {
+		if v == "" || v == "latest" {
+			v = "stable"
+		}
+		return "rust@" + v
+	},
+	"git": func(string) string { return "git" },
+}
+
+var numericVersion = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+func isNumericVersion(v string) bool {
+	return numericVersion.MatchString(v)
+}
+
+// FromDevcontainer translates a devcontainer.json (JSON with comments)
+// into a Podfile. The image or Dockerfile build, environment, lifecycle
+// commands, forwarded ports, host requirements and the common
+// devcontainers features carry over; everything else comes back as a
+// warning, one per key. dir is the directory holding the file, relative
+// to the repo root, which the build paths are relative to. The returned
+// bytes are the Podfile as YAML, for ComputeTag.
+func FromDevcontainer(data []byte, dir string) (*Podfile, []byte, []string, error) {
+	var dc map[string]json.RawMessage
+	if err := json.Unmarshal(stripJSONC(data), &dc); err != nil {
+		return nil, nil, nil, fmt.Errorf("decoding devcontainer.json: %w", err)
+	}
+
+	t := &devcontainerTranslator{pf: &Podfile{}, dir: dir}
+	for _, k := range sortedRawKeys(dc) {
+		if err := t.translate(k, dc[k]); err != nil {
+			return nil, nil, nil, fmt.Errorf("devcontainer.json %q: %w", k, err)
+		}
+	}
+
+	pf := t.pf
+	if t.build != nil {
+		if pf.Base != "" {
+			t.warn("both \"image\" and a Dockerfile build are set, using the image")
+		} else if err := t.applyBuild(); err != nil {
+			return nil, nil, nil, fmt.Errorf("devcontainer.json build: %w", err)
+		}
+	}
+	if pf.Base == "" && pf.Build == nil {
+		return nil, nil, nil, fmt.Errorf("devcontainer.json has no \"image\" or Dockerfile build")
+	}
+	pf.OnCreate = joinScripts(t.onCreate, t.postCreate)
+
+	raw, err := yaml.Marshal(pf)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encoding translated podfile: %w", err)
+	}
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("translated devcontainer.json: %w", err)
+	}
+	return parsed, raw, t.warnings, nil
+}
+
+type devcontainerTranslator struct {
+	pf                   *Podfile
+	dir                  string
+	build                *devcontainerBuild
+	onCreate, postCreate string
+	warnings             []string
+}
+
+// devcontainerBuild is the build section, with dockerfile and context
+// relative to devcontainer.json.
+type devcontainerBuild struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+	Target     string            `json:"target"`
+}
+
+func (t *devcontainerTranslator) warn(format string, args ...any) {
+	t.warnings = append(t.warnings, "devcontainer.json: "+fmt.Sprintf(format, args...))
+}
+
+func (t *devcontainerTranslator) translate(key string, raw json.RawMessage) error {
+	var err error
+	switch key {
+	case "image":
+		err = json.Unmarshal(raw, &t.pf.Base)
+	case "build":
+		err = t.buildSection(raw)
+	case "dockerFile", "context":
+		// Pre-"build" spelling of build.dockerfile and build.context
+		var v string
+		if err = json.Unmarshal(raw, &v); err == nil {
+			if t.build == nil {
+				t.build = &devcontainerBuild{}
+			}
+			if key == "dockerFile" {
+				t.build.Dockerfile = v
+			} else {
+				t.build.Context = v
+			}
+		}
+	case "containerEnv", "remoteEnv":
+		err = t.env(key, raw)
+	case "onCreateCommand":
+		t.onCreate, err = lifecycleScript(raw)
+	case "postCreateCommand":
+		t.postCreate, err = lifecycleScript(raw)
+	case "postStartCommand":
+		t.warn("%q is not supported (on_start runs on every connection), ignored", key)
+	case "forwardPorts":
+		err = t.ports(raw)
+	case "features":
+		err = t.features(raw)
+	case "hostRequirements":
+		err = t.hostRequirements(raw)
+	default:
+		if !devcontainerIgnored[key] {
+			t.warn("%q is not supported, ignored", key)
+		}
+	}
+	return err
+}
+
+func (t *devcontainerTranslator) buildSection(raw json.RawMessage) error {
+	var section map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &section); err != nil {
+		return err
+	}
+	if t.build == nil {
+		t.build = &devcontainerBuild{}
+	}
+	for _, k := range sortedRawKeys(section) {
+		var err error
+		switch k {
+		case "dockerfile":
+			err = json.Unmarshal(section[k], &t.build.Dockerfile)
+		case "context":
+			err = json.Unmarshal(section[k], &t.build.Context)
+		case "args":
+			err = json.Unmarshal(section[k], &t.build.Args)
+		case "target":
+			err = json.Unmarshal(section[k], &t.build.Target)
+		default:
+			t.warn("build.%s is not supported, ignored", k)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// applyBuild turns the build section into the Podfile's, whose context
+// is relative to the repo root and dockerfile relative to the context.
+func (t *devcontainerTranslator) applyBuild() error {
+	b := t.build
+	if b.Dockerfile == "" {
+		return fmt.Errorf("dockerfile is required")
+	}
+	contextDir := path.Join(t.dir, b.Context)
+	dockerfile, err := filepath.Rel(contextDir, path.Join(t.dir, b.Dockerfile))
+	if err != nil || !insideDir(dockerfile) {
+		return fmt.Errorf("dockerfile %q is outside the build context", b.Dockerfile)
+	}
+	t.pf.Build = &BuildConfig{
+		Context:    contextDir,
+		Dockerfile: filepath.ToSlash(dockerfile),
+		Args:       b.Args,
+		Target:     b.Target,
+	}
+	return nil
+}
+
+// env copies variables into Env. Values using devcontainer's ${...}
+// substitutions have no equivalent and are dropped.
+func (t *devcontainerTranslator) env(key string, raw json.RawMessage) error {
+	var env map[string]string
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(env) {
+		v := env[name]
+		if strings.Contains(v, "${") {
+			t.warn("%s.%s uses a variable substitution, ignored", key, name)
+			continue
+		}
+		if t.pf.Env == nil {
+			t.pf.Env = make(map[string]string)
+		}
+		t.pf.Env[name] = v
+	}
+	return nil
+}
+
+// ports keeps numeric ports; "host:port" entries point at other
+// containers, which podspawn doesn't forward to.
+func (t *devcontainerTranslator) ports(raw json.RawMessage) error {
+	var ports []any
+	if err := json.Unmarshal(raw, &ports); err != nil {
+		return err
+	}
+	for _, p := range ports {
+		if n, ok := p.(float64); ok && n == float64(int(n)) && n > 0 {
+			t.pf.Ports.Expose = append(t.pf.Ports.Expose, int(n))
+			continue
+		}
+		t.warn("forwardPorts entry %v is not supported, ignored", p)
+	}
+	return nil
+}
+
+func (t *devcontainerTranslator) features(raw json.RawMessage) error {
+	var features map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &features); err != nil {
+		return err
+	}
+	for _, id := range sortedRawKeys(features) {
+		var opts struct {
+			Version any `json:"version"`
+		}
+		_ = json.Unmarshal(features[id], &opts) // options are optional, and may be a bare string
+		version := ""
+		if opts.Version != nil {
+			version = fmt.Sprint(opts.Version)
+		}
+
+		name, ok := strings.CutPrefix(id, "ghcr.io/devcontainers/features/")
+		if i := strings.IndexAny(name, ":@"); i >= 0 {
+			name = name[:i]
+		}
+		mapFeature := devcontainerFeatures[name]
+		if !ok || mapFeature == nil {
+			t.warn("feature %s is not supported, ignored", id)
+			continue
+		}
+		pkg := mapFeature(version)
+		if pkg == "" {
+			t.warn("feature %s version %q is not supported, ignored", id, version)
+			continue
+		}
+		t.pf.Packages = append(t.pf.Packages, pkg)
+	}
+	return nil
+}
+
+func (t *devcontainerTranslator) hostRequirements(raw json.RawMessage) error {
+	var req map[string]any
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return err
+	}
+	for _, k := range sortedAnyKeys(req) {
+		switch v := req[k]; {
+		case k == "cpus":
+			if n, ok := v.(float64); ok {
+				t.pf.Resources.CPUs = n
+				continue
+			}
+		case k == "memory":
+			// devcontainer sizes are "8gb"; ParseMemory wants "8g"
+			if s, ok := v.(string); ok {
+				t.pf.Resources.Memory = strings.TrimSuffix(strings.ToLower(s), "b")
+				continue
+			}
+		}
+		t.warn("hostRequirements.%s is not supported, ignored", k)
+	}
+	return nil
+}
+
+// lifecycleScript turns a lifecycle command into a shell script. It can
+// be a string (run by a shell), an array (run directly) or an object of
+// named commands, which devcontainers run in parallel and we run in turn.
+func lifecycleScript(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var argv []string
+	if err := json.Unmarshal(raw, &argv); err == nil {
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = ShellQuote(a)
+		}
+		return strings.Join(quoted, " "), nil
+	}
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &named); err != nil {
+		return "", fmt.Errorf("must be a string, an array or an object")
+	}
+	var scripts []string
+	for _, n := range sortedRawKeys(named) {
+		script, err := lifecycleScript(named[n])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", n, err)
+		}
+		scripts = append(scripts, script)
+	}
+	return joinScripts(scripts...), nil
+}
+
+func joinScripts(scripts ...string) string {
+	var nonEmpty []string
+	for _, s := range scripts {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return strings.Join(nonEmpty, "\n")
+}
+
+func sortedRawKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedAnyKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas, which
+// devcontainer.json allows and encoding/json doesn't. String contents
+// are left alone.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			start := i
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			out = append(out, data[start:min(i+1, len(data))]...)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == ',':
+			if next := nextSignificant(data[i+1:]); next == '}' || next == ']' {
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// nextSignificant returns the next byte in data that isn't whitespace
+// or inside a comment, or 0 at the end.
+func nextSignificant(data []byte) byte {
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return 0
+			}
+			i += end + 3
+		default:
+			return c
+		}
+	}
+	return 0
+}
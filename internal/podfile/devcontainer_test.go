@@ -0,0 +1,167 @@
+package podfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFromDevcontainer(t *testing.T) {
+	input := `{
+	// comments and trailing commas are allowed
+	"name": "backend",
+	"image": "mcr.microsoft.com/devcontainers/base:ubuntu",
+	"containerEnv": {"DB_HOST": "localhost", "HOME_DIR": "${localEnv:HOME}"},
+	"remoteEnv": {"EDITOR": "vim", "DB_HOST": "db"},
+	"onCreateCommand": "make deps",
+	"postCreateCommand": ["npm", "install", "--prefix", "my app"],
+	"postStartCommand": {"server": "make serve", "watch": "make watch"},
+	"forwardPorts": [3000, "db:5432"],
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": {"version": "20"},
+		"ghcr.io/devcontainers/features/go:1": {"version": "latest"},
+		"ghcr.io/devcontainers/features/git:1": {},
+		"ghcr.io/example/features/thing:1": {},
+	},
+	"hostRequirements": {"cpus": 4, "memory": "8gb", "gpu": true},
+	"mounts": ["source=/tmp,target=/tmp,type=bind"],
+	/* editor settings */
+	"customizations": {"vscode": {"extensions": ["golang.go"]}},
+}`
+	pf, raw, warnings, err := FromDevcontainer([]byte(input), ".devcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pf.Base != "mcr.microsoft.com/devcontainers/base:ubuntu" {
+		t.Errorf("base = %q", pf.Base)
+	}
+	if pf.Shell != "/bin/bash" {
+		t.Errorf("shell = %q, want the Parse default", pf.Shell)
+	}
+	if pf.Env["DB_HOST"] != "db" || pf.Env["EDITOR"] != "vim" {
+		t.Errorf("env = %v, want remoteEnv to win", pf.Env)
+	}
+	if _, ok := pf.Env["HOME_DIR"]; ok {
+		t.Error("variable substitution should be dropped")
+	}
+	if want := "make deps\nnpm install --prefix 'my app'"; pf.OnCreate != want {
+		t.Errorf("on_create = %q, want %q", pf.OnCreate, want)
+	}
+	if pf.OnStart != "" {
+		t.Errorf("on_start = %q, postStartCommand should not run on every connection", pf.OnStart)
+	}
+	if !slices.Equal(pf.Ports.Expose, []int{3000}) {
+		t.Errorf("ports = %v, want [3000]", pf.Ports.Expose)
+	}
+	if !slices.Equal(pf.Packages, []string{"git", "nodejs@20"}) {
+		t.Errorf("packages = %v, want [git nodejs@20]", pf.Packages)
+	}
+	if pf.Resources.CPUs != 4 || pf.Resources.Memory != "8g" {
+		t.Errorf("resources = %+v, want 4 cpus and 8g", pf.Resources)
+	}
+	if !strings.Contains(string(raw), "base: mcr.microsoft.com/devcontainers/base:ubuntu") {
+		t.Errorf("raw should be the translated podfile, got:\n%s", raw)
+	}
+
+	for _, want := range []string{
+		`containerEnv.HOME_DIR`,
+		`forwardPorts entry db:5432`,
+		`feature ghcr.io/devcontainers/features/go:1 version "latest"`,
+		`feature ghcr.io/example/features/thing:1`,
+		`hostRequirements.gpu`,
+		`"mounts" is not supported`,
+		`"postStartCommand" is not supported`,
+	} {
+		if !slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, want) }) {
+			t.Errorf("no warning mentioning %s in %q", want, warnings)
+		}
+	}
+	if len(warnings) != 7 {
+		t.Errorf("got %d warnings, want 7: %q", len(warnings), warnings)
+	}
+}
+
+func TestFromDevcontainerBuild(t *testing.T) {
+	input := `{"build": {"dockerfile": "Dockerfile", "context": "..", "args": {"VARIANT": "3.12"}, "target": "dev", "cacheFrom": "x"}}`
+	pf, _, warnings, err := FromDevcontainer([]byte(input), ".devcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BuildConfig{Context: ".", Dockerfile: ".devcontainer/Dockerfile", Args: map[string]string{"VARIANT": "3.12"}, Target: "dev"}
+	if pf.Build == nil || pf.Build.Context != want.Context || pf.Build.Dockerfile != want.Dockerfile ||
+		pf.Build.Target != want.Target || pf.Build.Args["VARIANT"] != "3.12" {
+		t.Errorf("build = %+v, want %+v", pf.Build, want)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "build.cacheFrom") {
+		t.Errorf("warnings = %q, want one for build.cacheFrom", warnings)
+	}
+
+	// Legacy top-level keys, without a context: relative to the file
+	pf, _, _, err = FromDevcontainer([]byte(`{"dockerFile": "Dockerfile"}`), ".devcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.Build.Context != ".devcontainer" || pf.Build.Dockerfile != "Dockerfile" {
+		t.Errorf("build = %+v", pf.Build)
+	}
+
+	_, _, _, err = FromDevcontainer([]byte(`{"build": {"dockerfile": "../Dockerfile"}}`), ".devcontainer")
+	if err == nil || !strings.Contains(err.Error(), "outside the build context") {
+		t.Errorf("expected dockerfile outside context error, got %v", err)
+	}
+}
+
+func TestFromDevcontainerRequiresImage(t *testing.T) {
+	_, _, _, err := FromDevcontainer([]byte(`{"dockerComposeFile": "compose.yml"}`), ".devcontainer")
+	if err == nil || !strings.Contains(err.Error(), `no "image"`) {
+		t.Errorf("expected missing image error, got %v", err)
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	input := `{"url": "http://example.com/*x*/", // trailing
+	"list": [1, 2, /* c */ ], "s": "a,}"}`
+	got := string(stripJSONC([]byte(input)))
+	want := `{"url": "http://example.com/*x*/", 
+	"list": [1, 2  ], "s": "a,}"}`
+	if got != want {
+		t.Errorf("stripJSONC =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoadFallsBackToDevcontainer(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(dir, nil); err == nil || !strings.Contains(err.Error(), "devcontainer.json") {
+		t.Fatalf("expected not-found error mentioning devcontainer.json, got %v", err)
+	}
+
+	dcDir := filepath.Join(dir, ".devcontainer")
+	if err := os.MkdirAll(dcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{"image": "node:22", "runArgs": ["--init"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Podfile.Base != "node:22" || len(src.Warnings) != 1 {
+		t.Errorf("devcontainer source = %+v", src)
+	}
+
+	// A podfile.yaml wins over devcontainer.json
+	if err := os.WriteFile(filepath.Join(dir, "podfile.yaml"), []byte("base: ubuntu:24.04\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err = Load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Podfile.Base != "ubuntu:24.04" || string(src.Raw) != "base: ubuntu:24.04\n" {
+		t.Errorf("podfile source = %+v", src)
+	}
+}
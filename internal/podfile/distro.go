@@ -0,0 +1,165 @@
+package podfile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+// Distro families, named after the distribution that defines each
+// package manager's conventions.
+const (
+	DistroDebian = "debian" // apt: Debian, Ubuntu
+	DistroAlpine = "alpine" // apk
+	DistroFedora = "fedora" // dnf: Fedora, RHEL, UBI, Rocky, Alma, CentOS Stream
+	DistroArch   = "arch"   // pacman
+)
+
+// distroFamily describes how to install packages on a family.
+type distroFamily struct {
+	install  string // command taking package names
+	cleanup  string // run after install to keep layers small; may be empty
+	lean     string // install flag skipping optional dependencies
+	sftpPkg  string // package providing sftp-server
+	sftpPath string // where that package puts it
+	pin      func(name, version string) (string, error)
+}
+
+var distroFamilies = map[string]distroFamily{
+	DistroDebian: {
+		install:  "apt-get update && apt-get install -y",
+		cleanup:  "rm -rf /var/lib/apt/lists/*",
+		lean:     "--no-install-recommends",
+		sftpPkg:  "openssh-sftp-server",
+		sftpPath: "/usr/lib/openssh/sftp-server",
+		pin:      func(n, v string) (string, error) { return n + "=" + v + "*", nil },
+	},
+	DistroAlpine: {
+		install:  "apk add --no-cache",
+		sftpPkg:  "openssh-sftp-server",
+		sftpPath: "/usr/lib/ssh/sftp-server",
+		pin:      func(n, v string) (string, error) { return n + "=~" + v, nil },
+	},
+	DistroFedora: {
+		install:  "dnf install -y",
+		cleanup:  "dnf clean all",
+		lean:     "--setopt=install_weak_deps=False",
+		sftpPkg:  "openssh-server",
+		sftpPath: "/usr/libexec/openssh/sftp-server",
+		pin:      func(n, v string) (string, error) { return n + "-" + v + "*", nil },
+	},
+	DistroArch: {
+		install:  "pacman -Sy --noconfirm --needed",
+		cleanup:  "rm -rf /var/cache/pacman/pkg/*",
+		sftpPkg:  "openssh",
+		sftpPath: "/usr/lib/ssh/sftp-server",
+		pin: func(n, v string) (string, error) {
+			return "", fmt.Errorf("pacman can't install a specific version of %s (asked for %s)", n, v)
+		},
+	},
+}
+
+// osReleaseIDs maps os-release ID values to families.
+var osReleaseIDs = map[string]string{
+	"debian": DistroDebian, "ubuntu": DistroDebian,
+	"alpine": DistroAlpine,
+	"fedora": DistroFedora, "rhel": DistroFedora, "centos": DistroFedora,
+	"rocky": DistroFedora, "almalinux": DistroFedora, "ol": DistroFedora, "amzn": DistroFedora,
+	"arch": DistroArch, "archarm": DistroArch, "manjaro": DistroArch,
+}
+
+func validDistro(d string) bool {
+	_, ok := distroFamilies[d]
+	return ok
+}
+
+// distroOrDefault is d, or Debian when unset, which is what podspawn
+// assumed before distros were detected.
+func distroOrDefault(d string) string {
+	if d == "" {
+		return DistroDebian
+	}
+	return d
+}
+
+// DistroFromOSRelease returns the family named by an /etc/os-release
+// file's ID, falling back to its ID_LIKE list.
+func DistroFromOSRelease(data []byte) (string, bool) {
+	fields := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if ok {
+			fields[k] = strings.Trim(v, `"'`)
+		}
+	}
+	candidates := append([]string{fields["ID"]}, strings.Fields(fields["ID_LIKE"])...)
+	for _, id := range candidates {
+		if d, ok := osReleaseIDs[strings.ToLower(id)]; ok {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// DetectDistro reads /etc/os-release from image in a throwaway
+// container. Images it can't identify (no os-release, no cat, an
+// unknown ID) are treated as Debian, with a warning.
+func DetectDistro(ctx context.Context, rt runtime.Runtime, image string) string {
+	d, err := detectDistro(ctx, rt, image)
+	if err != nil {
+		slog.Warn("could not detect distro, assuming debian; set distro: in the podfile", "image", image, "error", err)
+		return DistroDebian
+	}
+	slog.Info("detected distro", "image", image, "distro", d)
+	return d
+}
+
+func detectDistro(ctx context.Context, rt runtime.Runtime, image string) (string, error) {
+	// Random, so concurrent builds of the same image don't collide
+	var suffix [6]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("naming container: %w", err)
+	}
+	name := "podspawn-distro-" + hex.EncodeToString(suffix[:])
+	// The image's ENTRYPOINT would wrap or ignore our command
+	if _, err := rt.CreateContainer(ctx, runtime.ContainerOpts{
+		Name:       name,
+		Image:      image,
+		Entrypoint: []string{"sleep"},
+		Cmd:        []string{"30"},
+	}); err != nil {
+		return "", fmt.Errorf("creating container from %s: %w", image, err)
+	}
+	defer func() {
+		_ = rt.RemoveContainer(context.Background(), name)
+	}()
+	if err := rt.StartContainer(ctx, name); err != nil {
+		return "", fmt.Errorf("starting container: %w", err)
+	}
+
+	var out bytes.Buffer
+	exitCode, err := rt.Exec(ctx, name, runtime.ExecOpts{
+		Cmd:    []string{"cat", "/etc/os-release"},
+		Stdout: &out,
+		Stderr: &out,
+	})
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("reading /etc/os-release exited %d", exitCode)
+	}
+	d, ok := DistroFromOSRelease(out.Bytes())
+	if !ok {
+		return "", fmt.Errorf("unrecognized /etc/os-release")
+	}
+	return d, nil
+}
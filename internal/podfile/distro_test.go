@@ -0,0 +1,68 @@
+package podfile
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+func TestDistroFromOSRelease(t *testing.T) {
+	tests := []struct {
+		osRelease string
+		want      string
+	}{
+		{"ID=ubuntu\nID_LIKE=debian\n", DistroDebian},
+		{"ID=alpine\n", DistroAlpine},
+		{"ID=\"rhel\"\nID_LIKE=\"fedora\"\n", DistroFedora},
+		{"ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n", DistroFedora},
+		{"ID=arch\n", DistroArch},
+		{"ID=pop\nID_LIKE=\"ubuntu debian\"\n", DistroDebian},
+	}
+	for _, tt := range tests {
+		got, ok := DistroFromOSRelease([]byte(tt.osRelease))
+		if !ok || got != tt.want {
+			t.Errorf("DistroFromOSRelease(%q) = %q, %v; want %q", tt.osRelease, got, ok, tt.want)
+		}
+	}
+
+	if d, ok := DistroFromOSRelease([]byte("ID=gentoo\n")); ok {
+		t.Errorf("gentoo should be unknown, got %q", d)
+	}
+}
+
+func TestDetectDistroOverridesEntrypoint(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	rt.ExecStdout = "ID=alpine\n"
+	if got := DetectDistro(context.Background(), rt, "postgres:16-alpine"); got != DistroAlpine {
+		t.Errorf("got %q, want alpine", got)
+	}
+	DetectDistro(context.Background(), rt, "postgres:16-alpine")
+
+	if len(rt.CreateCalls) != 2 {
+		t.Fatalf("expected two probe containers, got %d", len(rt.CreateCalls))
+	}
+	first, second := rt.CreateCalls[0], rt.CreateCalls[1]
+	if !slices.Equal(first.Entrypoint, []string{"sleep"}) {
+		t.Errorf("entrypoint = %v, the image's own must not run", first.Entrypoint)
+	}
+	if first.Name == second.Name {
+		t.Errorf("probe containers share the name %q", first.Name)
+	}
+}
+
+func TestDetectDistroFallsBackToDebian(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	rt.ExitCode = 1
+	if got := DetectDistro(context.Background(), rt, "distroless"); got != DistroDebian {
+		t.Errorf("got %q, want debian", got)
+	}
+
+	rt = runtime.NewFakeRuntime()
+	rt.CreateErr = fmt.Errorf("pull denied")
+	if got := DetectDistro(context.Background(), rt, "private/image"); got != DistroDebian {
+		t.Errorf("got %q, want debian", got)
+	}
+}
@@ -0,0 +1,159 @@
+package podfile
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// SFTPServerPath is where sessions run sftp-server from, as packaged
+// by Debian and Ubuntu. Images from other distros get a symlink here.
+const SFTPServerPath = "/usr/lib/openssh/sftp-server"
+
+// baseStage names the repo's final stage when it has no name of its own.
+const baseStage = "podspawn-base"
+
+type dockerStage struct {
+	line int    // index of the FROM line
+	from string // image or earlier stage it starts from
+	name string // "" when the stage has no AS
+	user string // last USER in the stage
+}
+
+// layerDockerfile appends podspawn's stage to a repo's Dockerfile. The
+// stage being built on (target, or the last one) is named if needed so
+// a final FROM can start from it; its USER is restored at the end.
+func layerDockerfile(dockerfile, target string, pf *Podfile) (string, error) {
+	lines := strings.Split(dockerfile, "\n")
+	stages := parseStages(lines)
+	if len(stages) == 0 {
+		return "", fmt.Errorf("dockerfile has no FROM instruction")
+	}
+
+	base, err := pickStage(stages, target)
+	if err != nil {
+		return "", err
+	}
+	if base.name == "" {
+		base.name = baseStage
+		lines[base.line] = strings.TrimRight(lines[base.line], " \t\r") + " AS " + baseStage
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(strings.Join(lines, "\n"), "\n"))
+	fmt.Fprintf(&b, "\n\n# Added by podspawn\nFROM %s\nUSER root\n", base.name)
+	writeRequirements(&b, pf.Shell, distroOrDefault(pf.Distro))
+	if err := writeLayers(&b, pf); err != nil {
+		return "", err
+	}
+	if base.user != "" {
+		fmt.Fprintf(&b, "\nUSER %s\n", base.user)
+	}
+	return b.String(), nil
+}
+
+// pickStage returns the stage named target, or the last one.
+func pickStage(stages []dockerStage, target string) (dockerStage, error) {
+	if target == "" {
+		return stages[len(stages)-1], nil
+	}
+	for _, st := range stages {
+		if strings.EqualFold(st.name, target) {
+			return st, nil
+		}
+	}
+	return dockerStage{}, fmt.Errorf("build.target %q: no such stage in the dockerfile", target)
+}
+
+// dockerfileBaseImage returns the image the stage being built on
+// ultimately starts from, following FROM references to earlier stages.
+// It reports false when that can't be known without building, such as
+// an image named by a build arg.
+func dockerfileBaseImage(dockerfile, target string) (string, bool) {
+	stages := parseStages(strings.Split(dockerfile, "\n"))
+	if len(stages) == 0 {
+		return "", false
+	}
+	st, err := pickStage(stages, target)
+	if err != nil {
+		return "", false
+	}
+	for {
+		i := slices.IndexFunc(stages, func(p dockerStage) bool {
+			return p.line < st.line && strings.EqualFold(p.name, st.from)
+		})
+		if i < 0 {
+			break
+		}
+		st = stages[i]
+	}
+	if st.from == "" || st.from == "scratch" || strings.Contains(st.from, "$") {
+		return "", false
+	}
+	return st.from, true
+}
+
+// parseStages finds the FROM and USER instructions in a Dockerfile.
+// Continuation lines and comments are skipped.
+func parseStages(lines []string) []dockerStage {
+	var stages []dockerStage
+	continued := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		wasContinued := continued
+		continued = strings.HasSuffix(trimmed, "\\")
+		if wasContinued || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		switch strings.ToUpper(fields[0]) {
+		case "FROM":
+			var args []string
+			for _, f := range fields[1:] {
+				if !strings.HasPrefix(f, "--") {
+					args = append(args, f)
+				}
+			}
+			st := dockerStage{line: i}
+			if len(args) > 0 {
+				st.from = args[0]
+			}
+			if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+				st.name = args[2]
+			}
+			stages = append(stages, st)
+		case "USER":
+			if len(stages) > 0 && len(fields) > 1 {
+				stages[len(stages)-1].user = fields[1]
+			}
+		}
+	}
+	return stages
+}
+
+// writeRequirements installs what sessions rely on if the image lacks
+// it: sftp-server for scp/sftp/rsync, and the login shell. Where the
+// distro keeps sftp-server elsewhere it's linked to SFTPServerPath.
+func writeRequirements(b *strings.Builder, shell, distro string) {
+	family := distroFamilies[distro]
+	install := family.install
+	if family.lean != "" {
+		install += " " + family.lean
+	}
+	if family.cleanup != "" {
+		install += " $pkgs && " + family.cleanup
+	} else {
+		install += " $pkgs"
+	}
+
+	b.WriteString("\nRUN pkgs=\"\"; \\\n")
+	fmt.Fprintf(b, "    [ -x %s ] || pkgs=\"$pkgs %s\"; \\\n", family.sftpPath, family.sftpPkg)
+	if shell != "" && shell != "/bin/sh" {
+		fmt.Fprintf(b, "    [ -x %s ] || pkgs=\"$pkgs %s\"; \\\n", shell, path.Base(shell))
+	}
+	fmt.Fprintf(b, "    if [ -n \"$pkgs\" ]; then %s; fi\n", install)
+	if family.sftpPath != SFTPServerPath {
+		fmt.Fprintf(b, "\nRUN mkdir -p %s && ln -sf %s %s\n", path.Dir(SFTPServerPath), family.sftpPath, SFTPServerPath)
+	}
+}
@@ -0,0 +1,103 @@
+package podfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayerDockerfileNamesLastStage(t *testing.T) {
+	repo := "FROM golang:1.23 AS build\nRUN go build ./...\n\nFROM debian:12\nCOPY --from=build /app /app\nUSER app\n"
+	pf := &Podfile{Shell: "/bin/zsh", Packages: []string{"ripgrep"}, Env: map[string]string{"EDITOR": "vim"}}
+
+	got, err := layerDockerfile(repo, "", pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"FROM debian:12 AS podspawn-base\n",
+		"FROM podspawn-base\nUSER root\n",
+		"[ -x " + SFTPServerPath + " ] || pkgs=\"$pkgs openssh-sftp-server\"",
+		"[ -x /bin/zsh ] || pkgs=\"$pkgs zsh\"",
+		"ripgrep",
+		`ENV EDITOR="vim"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "\nUSER app\n") {
+		t.Errorf("should switch back to the stage's user at the end:\n%s", got)
+	}
+}
+
+func TestLayerDockerfileTarget(t *testing.T) {
+	repo := "FROM node:22 AS dev\nRUN npm ci\nFROM node:22-slim AS prod\n"
+	got, err := layerDockerfile(repo, "dev", &Podfile{Shell: "/bin/bash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "FROM dev\nUSER root\n") {
+		t.Errorf("should build on the target stage:\n%s", got)
+	}
+	if strings.Contains(got, "podspawn-base") {
+		t.Errorf("named stages should not be renamed:\n%s", got)
+	}
+
+	if _, err := layerDockerfile(repo, "test", &Podfile{}); err == nil || !strings.Contains(err.Error(), "no such stage") {
+		t.Errorf("expected missing stage error, got %v", err)
+	}
+}
+
+func TestLayerDockerfileIgnoresContinuations(t *testing.T) {
+	repo := "FROM --platform=linux/amd64 ubuntu:24.04\nRUN echo \\\n  FROM nothing\n"
+	got, err := layerDockerfile(repo, "", &Podfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "FROM --platform=linux/amd64 ubuntu:24.04 AS podspawn-base\n") {
+		t.Errorf("continuation line was taken for a FROM:\n%s", got)
+	}
+
+	if _, err := layerDockerfile("# empty\n", "", &Podfile{}); err == nil {
+		t.Error("expected error for a dockerfile without FROM")
+	}
+}
+
+func TestLayerDockerfileAlpine(t *testing.T) {
+	got, err := layerDockerfile("FROM alpine:3.20\n", "", &Podfile{Shell: "/bin/bash", Distro: DistroAlpine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"[ -x /usr/lib/ssh/sftp-server ] || pkgs=\"$pkgs openssh-sftp-server\"",
+		"then apk add --no-cache $pkgs; fi",
+		"ln -sf /usr/lib/ssh/sftp-server " + SFTPServerPath,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "apt-get") {
+		t.Errorf("alpine image should not use apt:\n%s", got)
+	}
+}
+
+func TestDockerfileBaseImage(t *testing.T) {
+	tests := []struct {
+		dockerfile, target string
+		want               string
+		ok                 bool
+	}{
+		{"FROM debian:12\n", "", "debian:12", true},
+		{"FROM --platform=linux/amd64 alpine:3.20 AS base\nFROM base AS dev\nFROM dev\n", "", "alpine:3.20", true},
+		{"FROM golang:1.23 AS build\nFROM fedora:40 AS run\n", "build", "golang:1.23", true},
+		{"ARG BASE=ubuntu:24.04\nFROM ${BASE}\n", "", "", false},
+		{"FROM scratch\n", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := dockerfileBaseImage(tt.dockerfile, tt.target)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("dockerfileBaseImage(%q, %q) = %q, %v; want %q, %v", tt.dockerfile, tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -4,17 +4,41 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/podspawn/podspawn/internal/runtime"
 )
 
+// ExecUser is who setup commands run as inside the container. The zero
+// value is the image's default user (root).
+type ExecUser struct {
+	Name string // empty = image default
+	Home string // empty = /root
+}
+
+// HomeDir returns the user's home directory in the container.
+func (u ExecUser) HomeDir() string {
+	if u.Home == "" {
+		return "/root"
+	}
+	return u.Home
+}
+
+// ExecOpts returns exec options that run cmd as u from dir. An empty dir
+// means the user's home, or the image's WORKDIR for the default user.
+func (u ExecUser) ExecOpts(dir string, cmd ...string) runtime.ExecOpts {
+	if dir == "" && u.Name != "" {
+		dir = u.HomeDir()
+	}
+	return runtime.ExecOpts{Cmd: cmd, User: u.Name, WorkingDir: dir}
+}
+
 // CloneDotfiles clones a dotfiles repo into ~/dotfiles inside the container
-// and optionally runs an install script.
-func CloneDotfiles(ctx context.Context, rt runtime.Runtime, containerName string, cfg *DotfilesConfig) error {
+// and optionally runs an install script from that directory.
+func CloneDotfiles(ctx context.Context, rt runtime.Runtime, containerName string, as ExecUser, cfg *DotfilesConfig) error {
 	slog.Info("cloning dotfiles", "repo", cfg.Repo, "container", containerName)
-	exitCode, err := rt.Exec(ctx, containerName, runtime.ExecOpts{
-		Cmd: []string{"git", "clone", cfg.Repo, "/root/dotfiles"},
-	})
+	dotfilesDir := as.HomeDir() + "/dotfiles"
+	exitCode, err := rt.Exec(ctx, containerName, as.ExecOpts("", "git", "clone", cfg.Repo, dotfilesDir))
 	if err != nil {
 		return fmt.Errorf("cloning dotfiles: %w", err)
 	}
@@ -24,9 +48,7 @@ func CloneDotfiles(ctx context.Context, rt runtime.Runtime, containerName string
 
 	if cfg.Install != "" {
 		slog.Info("running dotfiles install", "script", cfg.Install)
-		exitCode, err = rt.Exec(ctx, containerName, runtime.ExecOpts{
-			Cmd: []string{"sh", "-c", "cd /root/dotfiles && " + cfg.Install},
-		})
+		exitCode, err = rt.Exec(ctx, containerName, as.ExecOpts(dotfilesDir, "sh", "-c", cfg.Install))
 		if err != nil {
 			return fmt.Errorf("running dotfiles install: %w", err)
 		}
@@ -40,7 +62,7 @@ func CloneDotfiles(ctx context.Context, rt runtime.Runtime, containerName string
 
 // CloneRepoInContainer clones a project repo into the container at the
 // specified path.
-func CloneRepoInContainer(ctx context.Context, rt runtime.Runtime, containerName string, repo RepoConfig) error {
+func CloneRepoInContainer(ctx context.Context, rt runtime.Runtime, containerName string, as ExecUser, repo RepoConfig) error {
 	slog.Info("cloning repo", "url", repo.URL, "path", repo.Path, "container", containerName)
 
 	args := []string{"git", "clone", "--single-branch"}
@@ -52,7 +74,7 @@ func CloneRepoInContainer(ctx context.Context, rt runtime.Runtime, containerName
 		args = append(args, repo.Path)
 	}
 
-	exitCode, err := rt.Exec(ctx, containerName, runtime.ExecOpts{Cmd: args})
+	exitCode, err := rt.Exec(ctx, containerName, as.ExecOpts("", args...))
 	if err != nil {
 		return fmt.Errorf("cloning %s: %w", repo.URL, err)
 	}
@@ -64,14 +86,12 @@ func CloneRepoInContainer(ctx context.Context, rt runtime.Runtime, containerName
 
 // RunHook executes a shell command inside the container. Used for
 // on_create and on_start lifecycle hooks.
-func RunHook(ctx context.Context, rt runtime.Runtime, containerName, hookName, script string) {
+func RunHook(ctx context.Context, rt runtime.Runtime, containerName string, as ExecUser, hookName, script string) {
 	if script == "" {
 		return
 	}
 	slog.Info("running hook", "hook", hookName, "container", containerName)
-	exitCode, err := rt.Exec(ctx, containerName, runtime.ExecOpts{
-		Cmd: []string{"sh", "-c", script},
-	})
+	exitCode, err := rt.Exec(ctx, containerName, as.ExecOpts("", "sh", "-c", script))
 	if err != nil {
 		slog.Warn("hook failed", "hook", hookName, "error", err)
 		return
@@ -80,3 +100,16 @@ func RunHook(ctx context.Context, rt runtime.Runtime, containerName, hookName, s
 		slog.Warn("hook exited non-zero", "hook", hookName, "code", exitCode)
 	}
 }
+
+// ShellQuote quotes s as a single sh word. Words made only of characters
+// sh never treats specially are left bare; anything else is
+// single-quoted.
+func ShellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || r == '/' || r == '=' || r == ':' ||
+			'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
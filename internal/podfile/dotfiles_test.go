@@ -14,7 +14,7 @@ func TestCloneDotfiles(t *testing.T) {
 	cfg := &DotfilesConfig{
 		Repo: "https://github.com/user/dots",
 	}
-	err := CloneDotfiles(context.Background(), rt, "dev-ctr", cfg)
+	err := CloneDotfiles(context.Background(), rt, "dev-ctr", ExecUser{}, cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,7 +35,7 @@ func TestCloneDotfilesWithInstall(t *testing.T) {
 		Repo:    "https://github.com/user/dots",
 		Install: "./install.sh",
 	}
-	err := CloneDotfiles(context.Background(), rt, "dev-ctr", cfg)
+	err := CloneDotfiles(context.Background(), rt, "dev-ctr", ExecUser{}, cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -53,7 +53,7 @@ func TestCloneRepoInContainer(t *testing.T) {
 		Path:   "/workspace/backend",
 		Branch: "develop",
 	}
-	err := CloneRepoInContainer(context.Background(), rt, "dev-ctr", repo)
+	err := CloneRepoInContainer(context.Background(), rt, "dev-ctr", ExecUser{}, repo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,7 +75,7 @@ func TestCloneRepoInContainer(t *testing.T) {
 
 func TestRunHookEmpty(t *testing.T) {
 	rt := runtime.NewFakeRuntime()
-	RunHook(context.Background(), rt, "dev-ctr", "on_create", "")
+	RunHook(context.Background(), rt, "dev-ctr", ExecUser{}, "on_create", "")
 	if len(rt.ExecCalls) != 0 {
 		t.Error("empty hook should not exec")
 	}
@@ -85,7 +85,7 @@ func TestRunHookExecutes(t *testing.T) {
 	rt := runtime.NewFakeRuntime()
 	rt.Containers["dev-ctr"] = true
 
-	RunHook(context.Background(), rt, "dev-ctr", "on_create", "make setup")
+	RunHook(context.Background(), rt, "dev-ctr", ExecUser{}, "on_create", "make setup")
 	if len(rt.ExecCalls) != 1 {
 		t.Fatalf("expected 1 exec call, got %d", len(rt.ExecCalls))
 	}
@@ -94,3 +94,40 @@ func TestRunHookExecutes(t *testing.T) {
 		t.Errorf("unexpected command: %v", cmd)
 	}
 }
+
+func TestCloneDotfilesAsUser(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	rt.Containers["dev-ctr"] = true
+	as := ExecUser{Name: "alice", Home: "/home/alice"}
+
+	cfg := &DotfilesConfig{Repo: "https://github.com/alice/dots", Install: "./install.sh"}
+	if err := CloneDotfiles(context.Background(), rt, "dev-ctr", as, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := rt.ExecCalls[0].Opts
+	if clone.User != "alice" || clone.Cmd[len(clone.Cmd)-1] != "/home/alice/dotfiles" {
+		t.Errorf("clone should run as alice into her home, got user=%q cmd=%v", clone.User, clone.Cmd)
+	}
+	install := rt.ExecCalls[1].Opts
+	if install.User != "alice" || install.WorkingDir != "/home/alice/dotfiles" {
+		t.Errorf("install should run as alice from the dotfiles dir, got user=%q dir=%q", install.User, install.WorkingDir)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"deploy":       "deploy",
+		"/home/deploy": "/home/deploy",
+		"":             "''",
+		"my app":       "'my app'",
+		"it's":         `'it'\''s'`,
+		"$HOME":        "'$HOME'",
+		"a;rm -rf /":   "'a;rm -rf /'",
+	}
+	for in, want := range tests {
+		if got := ShellQuote(in); got != want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
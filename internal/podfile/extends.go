@@ -0,0 +1,203 @@
+package podfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/config"
+)
+
+// maxExtendsDepth bounds extends chains; anything deeper is almost
+// certainly a mistake.
+const maxExtendsDepth = 8
+
+// Resolver locates the parents named by extends: other registered
+// projects, and shared templates in TemplateDir.
+//
+// A project: parent ends up in the child's image, so it must be
+// unrestricted or its access list must cover Access, the access list of
+// the project being loaded; Groups resolves group members for that.
+type Resolver struct {
+	Projects    map[string]config.ProjectConfig
+	TemplateDir string
+	Access      config.ProjectConfig
+	Groups      map[string][]string
+}
+
+// resolve merges pf onto its chain of parents. root is the directory
+// relative extends paths are resolved in (the repo checkout, or the
+// template directory); seen holds the files already on the chain.
+// Also returns the registered projects the chain goes through.
+func (r *Resolver) resolve(pf *Podfile, root string, seen []string) (*Podfile, []string, error) {
+	if pf.Extends == "" {
+		return pf, nil, nil
+	}
+	if len(seen) > maxExtendsDepth {
+		return nil, nil, fmt.Errorf("extends chain is deeper than %d", maxExtendsDepth)
+	}
+	path, parentRoot, err := r.locate(pf.Extends, root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("extends %q: %w", pf.Extends, err)
+	}
+	if slices.Contains(seen, path) {
+		return nil, nil, fmt.Errorf("extends %q: cycle through %s", pf.Extends, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("extends %q: %w", pf.Extends, err)
+	}
+	parent, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("extends %q: %w", pf.Extends, err)
+	}
+	parent, projects, err := r.resolve(parent, parentRoot, append(seen, path))
+	if err != nil {
+		return nil, nil, err
+	}
+	if name, ok := strings.CutPrefix(pf.Extends, "project:"); ok {
+		projects = append([]string{name}, projects...)
+	}
+	// The parent's build context is in the parent's tree
+	if parent.Build != nil && parent.Build.root == "" {
+		parent.Build.root = parentRoot
+	}
+	return merge(parent, pf), projects, nil
+}
+
+// locate returns the file a reference points to and the root its own
+// relative references resolve in.
+func (r *Resolver) locate(ref, root string) (path, parentRoot string, err error) {
+	if name, ok := strings.CutPrefix(ref, "project:"); ok {
+		proj, found := config.ProjectConfig{}, false
+		if r != nil {
+			proj, found = r.Projects[name]
+		}
+		if !found {
+			return "", "", fmt.Errorf("project %q is not registered", name)
+		}
+		if !proj.Covers(r.Access, r.Groups) {
+			return "", "", fmt.Errorf("project %q is restricted to fewer users than this project", name)
+		}
+		data, path, err := readFirst(
+			filepath.Join(proj.LocalPath, ".podspawn", "podfile.yaml"),
+			filepath.Join(proj.LocalPath, "podfile.yaml"),
+		)
+		if err != nil {
+			return "", "", err
+		}
+		if data == nil {
+			return "", "", fmt.Errorf("project %q has no podfile.yaml", name)
+		}
+		return path, proj.LocalPath, nil
+	}
+
+	if name, ok := strings.CutPrefix(ref, "template:"); ok {
+		if r == nil || r.TemplateDir == "" {
+			return "", "", fmt.Errorf("no template directory configured")
+		}
+		if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+			return "", "", fmt.Errorf("invalid template name %q", name)
+		}
+		return filepath.Join(r.TemplateDir, name+".yaml"), r.TemplateDir, nil
+	}
+
+	if filepath.IsAbs(ref) {
+		return "", "", fmt.Errorf("must be relative to the repo; use template:<name> for shared files")
+	}
+	if !insideDir(ref) {
+		return "", "", fmt.Errorf("path leaves the repo")
+	}
+	return filepath.Join(root, ref), root, nil
+}
+
+// merge lays child over parent:
+//   - scalars (base or build, distro, shell, dotfiles, persist) and each
+//     resources field are replaced when the child sets them
+//   - packages are combined, the child's version winning for the same tool
+//   - env is combined, the child winning per variable
+//   - services and repos are combined, the child's replacing the parent's
+//     with the same name (path for repos)
+//   - hooks and extra_commands run the parent's first, then the child's
+//   - exposed ports are combined
+func merge(parent, child *Podfile) *Podfile {
+	out := *parent
+	out.Extends = ""
+
+	// base and build are alternatives, so setting either replaces both,
+	// and the parent's distro no longer describes the image
+	if child.Base != "" || child.Build != nil {
+		out.Base, out.Build, out.Distro = child.Base, child.Build, ""
+	}
+	if child.Distro != "" {
+		out.Distro = child.Distro
+	}
+	if child.Shell != "" {
+		out.Shell = child.Shell
+	}
+	if child.Dotfiles != nil {
+		out.Dotfiles = child.Dotfiles
+	}
+	if child.Persist != nil {
+		out.Persist = child.Persist
+	}
+
+	out.Packages = mergeByKey(parent.Packages, child.Packages, func(spec string) string { return ParsePackage(spec).Name })
+	out.Services = mergeByKey(parent.Services, child.Services, func(s ServiceConfig) string { return s.Name })
+	out.Repos = mergeByKey(parent.Repos, child.Repos, func(r RepoConfig) string {
+		if r.Path != "" {
+			return r.Path
+		}
+		return r.URL
+	})
+
+	if len(parent.Env)+len(child.Env) > 0 {
+		out.Env = make(map[string]string, len(parent.Env)+len(child.Env))
+		for k, v := range parent.Env {
+			out.Env[k] = v
+		}
+		for k, v := range child.Env {
+			out.Env[k] = v
+		}
+	}
+
+	out.Ports.Expose = slices.Clone(parent.Ports.Expose)
+	for _, p := range child.Ports.Expose {
+		if !slices.Contains(out.Ports.Expose, p) {
+			out.Ports.Expose = append(out.Ports.Expose, p)
+		}
+	}
+
+	if child.Resources.CPUs != 0 {
+		out.Resources.CPUs = child.Resources.CPUs
+	}
+	if child.Resources.Memory != "" {
+		out.Resources.Memory = child.Resources.Memory
+	}
+	if child.Resources.Runtime != "" {
+		out.Resources.Runtime = child.Resources.Runtime
+	}
+
+	out.OnCreate = joinScripts(parent.OnCreate, child.OnCreate)
+	out.OnStart = joinScripts(parent.OnStart, child.OnStart)
+	out.ExtraCommands = append(slices.Clone(parent.ExtraCommands), child.ExtraCommands...)
+	return &out
+}
+
+// mergeByKey appends child's items to parent's, an item with a key
+// already present replacing the parent's in place.
+func mergeByKey[T any](parent, child []T, key func(T) string) []T {
+	out := slices.Clone(parent)
+	for _, c := range child {
+		i := slices.IndexFunc(out, func(p T) bool { return key(p) == key(c) })
+		if i >= 0 {
+			out[i] = c
+		} else {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,197 @@
+package podfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtendsMerge(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "shared", "base.yaml"), `
+base: ubuntu:24.04
+shell: /bin/zsh
+packages: [nodejs@20, ripgrep]
+env: {EDITOR: vim, LANG: C.UTF-8}
+services:
+  - {name: postgres, image: "postgres:15"}
+  - {name: redis, image: "redis:7"}
+resources: {cpus: 2, memory: 4g}
+ports: {expose: [3000]}
+on_create: make deps
+extra_commands: ["echo parent"]
+`)
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), `
+extends: shared/base.yaml
+packages: [nodejs@22, fzf]
+env: {EDITOR: nvim}
+services:
+  - {name: postgres, image: "postgres:16"}
+resources: {memory: 8g}
+ports: {expose: [3000, 8080]}
+on_create: make seed
+extra_commands: ["echo child"]
+`)
+
+	src, err := Load(repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := src.Podfile
+
+	if pf.Base != "ubuntu:24.04" || pf.Shell != "/bin/zsh" {
+		t.Errorf("base = %q, shell = %q, want the parent's", pf.Base, pf.Shell)
+	}
+	if want := []string{"nodejs@22", "ripgrep", "fzf"}; !slices.Equal(pf.Packages, want) {
+		t.Errorf("packages = %v, want %v", pf.Packages, want)
+	}
+	if pf.Env["EDITOR"] != "nvim" || pf.Env["LANG"] != "C.UTF-8" {
+		t.Errorf("env = %v", pf.Env)
+	}
+	if len(pf.Services) != 2 || pf.Services[0].Image != "postgres:16" || pf.Services[1].Name != "redis" {
+		t.Errorf("services = %+v, want postgres replaced and redis kept", pf.Services)
+	}
+	if pf.Resources.CPUs != 2 || pf.Resources.Memory != "8g" {
+		t.Errorf("resources = %+v", pf.Resources)
+	}
+	if !slices.Equal(pf.Ports.Expose, []int{3000, 8080}) {
+		t.Errorf("ports = %v", pf.Ports.Expose)
+	}
+	if pf.OnCreate != "make deps\nmake seed" {
+		t.Errorf("on_create = %q, want parent's then child's", pf.OnCreate)
+	}
+	if !slices.Equal(pf.ExtraCommands, []string{"echo parent", "echo child"}) {
+		t.Errorf("extra_commands = %v", pf.ExtraCommands)
+	}
+	if pf.Extends != "" || strings.Contains(string(src.Raw), "extends") {
+		t.Error("resolved podfile should not extend anything")
+	}
+}
+
+func TestExtendsParentChangeChangesTag(t *testing.T) {
+	repo := t.TempDir()
+	templates := t.TempDir()
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), "extends: template:go\npackages: [make]\n")
+	writeFile(t, filepath.Join(templates, "go.yaml"), "base: golang:1.22\n")
+	r := &Resolver{TemplateDir: templates}
+
+	before, err := Load(repo, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Podfile.Base != "golang:1.22" {
+		t.Errorf("base = %q, want the template's", before.Podfile.Base)
+	}
+
+	writeFile(t, filepath.Join(templates, "go.yaml"), "base: golang:1.23\n")
+	after, err := Load(repo, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ComputeTag("svc", before.Raw) == ComputeTag("svc", after.Raw) {
+		t.Error("changing the template should change the image tag")
+	}
+}
+
+func TestExtendsProject(t *testing.T) {
+	shared := t.TempDir()
+	writeFile(t, filepath.Join(shared, "podfile.yaml"), "extends: common.yaml\npackages: [jq]\n")
+	writeFile(t, filepath.Join(shared, "common.yaml"), "base: debian:12\n")
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), "extends: project:platform\n")
+
+	r := &Resolver{Projects: map[string]config.ProjectConfig{"platform": {LocalPath: shared}}}
+	src, err := Load(repo, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Podfile.Base != "debian:12" || !slices.Equal(src.Podfile.Packages, []string{"jq"}) {
+		t.Errorf("podfile = %+v, want the platform project's chain resolved in its own repo", src.Podfile)
+	}
+	if !slices.Equal(src.Parents, []string{"platform"}) {
+		t.Errorf("parents = %v, want [platform]", src.Parents)
+	}
+
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), "extends: project:unknown\n")
+	if _, err := Load(repo, r); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("expected unregistered project error, got %v", err)
+	}
+}
+
+func TestExtendsRestrictedProject(t *testing.T) {
+	secret := t.TempDir()
+	writeFile(t, filepath.Join(secret, "podfile.yaml"), "base: debian:12\nenv:\n  TOKEN: internal\n")
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), "extends: project:secret\n")
+	projects := map[string]config.ProjectConfig{"secret": {LocalPath: secret, AllowedUsers: []string{"alice"}}}
+
+	for _, access := range []config.ProjectConfig{{}, {AllowedUsers: []string{"alice", "bob"}}} {
+		r := &Resolver{Projects: projects, Access: access}
+		if _, err := Load(repo, r); err == nil || !strings.Contains(err.Error(), "restricted") {
+			t.Errorf("access %+v: expected restricted parent error, got %v", access, err)
+		}
+	}
+
+	r := &Resolver{Projects: projects, Access: config.ProjectConfig{AllowedUsers: []string{"alice"}}}
+	if _, err := Load(repo, r); err != nil {
+		t.Errorf("a child open to a subset of the parent's users should load: %v", err)
+	}
+}
+
+func TestExtendsProjectBuildContext(t *testing.T) {
+	shared := t.TempDir()
+	writeFile(t, filepath.Join(shared, "podfile.yaml"), "build:\n  context: docker\n")
+	writeFile(t, filepath.Join(shared, "docker", "Dockerfile"), "FROM debian:12\n")
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "podfile.yaml"), "extends: project:platform\npackages: [jq]\n")
+
+	r := &Resolver{Projects: map[string]config.ProjectConfig{"platform": {LocalPath: shared}}}
+	src, err := Load(repo, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := src.contextDir(), filepath.Join(shared, "docker"); got != want {
+		t.Errorf("context dir = %q, want %q in the parent's repo", got, want)
+	}
+}
+
+func TestExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"cycle", map[string]string{"podfile.yaml": "extends: a.yaml\n", "a.yaml": "extends: podfile.yaml\n"}, "cycle"},
+		{"escape", map[string]string{"podfile.yaml": "extends: ../other/podfile.yaml\n"}, "leaves the repo"},
+		{"absolute", map[string]string{"podfile.yaml": "extends: /etc/podspawn/templates/go.yaml\n"}, "relative"},
+		{"no template dir", map[string]string{"podfile.yaml": "extends: template:go\n"}, "no template directory"},
+		{"missing parent", map[string]string{"podfile.yaml": "extends: nope.yaml\n"}, "no such file"},
+		{"no base anywhere", map[string]string{"podfile.yaml": "extends: a.yaml\n", "a.yaml": "packages: [git]\n"}, "base image is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := t.TempDir()
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(repo, name), content)
+			}
+			_, err := Load(repo, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -9,37 +9,56 @@ import (
 // Generate produces a Dockerfile from a parsed Podfile.
 // Only image-time concerns go here: FROM, packages, shell, static env, ports, extra commands.
 // Runtime concerns (repos, dotfiles, on_create, services) are handled at container creation.
+// Packages are installed with pf.Distro's package manager, apt when unset.
 func Generate(pf *Podfile) (string, error) {
 	var b strings.Builder
-
 	fmt.Fprintf(&b, "FROM %s\n", pf.Base)
+	// Debian-family images are assumed to already carry bash and sftp-server
+	if distro := distroOrDefault(pf.Distro); distro != DistroDebian {
+		writeRequirements(&b, pf.Shell, distro)
+	}
+	if err := writeLayers(&b, pf); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
 
+// writeLayers writes everything Generate puts after FROM.
+func writeLayers(b *strings.Builder, pf *Podfile) error {
 	var pkgs []Package
 	for _, spec := range pf.Packages {
 		pkgs = append(pkgs, ParsePackage(spec))
 	}
 
-	aptPkgs, specialRuns, err := InstallCommands(pkgs)
+	distro := distroOrDefault(pf.Distro)
+	sysPkgs, specialRuns, err := InstallCommands(pkgs, distro)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	needsBash := len(specialRuns) > 0
 	if needsBash {
-		b.WriteString("\nSHELL [\"/bin/bash\", \"-euo\", \"pipefail\", \"-c\"]\n")
+		b.WriteString(pipefailShell(distro))
 	}
 
 	for _, cmd := range specialRuns {
-		fmt.Fprintf(&b, "\nRUN %s\n", cmd)
+		fmt.Fprintf(b, "\nRUN %s\n", cmd)
 	}
 
-	if len(aptPkgs) > 0 {
-		b.WriteString("\nRUN apt-get update && apt-get install -y \\\n")
-		sort.Strings(aptPkgs)
-		for _, pkg := range aptPkgs {
-			fmt.Fprintf(&b, "    %s \\\n", pkg)
+	if len(sysPkgs) > 0 {
+		family := distroFamilies[distro]
+		fmt.Fprintf(b, "\nRUN %s \\\n", family.install)
+		sort.Strings(sysPkgs)
+		for i, pkg := range sysPkgs {
+			if i == len(sysPkgs)-1 && family.cleanup == "" {
+				fmt.Fprintf(b, "    %s\n", pkg)
+			} else {
+				fmt.Fprintf(b, "    %s \\\n", pkg)
+			}
+		}
+		if family.cleanup != "" {
+			fmt.Fprintf(b, "    && %s\n", family.cleanup)
 		}
-		b.WriteString("    && rm -rf /var/lib/apt/lists/*\n")
 	}
 
 	staticEnv := filterStaticEnv(pf.Env)
@@ -55,12 +74,12 @@ func Generate(pf *Podfile) (string, error) {
 			if i == len(keys)-1 {
 				sep = ""
 			}
-			fmt.Fprintf(&b, "%s%s=%q%s\n", prefix, k, staticEnv[k], sep)
+			fmt.Fprintf(b, "%s%s=%q%s\n", prefix, k, staticEnv[k], sep)
 		}
 	}
 
 	if pf.Shell != "/bin/bash" && pf.Shell != "" {
-		fmt.Fprintf(&b, "\nSHELL [\"%s\", \"-c\"]\n", pf.Shell)
+		fmt.Fprintf(b, "\nSHELL [\"%s\", \"-c\"]\n", pf.Shell)
 	}
 
 	if len(pf.Ports.Expose) > 0 {
@@ -68,14 +87,23 @@ func Generate(pf *Podfile) (string, error) {
 		for i, p := range pf.Ports.Expose {
 			parts[i] = fmt.Sprintf("%d", p)
 		}
-		fmt.Fprintf(&b, "\nEXPOSE %s\n", strings.Join(parts, " "))
+		fmt.Fprintf(b, "\nEXPOSE %s\n", strings.Join(parts, " "))
 	}
 
 	for _, cmd := range pf.ExtraCommands {
-		fmt.Fprintf(&b, "\nRUN %s\n", cmd)
+		fmt.Fprintf(b, "\nRUN %s\n", cmd)
 	}
+	return nil
+}
 
-	return b.String(), nil
+// pipefailShell switches RUN to a shell that fails on any stage of a
+// pipe, so curl | sh recipes don't hide download errors. Alpine has no
+// bash, but busybox sh knows pipefail.
+func pipefailShell(distro string) string {
+	if distro == DistroAlpine {
+		return "\nSHELL [\"/bin/sh\", \"-euo\", \"pipefail\", \"-c\"]\n"
+	}
+	return "\nSHELL [\"/bin/bash\", \"-euo\", \"pipefail\", \"-c\"]\n"
 }
 
 func filterStaticEnv(env map[string]string) map[string]string {
@@ -222,3 +222,44 @@ func TestGenerateDeterministic(t *testing.T) {
 		t.Errorf("non-deterministic output:\n--- run 1 ---\n%s\n--- run 2 ---\n%s", out1, out2)
 	}
 }
+
+func TestGenerateAlpine(t *testing.T) {
+	pf := &Podfile{
+		Base:     "alpine:3.20",
+		Distro:   DistroAlpine,
+		Shell:    "/bin/bash",
+		Packages: []string{"ripgrep", "go@1.23.0"},
+	}
+	got, err := Generate(pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"[ -x /bin/bash ] || pkgs=\"$pkgs bash\"",
+		`SHELL ["/bin/sh", "-euo", "pipefail", "-c"]`,
+		"RUN apk add --no-cache \\\n    ripgrep\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "apt-get") || strings.Contains(got, "/bin/bash\", \"-euo") {
+		t.Errorf("alpine output should use neither apt nor bash:\n%s", got)
+	}
+}
+
+func TestGenerateFedora(t *testing.T) {
+	pf := &Podfile{
+		Base:     "fedora:40",
+		Distro:   DistroFedora,
+		Shell:    "/bin/bash",
+		Packages: []string{"ripgrep", "fzf"},
+	}
+	got, err := Generate(pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "RUN dnf install -y \\\n    fzf \\\n    ripgrep \\\n    && dnf clean all\n") {
+		t.Errorf("expected dnf install:\n%s", got)
+	}
+}
@@ -2,6 +2,7 @@ package podfile
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -17,60 +18,41 @@ func ParsePackage(spec string) Package {
 	return Package{Name: spec}
 }
 
-// knownPackages maps tool name to version to install commands.
-// "*" as a version key means any version, with ${VERSION} substituted.
-var knownPackages = map[string]map[string][]string{
-	"nodejs": {
-		"18": {"curl -fsSL https://deb.nodesource.com/setup_18.x | bash -", "apt-get install -y nodejs"},
-		"20": {"curl -fsSL https://deb.nodesource.com/setup_20.x | bash -", "apt-get install -y nodejs"},
-		"22": {"curl -fsSL https://deb.nodesource.com/setup_22.x | bash -", "apt-get install -y nodejs"},
-	},
-	"python": {
-		"3.11": {"add-apt-repository -y ppa:deadsnakes/ppa", "apt-get install -y python3.11 python3.11-venv"},
-		"3.12": {"add-apt-repository -y ppa:deadsnakes/ppa", "apt-get install -y python3.12 python3.12-venv"},
-		"3.13": {"add-apt-repository -y ppa:deadsnakes/ppa", "apt-get install -y python3.13 python3.13-venv"},
-	},
-	"go": {
-		"*": {
-			"curl -fsSL https://go.dev/dl/go${VERSION}.linux-$(dpkg --print-architecture).tar.gz | tar -C /usr/local -xzf -",
-			"ln -sf /usr/local/go/bin/go /usr/local/bin/go",
-		},
-	},
-	"rust": {
-		"*": {"curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh -s -- -y --default-toolchain ${VERSION}"},
-	},
-}
-
-// InstallCommands splits parsed packages into plain apt packages and
-// special install sequences from the version map. Returns (aptPackages, specialRuns, error).
-func InstallCommands(pkgs []Package) ([]string, []string, error) {
-	var aptPkgs []string
+// InstallCommands splits parsed packages into plain distro packages and
+// special install sequences from the recipes, for the given distro
+// family. Returns (systemPackages, specialRuns, error).
+func InstallCommands(pkgs []Package, distro string) ([]string, []string, error) {
+	family, ok := distroFamilies[distroOrDefault(distro)]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown distro %q", distro)
+	}
+	var sysPkgs []string
 	var specialRuns []string
 
 	for _, pkg := range pkgs {
 		if pkg.Version == "" {
-			aptPkgs = append(aptPkgs, pkg.Name)
+			sysPkgs = append(sysPkgs, pkg.Name)
 			continue
 		}
 
-		versions, known := knownPackages[pkg.Name]
+		r, known := knownPackages[pkg.Name]
 		if !known {
-			// Unknown versioned package: try apt with version glob
-			aptPkgs = append(aptPkgs, pkg.Name+"="+pkg.Version+"*")
+			// Unknown versioned package: ask the package manager for it
+			pinned, err := family.pin(pkg.Name, pkg.Version)
+			if err != nil {
+				return nil, nil, err
+			}
+			sysPkgs = append(sysPkgs, pinned)
 			continue
 		}
 
-		// Exact version match
-		if cmds, ok := versions[pkg.Version]; ok {
-			specialRuns = append(specialRuns, cmds...)
-			continue
+		versions := r.forDistro(distroOrDefault(distro))
+		if versions == nil {
+			return nil, nil, fmt.Errorf("no recipe for %s@%s on %s images", pkg.Name, pkg.Version, distroOrDefault(distro))
 		}
 
-		// Wildcard version
-		if cmds, ok := versions["*"]; ok {
-			for _, cmd := range cmds {
-				specialRuns = append(specialRuns, strings.ReplaceAll(cmd, "${VERSION}", pkg.Version))
-			}
+		if cmds, ok := matchVersion(versions, pkg.Version); ok {
+			specialRuns = append(specialRuns, cmds...)
 			continue
 		}
 
@@ -78,7 +60,7 @@ func InstallCommands(pkgs []Package) ([]string, []string, error) {
 			pkg.Name, pkg.Version, availableVersions(versions))
 	}
 
-	return aptPkgs, specialRuns, nil
+	return sysPkgs, specialRuns, nil
 }
 
 func availableVersions(versions map[string][]string) string {
@@ -88,6 +70,7 @@ func availableVersions(versions map[string][]string) string {
 			vs = append(vs, v)
 		}
 	}
+	sort.Strings(vs)
 	if _, ok := versions["*"]; ok {
 		vs = append(vs, "<any>")
 	}
@@ -28,7 +28,7 @@ func TestParsePackageDottedVersion(t *testing.T) {
 
 func TestInstallCommandsKnownVersioned(t *testing.T) {
 	pkgs := []Package{{Name: "nodejs", Version: "22"}}
-	apt, special, err := InstallCommands(pkgs)
+	apt, special, err := InstallCommands(pkgs, DistroDebian)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,7 +45,7 @@ func TestInstallCommandsKnownVersioned(t *testing.T) {
 
 func TestInstallCommandsUnversioned(t *testing.T) {
 	pkgs := []Package{{Name: "ripgrep"}, {Name: "fzf"}}
-	apt, special, err := InstallCommands(pkgs)
+	apt, special, err := InstallCommands(pkgs, DistroDebian)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,7 +59,7 @@ func TestInstallCommandsUnversioned(t *testing.T) {
 
 func TestInstallCommandsWildcardVersion(t *testing.T) {
 	pkgs := []Package{{Name: "go", Version: "1.22.0"}}
-	apt, special, err := InstallCommands(pkgs)
+	apt, special, err := InstallCommands(pkgs, DistroDebian)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -79,7 +79,7 @@ func TestInstallCommandsWildcardVersion(t *testing.T) {
 
 func TestInstallCommandsUnknownVersioned(t *testing.T) {
 	pkgs := []Package{{Name: "libcurl", Version: "7.88"}}
-	apt, special, err := InstallCommands(pkgs)
+	apt, special, err := InstallCommands(pkgs, DistroDebian)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +97,7 @@ func TestInstallCommandsMixed(t *testing.T) {
 		{Name: "ripgrep"},
 		{Name: "go", Version: "1.23.0"},
 	}
-	apt, special, err := InstallCommands(pkgs)
+	apt, special, err := InstallCommands(pkgs, DistroDebian)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -112,7 +112,7 @@ func TestInstallCommandsMixed(t *testing.T) {
 
 func TestInstallCommandsUnsupportedVersion(t *testing.T) {
 	pkgs := []Package{{Name: "nodejs", Version: "99"}}
-	_, _, err := InstallCommands(pkgs)
+	_, _, err := InstallCommands(pkgs, DistroDebian)
 	if err == nil {
 		t.Fatal("expected error for unsupported nodejs version")
 	}
@@ -120,3 +120,57 @@ func TestInstallCommandsUnsupportedVersion(t *testing.T) {
 		t.Errorf("error = %q", err)
 	}
 }
+
+func TestInstallCommandsDistroRecipes(t *testing.T) {
+	_, special, err := InstallCommands([]Package{{Name: "nodejs", Version: "22"}}, DistroAlpine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(special) != 1 || special[0] != "apk add --no-cache nodejs=~22 npm" {
+		t.Errorf("alpine nodejs = %v", special)
+	}
+
+	_, special, err = InstallCommands([]Package{{Name: "python", Version: "3.12"}}, DistroFedora)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(special) != 1 || special[0] != "dnf install -y python3.12" {
+		t.Errorf("fedora python = %v", special)
+	}
+
+	// go's tarball recipe isn't tied to a package manager
+	_, special, err = InstallCommands([]Package{{Name: "go", Version: "1.23.0"}}, DistroArch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(special) != 2 || strings.Contains(special[0], "dpkg") {
+		t.Errorf("arch go = %v", special)
+	}
+
+	_, _, err = InstallCommands([]Package{{Name: "python", Version: "3.12"}}, DistroArch)
+	if err == nil || !strings.Contains(err.Error(), "no recipe for python@3.12 on arch") {
+		t.Errorf("expected missing recipe error, got %v", err)
+	}
+}
+
+func TestInstallCommandsDistroPinning(t *testing.T) {
+	tests := []struct {
+		distro, want string
+	}{
+		{DistroAlpine, "libcurl=~7.88"},
+		{DistroFedora, "libcurl-7.88*"},
+	}
+	for _, tt := range tests {
+		sys, _, err := InstallCommands([]Package{{Name: "libcurl", Version: "7.88"}}, tt.distro)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sys) != 1 || sys[0] != tt.want {
+			t.Errorf("%s: got %v, want [%s]", tt.distro, sys, tt.want)
+		}
+	}
+
+	if _, _, err := InstallCommands([]Package{{Name: "libcurl", Version: "7.88"}}, DistroArch); err == nil {
+		t.Error("pacman can't pin versions; expected an error")
+	}
+}
@@ -1,6 +1,7 @@
 package podfile
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -12,13 +13,30 @@ import (
 )
 
 // Parse decodes a Podfile from an io.Reader, applies defaults, and validates.
+// An extends key is left unresolved; Load resolves it.
 func Parse(r io.Reader) (*Podfile, error) {
+	pf, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := pf.finalize(); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+func decode(r io.Reader) (*Podfile, error) {
 	var pf Podfile
 	dec := yaml.NewDecoder(r)
 	if err := dec.Decode(&pf); err != nil {
 		return nil, fmt.Errorf("decoding podfile: %w", err)
 	}
+	return &pf, nil
+}
 
+// finalize applies defaults and validates. It runs after extends is
+// merged, so a parent's shell isn't masked by the child's default.
+func (pf *Podfile) finalize() error {
 	if pf.Shell == "" {
 		pf.Shell = "/bin/bash"
 	}
@@ -27,11 +45,15 @@ func Parse(r io.Reader) (*Podfile, error) {
 			pf.Repos[i].Branch = "main"
 		}
 	}
-
-	if err := pf.validate(); err != nil {
-		return nil, err
+	if pf.Build != nil {
+		if pf.Build.Context == "" {
+			pf.Build.Context = "."
+		}
+		if pf.Build.Dockerfile == "" {
+			pf.Build.Dockerfile = "Dockerfile"
+		}
 	}
-	return &pf, nil
+	return pf.validate()
 }
 
 // ParseFile reads and parses a Podfile from a filesystem path.
@@ -47,26 +69,136 @@ func ParseFile(path string) (*Podfile, error) {
 // FindAndRead searches for a Podfile in a project directory and returns
 // the raw bytes. Checks .podspawn/podfile.yaml first, then podfile.yaml.
 func FindAndRead(projectDir string) ([]byte, error) {
-	candidates := []string{
+	data, _, err := readFirst(
 		filepath.Join(projectDir, ".podspawn", "podfile.yaml"),
 		filepath.Join(projectDir, "podfile.yaml"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("no podfile.yaml found in %s", projectDir)
+	}
+	return data, nil
+}
+
+// Source is a project's parsed environment definition.
+type Source struct {
+	Podfile  *Podfile
+	Raw      []byte   // what ComputeTag hashes
+	Path     string   // file it was read from
+	Dir      string   // the project directory; build contexts are relative to it unless inherited
+	Warnings []string // devcontainer.json keys that couldn't be translated
+	Parents  []string // registered projects the extends chain goes through
+	Sudo     bool     // install sudo for the dev user (dev_user.sudo)
+}
+
+// Load reads a project's Podfile, falling back to a devcontainer.json
+// (.devcontainer/devcontainer.json or .devcontainer.json) translated
+// by FromDevcontainer when the repo has no podfile.yaml. An extends
+// chain is resolved through r, which may be nil when only paths inside
+// the repo are used; Raw is then the merged Podfile, so a change to any
+// parent changes the image tag.
+func Load(projectDir string, r *Resolver) (*Source, error) {
+	data, path, err := readFirst(
+		filepath.Join(projectDir, ".podspawn", "podfile.yaml"),
+		filepath.Join(projectDir, "podfile.yaml"),
+	)
+	if err != nil {
+		return nil, err
 	}
-	for _, path := range candidates {
+	if data != nil {
+		pf, err := decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		raw := data
+		var parents []string
+		if pf.Extends != "" {
+			if pf, parents, err = r.resolve(pf, projectDir, []string{path}); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if raw, err = yaml.Marshal(pf); err != nil {
+				return nil, fmt.Errorf("encoding resolved podfile: %w", err)
+			}
+		}
+		if err := pf.finalize(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &Source{Podfile: pf, Raw: raw, Path: path, Dir: projectDir, Parents: parents}, nil
+	}
+
+	data, path, err = readFirst(
+		filepath.Join(projectDir, ".devcontainer", "devcontainer.json"),
+		filepath.Join(projectDir, ".devcontainer.json"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("no podfile.yaml or devcontainer.json found in %s", projectDir)
+	}
+	pf, raw, warnings, err := FromDevcontainer(data, devcontainerDir(projectDir, path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Source{Podfile: pf, Raw: raw, Path: path, Dir: projectDir, Warnings: warnings}, nil
+}
+
+// devcontainerDir is the directory of the devcontainer.json at path,
+// relative to the project directory.
+func devcontainerDir(projectDir, path string) string {
+	rel, err := filepath.Rel(projectDir, filepath.Dir(path))
+	if err != nil {
+		return "."
+	}
+	return filepath.ToSlash(rel)
+}
+
+// insideDir reports whether the relative path p stays inside the
+// directory it is relative to.
+func insideDir(p string) bool {
+	if filepath.IsAbs(p) {
+		return false
+	}
+	p = filepath.Clean(p)
+	return p != ".." && !strings.HasPrefix(p, ".."+string(filepath.Separator))
+}
+
+// readFirst returns the contents and path of the first of paths that
+// exists, or nil if none do.
+func readFirst(paths ...string) ([]byte, string, error) {
+	for _, path := range paths {
 		data, err := os.ReadFile(path)
 		if err == nil {
-			return data, nil
+			return data, path, nil
 		}
 		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("reading %s: %w", path, err)
+			return nil, "", fmt.Errorf("reading %s: %w", path, err)
 		}
 	}
-	return nil, fmt.Errorf("no podfile.yaml found in %s", projectDir)
+	return nil, "", nil
 }
 
 func (pf *Podfile) validate() error {
-	if pf.Base == "" {
+	if pf.Base == "" && pf.Build == nil && pf.Extends == "" {
 		return fmt.Errorf("base image is required")
 	}
+	if pf.Build != nil {
+		if pf.Base != "" {
+			return fmt.Errorf("base and build are mutually exclusive")
+		}
+		if !insideDir(pf.Build.Context) {
+			return fmt.Errorf("build.context must be a path inside the repo, got %q", pf.Build.Context)
+		}
+		if !insideDir(pf.Build.Dockerfile) {
+			return fmt.Errorf("build.dockerfile must be a path inside the context, got %q", pf.Build.Dockerfile)
+		}
+	}
+
+	if pf.Distro != "" && !validDistro(pf.Distro) {
+		return fmt.Errorf("distro must be debian, alpine, fedora or arch, got %q", pf.Distro)
+	}
 
 	if pf.Shell != "" && !strings.HasPrefix(pf.Shell, "/") {
 		return fmt.Errorf("shell must be absolute path, got %q", pf.Shell)
@@ -78,6 +210,10 @@ func (pf *Podfile) validate() error {
 		}
 	}
 
+	if pf.Persist != nil && pf.Persist.Workspace != "" && !strings.HasPrefix(pf.Persist.Workspace, "/") {
+		return fmt.Errorf("persist.workspace must be absolute, got %q", pf.Persist.Workspace)
+	}
+
 	for _, repo := range pf.Repos {
 		if repo.URL == "" {
 			return fmt.Errorf("repo url is required")
@@ -95,6 +231,11 @@ func (pf *Podfile) validate() error {
 		if svc.Image == "" {
 			return fmt.Errorf("service %q: image is required", svc.Name)
 		}
+		if svc.Memory != "" {
+			if _, err := config.ParseMemory(svc.Memory); err != nil {
+				return fmt.Errorf("service %q: invalid memory: %w", svc.Name, err)
+			}
+		}
 		if seen[svc.Name] {
 			return fmt.Errorf("duplicate service name %q", svc.Name)
 		}
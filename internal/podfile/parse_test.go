@@ -178,6 +178,63 @@ repos:
 	}
 }
 
+func TestParsePersist(t *testing.T) {
+	input := `
+base: ubuntu:24.04
+persist:
+  home: true
+  workspace: /workspace
+`
+	pf, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.Persist == nil || !pf.Persist.Home || pf.Persist.Workspace != "/workspace" {
+		t.Errorf("persist = %+v, want home + /workspace", pf.Persist)
+	}
+
+	_, err = Parse(strings.NewReader("base: ubuntu:24.04\npersist:\n  workspace: src\n"))
+	if err == nil || !strings.Contains(err.Error(), "persist.workspace must be absolute") {
+		t.Errorf("expected relative workspace error, got %v", err)
+	}
+}
+
+func TestParseBuild(t *testing.T) {
+	pf, err := Parse(strings.NewReader("build:\n  target: dev\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.Build.Context != "." || pf.Build.Dockerfile != "Dockerfile" {
+		t.Errorf("build = %+v, want context . and Dockerfile defaults", pf.Build)
+	}
+
+	tests := map[string]string{
+		"base: ubuntu:24.04\nbuild: {}\n":         "mutually exclusive",
+		"build:\n  context: ../other\n":           "build.context",
+		"build:\n  dockerfile: /etc/Dockerfile\n": "build.dockerfile",
+	}
+	for input, want := range tests {
+		if _, err := Parse(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want one mentioning %s", input, err, want)
+		}
+	}
+}
+
+func TestParseDistro(t *testing.T) {
+	pf, err := Parse(strings.NewReader("base: alpine:3.20\ndistro: alpine\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.Distro != DistroAlpine {
+		t.Errorf("distro = %q, want alpine", pf.Distro)
+	}
+
+	_, err = Parse(strings.NewReader("base: gentoo/stage3\ndistro: gentoo\n"))
+	if err == nil || !strings.Contains(err.Error(), "distro must be") {
+		t.Errorf("expected distro error, got %v", err)
+	}
+}
+
 func TestParseServiceMissingImage(t *testing.T) {
 	input := `
 base: ubuntu:24.04
@@ -193,6 +250,20 @@ services:
 	}
 }
 
+func TestParseServiceBadMemory(t *testing.T) {
+	input := `
+base: ubuntu:24.04
+services:
+  - name: pg
+    image: postgres:16
+    memory: lots
+`
+	_, err := Parse(strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), `service "pg": invalid memory`) {
+		t.Errorf("error = %v, want invalid service memory", err)
+	}
+}
+
 func TestParseUnknownFieldsIgnored(t *testing.T) {
 	input := `
 base: ubuntu:24.04
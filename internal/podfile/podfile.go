@@ -3,7 +3,12 @@ package podfile
 // Podfile defines a project's dev environment declaratively.
 // Parsed from podfile.yaml in the project root or .podspawn/ directory.
 type Podfile struct {
+	// Extends names a parent Podfile this one is merged onto: a path
+	// inside the repo, "project:<name>" or "template:<name>".
+	Extends       string            `yaml:"extends,omitempty"`
 	Base          string            `yaml:"base"`
+	Build         *BuildConfig      `yaml:"build,omitempty"`  // instead of base
+	Distro        string            `yaml:"distro,omitempty"` // debian, alpine, fedora or arch; detected when empty
 	Packages      []string          `yaml:"packages"`
 	Shell         string            `yaml:"shell"`
 	Dotfiles      *DotfilesConfig   `yaml:"dotfiles"`
@@ -12,17 +17,32 @@ type Podfile struct {
 	Services      []ServiceConfig   `yaml:"services"`
 	Ports         PortsConfig       `yaml:"ports"`
 	Resources     ResourcesConfig   `yaml:"resources"`
+	Persist       *PersistConfig    `yaml:"persist"` // nil = server default
 	OnCreate      string            `yaml:"on_create"`
 	OnStart       string            `yaml:"on_start"`
 	ExtraCommands []string          `yaml:"extra_commands"`
 }
 
+// BuildConfig builds the image from the repo's own Dockerfile instead
+// of base. Packages, env, ports and extra_commands are layered on top,
+// along with the shell and sftp-server sessions need.
+type BuildConfig struct {
+	Context    string            `yaml:"context"`    // relative to the repo root; default "."
+	Dockerfile string            `yaml:"dockerfile"` // relative to the context; default "Dockerfile"
+	Args       map[string]string `yaml:"args"`
+	Target     string            `yaml:"target"` // stage to build on; default the last one
+
+	root string // repo the context is in when inherited through extends; "" = this one
+}
+
 type ServiceConfig struct {
 	Name    string            `yaml:"name"`
 	Image   string            `yaml:"image"`
 	Ports   []int             `yaml:"ports"`
 	Env     map[string]string `yaml:"env"`
 	Volumes []string          `yaml:"volumes"`
+	CPUs    float64           `yaml:"cpus"`   // 0 = unlimited
+	Memory  string            `yaml:"memory"` // e.g. "512m"; empty = unlimited
 }
 
 type DotfilesConfig struct {
@@ -37,8 +57,16 @@ type RepoConfig struct {
 }
 
 type ResourcesConfig struct {
-	CPUs   float64 `yaml:"cpus"`
-	Memory string  `yaml:"memory"`
+	CPUs    float64 `yaml:"cpus"`
+	Memory  string  `yaml:"memory"`
+	Runtime string  `yaml:"runtime"` // OCI runtime for the dev container, e.g. runsc
+}
+
+// PersistConfig selects which paths are backed by named volumes that
+// outlive the container. Replaces the server's defaults.persist wholesale.
+type PersistConfig struct {
+	Home      bool   `yaml:"home"`
+	Workspace string `yaml:"workspace"`
 }
 
 type PortsConfig struct {
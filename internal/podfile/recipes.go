@@ -0,0 +1,231 @@
+package podfile
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BuiltinRecipe is the Source of recipes shipped with podspawn.
+const BuiltinRecipe = "built-in"
+
+//go:embed recipes/*.yaml
+var builtinRecipes embed.FS
+
+// Recipe installs a versioned tool (name@version in packages). Version
+// keys are an exact version or a path.Match pattern such as "3.*" or
+// "*"; the exact key wins, then the longest matching pattern. The
+// version asked for replaces ${VERSION} in the commands.
+type Recipe struct {
+	Name        string                         `yaml:"name"`
+	Description string                         `yaml:"description"`
+	Versions    map[string][]string            `yaml:"versions"` // for any distro
+	Distros     map[string]map[string][]string `yaml:"distros"`  // per distro, replacing versions there
+
+	Source string `yaml:"-"` // BuiltinRecipe or the file it was loaded from
+	raw    []byte // file contents, hashed into image tags for admin recipes
+}
+
+// knownPackages maps tool name to its install recipe: the built-ins,
+// replaced or added to by LoadRecipes.
+var knownPackages = mustBuiltinRecipes()
+
+func mustBuiltinRecipes() map[string]Recipe {
+	recipes, err := readRecipes(builtinRecipes, "recipes", BuiltinRecipe)
+	if err != nil {
+		panic(fmt.Sprintf("built-in package recipes: %v", err))
+	}
+	return recipes
+}
+
+// LoadRecipes reads the *.yaml recipes in dir over the built-ins; a
+// recipe named like a built-in replaces it. A missing dir is not an
+// error. Call it before generating Dockerfiles.
+func LoadRecipes(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	recipes, err := readRecipes(os.DirFS(dir), ".", dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for name, r := range recipes {
+		knownPackages[name] = r
+	}
+	return nil
+}
+
+// Recipes returns the known recipes sorted by name.
+func Recipes() []Recipe {
+	out := make([]Recipe, 0, len(knownPackages))
+	for _, r := range knownPackages {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func readRecipes(fsys fs.FS, dir, source string) (map[string]Recipe, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	recipes := make(map[string]Recipe)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		src := source
+		if source != BuiltinRecipe {
+			src = filepath.Join(source, e.Name())
+		}
+		r, err := parseRecipe(data, strings.TrimSuffix(e.Name(), ".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("recipe %s: %w", src, err)
+		}
+		if prev, dup := recipes[r.Name]; dup {
+			return nil, fmt.Errorf("recipe %s: %s is also defined in %s", src, r.Name, prev.Source)
+		}
+		r.Source = src
+		recipes[r.Name] = r
+	}
+	return recipes, nil
+}
+
+// parseRecipe decodes and validates one recipe file. name defaults to
+// the file's base name.
+func parseRecipe(data []byte, name string) (Recipe, error) {
+	var r Recipe
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil {
+		return Recipe{}, err
+	}
+	if r.Name == "" {
+		r.Name = name
+	}
+	r.raw = data
+
+	if strings.ContainsAny(r.Name, "@ \t") {
+		return Recipe{}, fmt.Errorf("invalid name %q", r.Name)
+	}
+	if len(r.Versions) == 0 && len(r.Distros) == 0 {
+		return Recipe{}, fmt.Errorf("%s: versions or distros is required", r.Name)
+	}
+	if err := validateVersions(r.Versions); err != nil {
+		return Recipe{}, fmt.Errorf("%s: %w", r.Name, err)
+	}
+	for d, versions := range r.Distros {
+		if !validDistro(d) {
+			return Recipe{}, fmt.Errorf("%s: unknown distro %q", r.Name, d)
+		}
+		if err := validateVersions(versions); err != nil {
+			return Recipe{}, fmt.Errorf("%s: distros.%s: %w", r.Name, d, err)
+		}
+	}
+	return r, nil
+}
+
+func validateVersions(versions map[string][]string) error {
+	for v, cmds := range versions {
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("version %q: %w", v, err)
+		}
+		if len(cmds) == 0 {
+			return fmt.Errorf("version %q has no commands", v)
+		}
+	}
+	return nil
+}
+
+// forDistro returns the version map that applies on distro, or nil if
+// the tool has no recipe there.
+func (r Recipe) forDistro(distro string) map[string][]string {
+	if v, ok := r.Distros[distro]; ok {
+		return v
+	}
+	return r.Versions
+}
+
+// DistroNames lists the distros the recipe installs on, or "any".
+func (r Recipe) DistroNames() []string {
+	if len(r.Versions) > 0 {
+		return []string{"any"}
+	}
+	return slices.Sorted(maps.Keys(r.Distros))
+}
+
+// VersionKeys lists the exact versions and patterns across distros.
+func (r Recipe) VersionKeys() []string {
+	var keys []string
+	add := func(versions map[string][]string) {
+		for v := range versions {
+			if !slices.Contains(keys, v) {
+				keys = append(keys, v)
+			}
+		}
+	}
+	add(r.Versions)
+	for _, versions := range r.Distros {
+		add(versions)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recipeDigest is the contents of the packages.d recipes pf's versioned
+// packages use, so editing one rebuilds the images that install it.
+// Built-ins change with podspawn itself and aren't included.
+func recipeDigest(pf *Podfile) []byte {
+	var out []byte
+	for _, spec := range pf.Packages {
+		pkg := ParsePackage(spec)
+		if r, ok := knownPackages[pkg.Name]; ok && pkg.Version != "" && r.Source != BuiltinRecipe {
+			out = append(out, r.raw...)
+		}
+	}
+	return out
+}
+
+// matchVersion returns the commands for version, with ${VERSION}
+// substituted: the exact key if there is one, else the longest pattern
+// that matches.
+func matchVersion(versions map[string][]string, version string) ([]string, bool) {
+	cmds, ok := versions[version]
+	if !ok {
+		best := ""
+		for pattern := range versions {
+			matched, _ := path.Match(pattern, version)
+			if matched && (best == "" || len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+				best = pattern
+			}
+		}
+		if best == "" {
+			return nil, false
+		}
+		cmds = versions[best]
+	}
+	out := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		out[i] = strings.ReplaceAll(cmd, "${VERSION}", version)
+	}
+	return out, true
+}
@@ -0,0 +1,154 @@
+package podfile
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withRecipes restores the built-in recipes after the test.
+func withRecipes(t *testing.T) {
+	t.Helper()
+	saved := maps.Clone(knownPackages)
+	t.Cleanup(func() { knownPackages = saved })
+}
+
+func TestBuiltinRecipes(t *testing.T) {
+	for _, name := range []string{"nodejs", "python", "go", "rust", "java", "ruby", "deno", "bun", "kubectl", "terraform", "uv"} {
+		r, ok := knownPackages[name]
+		if !ok {
+			t.Errorf("missing built-in recipe %s", name)
+			continue
+		}
+		if r.Source != BuiltinRecipe || r.Description == "" {
+			t.Errorf("%s: source = %q, description = %q", name, r.Source, r.Description)
+		}
+	}
+
+	_, special, err := InstallCommands([]Package{{Name: "kubectl", Version: "1.31.0"}}, DistroDebian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(special) != 1 || !strings.Contains(special[0], "/release/v1.31.0/") {
+		t.Errorf("kubectl = %v", special)
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	versions := map[string][]string{
+		"*":    {"any ${VERSION}"},
+		"1.*":  {"one ${VERSION}"},
+		"1.2*": {"one-two ${VERSION}"},
+		"1.5":  {"exact"},
+	}
+	tests := map[string]string{
+		"1.5":   "exact",
+		"1.22":  "one-two 1.22",
+		"1.3":   "one 1.3",
+		"2.0.1": "any 2.0.1",
+	}
+	for version, want := range tests {
+		cmds, ok := matchVersion(versions, version)
+		if !ok || len(cmds) != 1 || cmds[0] != want {
+			t.Errorf("matchVersion(%q) = %v, %v; want [%s]", version, cmds, ok, want)
+		}
+	}
+
+	if _, ok := matchVersion(map[string][]string{"3.*": {"x"}}, "2.7"); ok {
+		t.Error("2.7 should not match 3.*")
+	}
+}
+
+func TestLoadRecipes(t *testing.T) {
+	withRecipes(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "zig.yaml"), "description: Zig\nversions:\n  \"0.*\":\n    - install-zig ${VERSION}\n")
+	writeFile(t, filepath.Join(dir, "go.yaml"), "name: go\nversions:\n  \"1.2*\":\n    - internal-mirror go ${VERSION}\n")
+	writeFile(t, filepath.Join(dir, "README"), "not a recipe")
+
+	if err := LoadRecipes(dir); err != nil {
+		t.Fatal(err)
+	}
+	if r := knownPackages["zig"]; r.Source != filepath.Join(dir, "zig.yaml") {
+		t.Errorf("zig source = %q; name should default to the file name", r.Source)
+	}
+
+	_, special, err := InstallCommands([]Package{{Name: "zig", Version: "0.13.0"}, {Name: "go", Version: "1.23.1"}}, DistroDebian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(special, "\n") != "install-zig 0.13.0\ninternal-mirror go 1.23.1" {
+		t.Errorf("special = %v", special)
+	}
+	// The admin's go recipe replaces the built-in entirely
+	if _, _, err := InstallCommands([]Package{{Name: "go", Version: "2.0"}}, DistroDebian); err == nil {
+		t.Error("expected go@2.0 to be unsupported by the replacement recipe")
+	}
+	if _, ok := knownPackages["rust"]; !ok {
+		t.Error("other built-ins should remain")
+	}
+
+	if err := LoadRecipes(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing dir should be ignored, got %v", err)
+	}
+}
+
+func TestLoadRecipesInvalid(t *testing.T) {
+	tests := []struct {
+		file, content, want string
+	}{
+		{"empty.yaml", "description: nothing\n", "versions or distros is required"},
+		{"distro.yaml", "distros:\n  gentoo:\n    \"*\": [emerge x]\n", "unknown distro"},
+		{"pattern.yaml", "versions:\n  \"[1-\": [x]\n", "syntax error in pattern"},
+		{"nocmds.yaml", "versions:\n  \"1\": []\n", "no commands"},
+		{"typo.yaml", "version:\n  \"1\": [x]\n", "field version not found"},
+	}
+	for _, tt := range tests {
+		withRecipes(t)
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, tt.file), tt.content)
+		err := LoadRecipes(dir)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.file, err, tt.want)
+		}
+	}
+}
+
+func TestTagIncludesAdminRecipes(t *testing.T) {
+	withRecipes(t)
+	dir := t.TempDir()
+	recipe := filepath.Join(dir, "zig.yaml")
+	writeFile(t, recipe, "versions:\n  \"*\": [\"install-zig ${VERSION}\"]\n")
+	if err := LoadRecipes(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := []byte("base: ubuntu:24.04\npackages: [zig@0.13.0, go@1.23.0]\n")
+	src := &Source{Podfile: &Podfile{Base: "ubuntu:24.04", Packages: []string{"zig@0.13.0", "go@1.23.0"}}, Raw: raw}
+	before, err := src.Tag("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(recipe, []byte("versions:\n  \"*\": [\"install-zig --fast ${VERSION}\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadRecipes(dir); err != nil {
+		t.Fatal(err)
+	}
+	after, err := src.Tag("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Error("editing a recipe the podfile uses should change the tag")
+	}
+
+	// Podfiles using only built-ins keep the tag they always had
+	builtinOnly := &Source{Podfile: &Podfile{Base: "ubuntu:24.04", Packages: []string{"go@1.23.0"}}, Raw: raw}
+	if tag, _ := builtinOnly.Tag("p"); tag != ComputeTag("p", raw) {
+		t.Errorf("tag = %s, want %s", tag, ComputeTag("p", raw))
+	}
+}
@@ -6,13 +6,16 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/podspawn/podspawn/internal/config"
 	"github.com/podspawn/podspawn/internal/runtime"
 )
 
 // StartServices creates and starts companion service containers on the
-// given network. Returns container IDs for later cleanup. On partial
-// failure, already-started services are removed before returning the error.
-func StartServices(ctx context.Context, rt runtime.Runtime, services []ServiceConfig, networkID, sessionPrefix string) ([]string, error) {
+// given network, labeled with the session's user so they can be found
+// without the session row. Returns container IDs for later cleanup. On
+// partial failure, already-started services are removed before
+// returning the error.
+func StartServices(ctx context.Context, rt runtime.Runtime, services []ServiceConfig, networkID, sessionPrefix, user string) ([]string, error) {
 	var ids []string
 
 	for _, svc := range services {
@@ -34,15 +37,19 @@ func StartServices(ctx context.Context, rt runtime.Runtime, services []ServiceCo
 			}
 		}
 
+		memory, _ := config.ParseMemory(svc.Memory) // validated by the parser
 		id, err := rt.CreateContainer(ctx, runtime.ContainerOpts{
 			Name:        name,
 			Image:       svc.Image,
+			CPUs:        svc.CPUs,
+			Memory:      memory,
 			NetworkID:   networkID,
 			NetworkName: svc.Name,
 			Env:         env,
 			Mounts:      mounts,
 			Labels: map[string]string{
 				"managed-by":       "podspawn",
+				"podspawn-user":    user,
 				"podspawn-service": svc.Name,
 			},
 		})
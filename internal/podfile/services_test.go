@@ -15,7 +15,7 @@ func TestStartServicesTwoServices(t *testing.T) {
 		{Name: "redis", Image: "redis:7"},
 	}
 
-	ids, err := StartServices(context.Background(), rt, services, "net-123", "podspawn-deploy-backend")
+	ids, err := StartServices(context.Background(), rt, services, "net-123", "podspawn-deploy-backend", "deploy")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -31,6 +31,9 @@ func TestStartServicesTwoServices(t *testing.T) {
 	if rt.CreateCalls[1].Image != "redis:7" {
 		t.Errorf("service[1] image = %q", rt.CreateCalls[1].Image)
 	}
+	if labels := rt.CreateCalls[0].Labels; labels["podspawn-user"] != "deploy" || labels["podspawn-service"] != "postgres" {
+		t.Errorf("service[0] labels = %v, want podspawn-user and podspawn-service", labels)
+	}
 }
 
 func TestStartServicesNetworkAlias(t *testing.T) {
@@ -39,7 +42,7 @@ func TestStartServicesNetworkAlias(t *testing.T) {
 		{Name: "postgres", Image: "postgres:16"},
 	}
 
-	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix")
+	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix", "deploy")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,7 +64,7 @@ func TestStartServicesEnvPassthrough(t *testing.T) {
 		},
 	}
 
-	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix")
+	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix", "deploy")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -76,6 +79,18 @@ func TestStartServicesEnvPassthrough(t *testing.T) {
 	}
 }
 
+func TestStartServicesResourceLimits(t *testing.T) {
+	rt := runtime.NewFakeRuntime()
+	services := []ServiceConfig{{Name: "postgres", Image: "postgres:16", CPUs: 0.5, Memory: "512m"}}
+
+	if _, err := StartServices(context.Background(), rt, services, "net-123", "prefix", "deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if opts := rt.CreateCalls[0]; opts.CPUs != 0.5 || opts.Memory != 512<<20 {
+		t.Errorf("limits = %g CPUs, %d bytes, want 0.5 and 512m", opts.CPUs, opts.Memory)
+	}
+}
+
 func TestStartServicesCreateFailure(t *testing.T) {
 	rt := runtime.NewFakeRuntime()
 	services := []ServiceConfig{
@@ -83,7 +98,7 @@ func TestStartServicesCreateFailure(t *testing.T) {
 	}
 
 	rt.CreateErr = fmt.Errorf("image not found")
-	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix")
+	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix", "deploy")
 	if err == nil {
 		t.Fatal("expected error on create failure")
 	}
@@ -114,7 +129,7 @@ func TestStartServicesWithVolumes(t *testing.T) {
 		},
 	}
 
-	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix")
+	_, err := StartServices(context.Background(), rt, services, "net-123", "prefix", "deploy")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -135,7 +150,7 @@ func TestStartServicesWithVolumes(t *testing.T) {
 
 func TestStartServicesEmpty(t *testing.T) {
 	rt := runtime.NewFakeRuntime()
-	ids, err := StartServices(context.Background(), rt, nil, "net-123", "prefix")
+	ids, err := StartServices(context.Background(), rt, nil, "net-123", "prefix", "deploy")
 	if err != nil {
 		t.Fatal(err)
 	}
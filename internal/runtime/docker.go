@@ -4,21 +4,29 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"time"
 
 	"log/slog"
 
 	"encoding/json"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/build"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
 )
 
 type DockerRuntime struct {
@@ -72,9 +80,17 @@ func (d *DockerRuntime) CreateContainer(ctx context.Context, opts ContainerOpts)
 	if opts.Memory > 0 {
 		hostCfg.Memory = opts.Memory
 	}
+	hostCfg.Runtime = opts.Runtime
+	if err := applySecurity(hostCfg, opts); err != nil {
+		return "", err
+	}
 	for _, m := range opts.Mounts {
+		mountType := mount.TypeBind
+		if m.Type == "volume" {
+			mountType = mount.TypeVolume
+		}
 		hostCfg.Mounts = append(hostCfg.Mounts, mount.Mount{
-			Type:     mount.TypeBind,
+			Type:     mountType,
 			Source:   m.Source,
 			Target:   m.Target,
 			ReadOnly: m.ReadOnly,
@@ -93,12 +109,13 @@ func (d *DockerRuntime) CreateContainer(ctx context.Context, opts ContainerOpts)
 	}
 
 	resp, err := d.cli.ContainerCreate(ctx, &container.Config{
-		Image:     opts.Image,
-		Cmd:       opts.Cmd,
-		Env:       opts.Env,
-		Labels:    opts.Labels,
-		OpenStdin: true,
-		Tty:       false,
+		Image:      opts.Image,
+		Entrypoint: opts.Entrypoint,
+		Cmd:        opts.Cmd,
+		Env:        opts.Env,
+		Labels:     opts.Labels,
+		OpenStdin:  true,
+		Tty:        false,
 	}, hostCfg, networkCfg, nil, opts.Name)
 	if err != nil {
 		return "", fmt.Errorf("creating container %s: %w", opts.Name, err)
@@ -106,6 +123,38 @@ func (d *DockerRuntime) CreateContainer(ctx context.Context, opts ContainerOpts)
 	return resp.ID, nil
 }
 
+func applySecurity(hostCfg *container.HostConfig, opts ContainerOpts) error {
+	hostCfg.CapDrop = opts.CapDrop
+	hostCfg.CapAdd = opts.CapAdd
+	if opts.NoNewPrivileges {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "no-new-privileges:true")
+	}
+	if opts.PidsLimit > 0 {
+		limit := opts.PidsLimit
+		hostCfg.PidsLimit = &limit
+	}
+	hostCfg.ReadonlyRootfs = opts.ReadOnlyRootfs
+	hostCfg.Tmpfs = opts.Tmpfs
+	switch opts.SeccompProfile {
+	case "":
+	case "unconfined":
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "seccomp=unconfined")
+	default:
+		// The API takes the profile itself, not a path (the docker CLI
+		// reads the file client-side too)
+		profile, err := os.ReadFile(opts.SeccompProfile)
+		if err != nil {
+			return fmt.Errorf("reading seccomp profile: %w", err)
+		}
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "seccomp="+string(profile))
+	}
+	if opts.AppArmorProfile != "" {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "apparmor="+opts.AppArmorProfile)
+	}
+	hostCfg.UsernsMode = container.UsernsMode(opts.UsernsMode)
+	return nil
+}
+
 func (d *DockerRuntime) StartContainer(ctx context.Context, id string) error {
 	if err := d.cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
 		return fmt.Errorf("starting container %s: %w", id, err)
@@ -116,6 +165,9 @@ func (d *DockerRuntime) StartContainer(ctx context.Context, id string) error {
 func (d *DockerRuntime) Exec(ctx context.Context, containerID string, opts ExecOpts) (int, error) {
 	execCfg := container.ExecOptions{
 		Cmd:          opts.Cmd,
+		Env:          opts.Env,
+		User:         opts.User,
+		WorkingDir:   opts.WorkingDir,
 		Tty:          opts.TTY,
 		AttachStdin:  opts.Stdin != nil,
 		AttachStdout: true,
@@ -188,6 +240,52 @@ func (d *DockerRuntime) ResizeExec(ctx context.Context, execID string, height, w
 	})
 }
 
+func (d *DockerRuntime) ListContainers(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	list, err := d.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: labelFilters(labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	out := make([]ContainerInfo, 0, len(list))
+	for _, c := range list {
+		var name string
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		out = append(out, ContainerInfo{
+			ID:      c.ID,
+			Name:    name,
+			State:   string(c.State),
+			Labels:  c.Labels,
+			Created: time.Unix(c.Created, 0),
+		})
+	}
+	return out, nil
+}
+
+func labelFilters(labels map[string]string) filters.Args {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	return args
+}
+
+func (d *DockerRuntime) ListRuntimes(ctx context.Context) ([]string, error) {
+	info, err := d.cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading daemon info: %w", err)
+	}
+	names := make([]string, 0, len(info.Runtimes))
+	for name := range info.Runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 func (d *DockerRuntime) ImageExists(ctx context.Context, ref string) (bool, error) {
 	_, err := d.cli.ImageInspect(ctx, ref)
 	if err != nil {
@@ -199,14 +297,31 @@ func (d *DockerRuntime) ImageExists(ctx context.Context, ref string) (bool, erro
 	return true, nil
 }
 
-func (d *DockerRuntime) BuildImage(ctx context.Context, buildCtx io.Reader, tag string) error {
+func (d *DockerRuntime) ImageLabels(ctx context.Context, ref string) (map[string]string, error) {
+	img, err := d.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting image %s: %w", ref, err)
+	}
+	if img.Config == nil {
+		return nil, nil
+	}
+	return img.Config.Labels, nil
+}
+
+func (d *DockerRuntime) BuildImage(ctx context.Context, buildCtx io.Reader, opts BuildOpts) error {
+	args := make(map[string]*string, len(opts.Args))
+	for k, v := range opts.Args {
+		args[k] = &v
+	}
 	resp, err := d.cli.ImageBuild(ctx, buildCtx, build.ImageBuildOptions{
-		Tags:        []string{tag},
+		Tags:        []string{opts.Tag},
+		Dockerfile:  opts.Dockerfile,
+		BuildArgs:   args,
 		Remove:      true,
 		ForceRemove: true,
 	})
 	if err != nil {
-		return fmt.Errorf("building image %s: %w", tag, err)
+		return fmt.Errorf("building image %s: %w", opts.Tag, err)
 	}
 	defer resp.Body.Close() //nolint:errcheck
 	return consumeBuildOutput(resp.Body)
@@ -233,6 +348,137 @@ func consumeBuildOutput(r io.Reader) error {
 	}
 }
 
+// maxSnapshotLayers is how deep a snapshot's layer chain may get
+// before CommitContainer squashes it. Each hibernate of a restored
+// snapshot adds a layer, and overlay2 refuses images past 128.
+const maxSnapshotLayers = 100
+
+// CommitContainer snapshots the container with docker commit, so the
+// image shares its base layers with every other snapshot of the same
+// image. Once the chain reaches maxSnapshotLayers the filesystem is
+// squashed into a single layer instead.
+func (d *DockerRuntime) CommitContainer(ctx context.Context, id, ref string, labels map[string]string) error {
+	ctr, err := d.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("inspecting container %s: %w", id, err)
+	}
+	img, err := d.cli.ImageInspect(ctx, ctr.Image)
+	if err != nil {
+		return fmt.Errorf("inspecting image of %s: %w", id, err)
+	}
+	if len(img.RootFS.Layers) >= maxSnapshotLayers {
+		slog.Info("squashing snapshot", "container", id, "layers", len(img.RootFS.Layers))
+		return d.squashContainer(ctx, id, ref, img.Config, labels)
+	}
+
+	var changes []string
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		changes = append(changes, "LABEL "+k+"="+dockerfileQuote(labels[k]))
+	}
+	if _, err := d.cli.ContainerCommit(ctx, id, container.CommitOptions{
+		Reference: ref,
+		Comment:   "podspawn session snapshot",
+		Changes:   changes,
+		Pause:     true,
+	}); err != nil {
+		return fmt.Errorf("committing container %s: %w", id, err)
+	}
+	return nil
+}
+
+// squashContainer exports the container's filesystem and imports it as
+// a single-layer image, carrying over the image config.
+func (d *DockerRuntime) squashContainer(ctx context.Context, id, ref string, cfg *dockerspec.DockerOCIImageConfig, labels map[string]string) error {
+	changes, err := importChanges(cfg, labels)
+	if err != nil {
+		return err
+	}
+
+	if err := d.cli.ContainerPause(ctx, id); err != nil {
+		return fmt.Errorf("pausing container %s: %w", id, err)
+	}
+	defer d.cli.ContainerUnpause(context.Background(), id) //nolint:errcheck
+
+	rootfs, err := d.cli.ContainerExport(ctx, id)
+	if err != nil {
+		return fmt.Errorf("exporting container %s: %w", id, err)
+	}
+	defer rootfs.Close() //nolint:errcheck
+
+	resp, err := d.cli.ImageImport(ctx, image.ImportSource{Source: rootfs, SourceName: "-"}, ref, image.ImportOptions{
+		Message: "podspawn session snapshot (squashed)",
+		Changes: changes,
+	})
+	if err != nil {
+		return fmt.Errorf("committing container %s: %w", id, err)
+	}
+	defer resp.Close() //nolint:errcheck
+	if err := consumeBuildOutput(resp); err != nil {
+		return fmt.Errorf("committing container %s: %w", id, err)
+	}
+	return nil
+}
+
+// importChanges turns an image config into the Dockerfile instructions
+// an import needs to reproduce it, with labels added.
+func importChanges(cfg *dockerspec.DockerOCIImageConfig, labels map[string]string) ([]string, error) {
+	all := make(map[string]string)
+	var changes []string
+	if cfg != nil {
+		for _, e := range cfg.Env {
+			k, v, _ := strings.Cut(e, "=")
+			changes = append(changes, "ENV "+k+"="+dockerfileQuote(v))
+		}
+		for _, cmd := range []struct {
+			instr string
+			args  []string
+		}{{"ENTRYPOINT", cfg.Entrypoint}, {"CMD", cfg.Cmd}} {
+			if len(cmd.args) == 0 {
+				continue
+			}
+			js, err := json.Marshal(cmd.args)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, cmd.instr+" "+string(js))
+		}
+		if cfg.WorkingDir != "" {
+			changes = append(changes, "WORKDIR "+cfg.WorkingDir)
+		}
+		if cfg.User != "" {
+			changes = append(changes, "USER "+cfg.User)
+		}
+		for p := range cfg.ExposedPorts {
+			changes = append(changes, "EXPOSE "+p)
+		}
+		if cfg.StopSignal != "" {
+			changes = append(changes, "STOPSIGNAL "+cfg.StopSignal)
+		}
+		maps.Copy(all, cfg.Labels)
+	}
+	maps.Copy(all, labels)
+	for _, k := range slices.Sorted(maps.Keys(all)) {
+		changes = append(changes, "LABEL "+k+"="+dockerfileQuote(all[k]))
+	}
+	return changes, nil
+}
+
+// dockerfileQuote double-quotes s for an ENV or LABEL value. Inside
+// Dockerfile double quotes only backslash, the quote and $ (variable
+// expansion) are special. A change is a single line, so a newline
+// can't be written at all and becomes a space.
+func dockerfileQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", " ")
+	return `"` + r.Replace(s) + `"`
+}
+
+func (d *DockerRuntime) RemoveImage(ctx context.Context, ref string) error {
+	if _, err := d.cli.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+		return fmt.Errorf("removing image %s: %w", ref, err)
+	}
+	return nil
+}
+
 func (d *DockerRuntime) CreateNetwork(ctx context.Context, name string) (string, error) {
 	resp, err := d.cli.NetworkCreate(ctx, name, network.CreateOptions{
 		Driver: "bridge",
@@ -250,3 +496,75 @@ func (d *DockerRuntime) RemoveNetwork(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func (d *DockerRuntime) ListNetworks(ctx context.Context, labels map[string]string) ([]NetworkInfo, error) {
+	list, err := d.cli.NetworkList(ctx, network.ListOptions{Filters: labelFilters(labels)})
+	if err != nil {
+		return nil, fmt.Errorf("listing networks: %w", err)
+	}
+	out := make([]NetworkInfo, 0, len(list))
+	for _, n := range list {
+		out = append(out, NetworkInfo{
+			ID:      n.ID,
+			Name:    n.Name,
+			Labels:  n.Labels,
+			Created: n.Created,
+		})
+	}
+	return out, nil
+}
+
+func (d *DockerRuntime) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	_, err := d.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Driver: "local",
+		Labels: labels,
+	})
+	if err != nil {
+		return fmt.Errorf("creating volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *DockerRuntime) ListVolumes(ctx context.Context, labels map[string]string, withUsage bool) ([]VolumeInfo, error) {
+	var vols []*volume.Volume
+	if withUsage {
+		// Only /system/df reports sizes; it can't filter, so filter here.
+		du, err := d.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+		if err != nil {
+			return nil, fmt.Errorf("reading volume usage: %w", err)
+		}
+		for _, v := range du.Volumes {
+			if matchLabels(v.Labels, labels) {
+				vols = append(vols, v)
+			}
+		}
+	} else {
+		resp, err := d.cli.VolumeList(ctx, volume.ListOptions{Filters: labelFilters(labels)})
+		if err != nil {
+			return nil, fmt.Errorf("listing volumes: %w", err)
+		}
+		vols = resp.Volumes
+	}
+
+	out := make([]VolumeInfo, 0, len(vols))
+	for _, v := range vols {
+		info := VolumeInfo{Name: v.Name, Labels: v.Labels, Size: -1, RefCount: -1}
+		if created, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil {
+			info.CreatedAt = created
+		}
+		if v.UsageData != nil {
+			info.Size = v.UsageData.Size
+			info.RefCount = v.UsageData.RefCount
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (d *DockerRuntime) RemoveVolume(ctx context.Context, name string) error {
+	if err := d.cli.VolumeRemove(ctx, name, false); err != nil {
+		return fmt.Errorf("removing volume %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package runtime
+
+import (
+	"slices"
+	"testing"
+
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+)
+
+func TestImportChangesQuoting(t *testing.T) {
+	cfg := &dockerspec.DockerOCIImageConfig{}
+	cfg.Env = []string{`GREETING=hello "big" world`, `PRICE=$5 \ each`, "EMPTY="}
+	got, err := importChanges(cfg, map[string]string{"podspawn-user": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`ENV GREETING="hello \"big\" world"`,
+		`ENV PRICE="\$5 \\ each"`,
+		`ENV EMPTY=""`,
+		`LABEL podspawn-user="alice"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("changes =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,21 +14,33 @@ import (
 type FakeRuntime struct {
 	mu sync.Mutex
 
-	Containers  map[string]bool // name → running
-	CreateCalls []ContainerOpts
-	ExecCalls   []FakeExecCall
-	ExitCode    int // returned by Exec
-	ExecErr     error
-	CreateErr   error
-	StartErr    error
+	Containers      map[string]bool              // name → running
+	ContainerLabels map[string]map[string]string // name → labels, set by CreateContainer
+	CreateCalls     []ContainerOpts
+	ExecCalls       []FakeExecCall
+	ExitCode        int    // returned by Exec
+	ExecStdout      string // written to the Stdout of every Exec
+	ExecErr         error
+	CreateErr       error
+	StartErr        error
+	OCIRuntimes     []string // returned by ListRuntimes
 
 	Images             map[string]bool
-	BuildCalls         []string // tags passed to BuildImage
+	ImageLabelSets     map[string]map[string]string // ref → labels, set by CommitContainer
+	BuildCalls         []string                     // tags passed to BuildImage
+	Builds             []BuildOpts
+	BuildContexts      [][]byte // tar streams passed to BuildImage
 	BuildErr           error
+	CommitCalls        []string // refs passed to CommitContainer
+	CommitErr          error
+	RemoveImageCalls   []string
 	Networks           map[string]bool
 	CreateNetworkCalls []string
 	RemoveNetworkCalls []string
 	networkCounter     int
+	Volumes            map[string]map[string]string // name → labels
+	RemoveVolumeCalls  []string
+	created            map[string]time.Time // CreateContainer time; zero for pre-seeded containers
 }
 
 type FakeExecCall struct {
@@ -37,9 +50,14 @@ type FakeExecCall struct {
 
 func NewFakeRuntime() *FakeRuntime {
 	return &FakeRuntime{
-		Containers: make(map[string]bool),
-		Images:     make(map[string]bool),
-		Networks:   make(map[string]bool),
+		Containers:      make(map[string]bool),
+		OCIRuntimes:     []string{"runc"},
+		ContainerLabels: make(map[string]map[string]string),
+		created:         make(map[string]time.Time),
+		Images:          make(map[string]bool),
+		ImageLabelSets:  make(map[string]map[string]string),
+		Networks:        make(map[string]bool),
+		Volumes:         make(map[string]map[string]string),
 	}
 }
 
@@ -60,6 +78,8 @@ func (f *FakeRuntime) CreateContainer(_ context.Context, opts ContainerOpts) (st
 	}
 	f.CreateCalls = append(f.CreateCalls, opts)
 	f.Containers[opts.Name] = false
+	f.ContainerLabels[opts.Name] = opts.Labels
+	f.created[opts.Name] = time.Now()
 	return opts.Name, nil
 }
 
@@ -78,12 +98,16 @@ func (f *FakeRuntime) Exec(_ context.Context, containerID string, opts ExecOpts)
 	f.ExecCalls = append(f.ExecCalls, FakeExecCall{ContainerID: containerID, Opts: opts})
 	exitCode := f.ExitCode
 	execErr := f.ExecErr
+	stdout := f.ExecStdout
 	cb := opts.ExecIDCallback
 	f.mu.Unlock()
 
 	if cb != nil {
 		cb("fake-exec-id")
 	}
+	if stdout != "" && opts.Stdout != nil {
+		io.WriteString(opts.Stdout, stdout) //nolint:errcheck
+	}
 
 	return exitCode, execErr
 }
@@ -99,6 +123,8 @@ func (f *FakeRuntime) RemoveContainer(_ context.Context, id string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	delete(f.Containers, id)
+	delete(f.ContainerLabels, id)
+	delete(f.created, id)
 	return nil
 }
 
@@ -106,20 +132,94 @@ func (f *FakeRuntime) ResizeExec(_ context.Context, _ string, _, _ uint) error {
 	return nil
 }
 
+// ListContainers returns containers whose recorded labels match. The
+// container name doubles as its ID, matching CreateContainer.
+func (f *FakeRuntime) ListContainers(_ context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []ContainerInfo
+	for name, running := range f.Containers {
+		if !matchLabels(f.ContainerLabels[name], labels) {
+			continue
+		}
+		st := "created"
+		if running {
+			st = "running"
+		}
+		out = append(out, ContainerInfo{
+			ID:      name,
+			Name:    name,
+			State:   st,
+			Labels:  f.ContainerLabels[name],
+			Created: f.created[name],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (f *FakeRuntime) ListRuntimes(_ context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.OCIRuntimes...), nil
+}
+
 func (f *FakeRuntime) ImageExists(_ context.Context, ref string) (bool, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	return f.Images[ref], nil
 }
 
-func (f *FakeRuntime) BuildImage(_ context.Context, _ io.Reader, tag string) error {
+func (f *FakeRuntime) ImageLabels(_ context.Context, ref string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.Images[ref] {
+		return nil, fmt.Errorf("image %s not found", ref)
+	}
+	return f.ImageLabelSets[ref], nil
+}
+
+func (f *FakeRuntime) BuildImage(_ context.Context, buildCtx io.Reader, opts BuildOpts) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.BuildErr != nil {
 		return f.BuildErr
 	}
-	f.BuildCalls = append(f.BuildCalls, tag)
-	f.Images[tag] = true
+	data, err := io.ReadAll(buildCtx)
+	if err != nil {
+		return err
+	}
+	f.BuildCalls = append(f.BuildCalls, opts.Tag)
+	f.Builds = append(f.Builds, opts)
+	f.BuildContexts = append(f.BuildContexts, data)
+	f.Images[opts.Tag] = true
+	return nil
+}
+
+func (f *FakeRuntime) CommitContainer(_ context.Context, id, ref string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.CommitErr != nil {
+		return f.CommitErr
+	}
+	if _, ok := f.Containers[id]; !ok {
+		return fmt.Errorf("container %s not found", id)
+	}
+	f.CommitCalls = append(f.CommitCalls, ref)
+	f.Images[ref] = true
+	f.ImageLabelSets[ref] = labels
+	return nil
+}
+
+func (f *FakeRuntime) RemoveImage(_ context.Context, ref string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.Images[ref] {
+		return fmt.Errorf("image %s not found", ref)
+	}
+	f.RemoveImageCalls = append(f.RemoveImageCalls, ref)
+	delete(f.Images, ref)
+	delete(f.ImageLabelSets, ref)
 	return nil
 }
 
@@ -140,3 +240,54 @@ func (f *FakeRuntime) RemoveNetwork(_ context.Context, id string) error {
 	f.RemoveNetworkCalls = append(f.RemoveNetworkCalls, id)
 	return nil
 }
+
+// ListNetworks returns all networks created through CreateNetwork, which
+// are labeled managed-by=podspawn like the Docker implementation.
+func (f *FakeRuntime) ListNetworks(_ context.Context, labels map[string]string) ([]NetworkInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	netLabels := map[string]string{"managed-by": "podspawn"}
+	if !matchLabels(netLabels, labels) {
+		return nil, nil
+	}
+	var out []NetworkInfo
+	for id := range f.Networks {
+		out = append(out, NetworkInfo{ID: id, Name: id, Labels: netLabels})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (f *FakeRuntime) CreateVolume(_ context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Volumes[name]; !ok {
+		f.Volumes[name] = labels
+	}
+	return nil
+}
+
+// ListVolumes reports Size and RefCount as -1 regardless of withUsage.
+func (f *FakeRuntime) ListVolumes(_ context.Context, labels map[string]string, _ bool) ([]VolumeInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []VolumeInfo
+	for name, l := range f.Volumes {
+		if matchLabels(l, labels) {
+			out = append(out, VolumeInfo{Name: name, Labels: l, Size: -1, RefCount: -1})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (f *FakeRuntime) RemoveVolume(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Volumes[name]; !ok {
+		return fmt.Errorf("no such volume %s", name)
+	}
+	delete(f.Volumes, name)
+	f.RemoveVolumeCalls = append(f.RemoveVolumeCalls, name)
+	return nil
+}
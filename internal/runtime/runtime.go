@@ -9,28 +9,45 @@ import (
 type ContainerOpts struct {
 	Name        string
 	Image       string
+	Entrypoint  []string // nil = image default
 	Cmd         []string
 	Env         []string
 	Mounts      []Mount
 	CPUs        float64
 	Memory      int64
+	Runtime     string // OCI runtime, e.g. "runsc"; empty = daemon default
 	Labels      map[string]string
 	NetworkID   string // Docker network to attach to
 	NetworkName string // DNS alias on the network
+
+	// Hardening. Zero values keep Docker's defaults.
+	CapDrop         []string
+	CapAdd          []string
+	NoNewPrivileges bool
+	PidsLimit       int64 // 0 = unlimited
+	ReadOnlyRootfs  bool
+	Tmpfs           map[string]string // mount point → options
+	SeccompProfile  string            // host path to a JSON profile, or "unconfined"
+	AppArmorProfile string
+	UsernsMode      string // "host" opts out of daemon userns remapping
 }
 
 type Mount struct {
+	Type     string // "bind" (default) | "volume"; for volumes Source is the volume name
 	Source   string
 	Target   string
 	ReadOnly bool
 }
 
 type ExecOpts struct {
-	Cmd    []string
-	TTY    bool
-	Stdin  io.Reader
-	Stdout io.Writer
-	Stderr io.Writer
+	Cmd        []string
+	Env        []string // KEY=value, added to the container's environment
+	User       string   // user[:group] to run as; empty = image default
+	WorkingDir string   // empty = image default
+	TTY        bool
+	Stdin      io.Reader
+	Stdout     io.Writer
+	Stderr     io.Writer
 
 	// ExecIDCallback is called with the exec ID before I/O piping
 	// starts. Spawn uses this to set up terminal resize handling
@@ -38,6 +55,39 @@ type ExecOpts struct {
 	ExecIDCallback func(execID string)
 }
 
+// BuildOpts configures BuildImage.
+type BuildOpts struct {
+	Tag        string
+	Dockerfile string            // path inside the build context; empty = Dockerfile
+	Args       map[string]string // build args
+}
+
+// ContainerInfo is a summary of a container returned by ListContainers.
+type ContainerInfo struct {
+	ID      string
+	Name    string
+	State   string // "running", "exited", "created", ...
+	Labels  map[string]string
+	Created time.Time
+}
+
+// NetworkInfo is a summary of a network returned by ListNetworks.
+type NetworkInfo struct {
+	ID      string
+	Name    string
+	Labels  map[string]string
+	Created time.Time
+}
+
+// VolumeInfo is a summary of a named volume returned by ListVolumes.
+type VolumeInfo struct {
+	Name      string
+	Labels    map[string]string
+	CreatedAt time.Time
+	Size      int64 // bytes on disk; -1 when unknown or usage wasn't requested
+	RefCount  int64 // containers using the volume; -1 when unknown
+}
+
 type Runtime interface {
 	ContainerExists(ctx context.Context, name string) (bool, error)
 	CreateContainer(ctx context.Context, opts ContainerOpts) (string, error)
@@ -46,9 +96,29 @@ type Runtime interface {
 	StopContainer(ctx context.Context, id string, timeout time.Duration) error
 	RemoveContainer(ctx context.Context, id string) error
 	ResizeExec(ctx context.Context, execID string, height, width uint) error
+	ListContainers(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) // includes stopped containers
+	ListRuntimes(ctx context.Context) ([]string, error)                                    // OCI runtimes registered with the daemon
 
-	BuildImage(ctx context.Context, buildCtx io.Reader, tag string) error
+	BuildImage(ctx context.Context, buildCtx io.Reader, opts BuildOpts) error
 	ImageExists(ctx context.Context, ref string) (bool, error)
+	ImageLabels(ctx context.Context, ref string) (map[string]string, error)
+	CommitContainer(ctx context.Context, id, ref string, labels map[string]string) error // pauses the container
+	RemoveImage(ctx context.Context, ref string) error
 	CreateNetwork(ctx context.Context, name string) (string, error)
 	RemoveNetwork(ctx context.Context, id string) error
+	ListNetworks(ctx context.Context, labels map[string]string) ([]NetworkInfo, error)
+
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error // no-op if it exists
+	ListVolumes(ctx context.Context, labels map[string]string, withUsage bool) ([]VolumeInfo, error)
+	RemoveVolume(ctx context.Context, name string) error
+}
+
+// matchLabels reports whether have contains every key/value in want.
+func matchLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
 }
@@ -18,7 +18,9 @@ type Paths struct {
 	KeyDir       string
 	StateDir     string
 	LockDir      string
+	AgentDir     string
 	EmergencyKey string
+	AuditLog     string // empty = audit log disabled
 }
 
 func DefaultPaths(binaryPath string) Paths {
@@ -29,7 +31,9 @@ func DefaultPaths(binaryPath string) Paths {
 		KeyDir:       "/etc/podspawn/keys",
 		StateDir:     "/var/lib/podspawn",
 		LockDir:      "/var/lib/podspawn/locks",
+		AgentDir:     "/var/lib/podspawn/agent",
 		EmergencyKey: "/etc/podspawn/emergency.keys",
+		AuditLog:     "/var/log/podspawn/audit.jsonl",
 	}
 }
 
@@ -73,8 +77,11 @@ func Run(paths Paths, cmd Commander, opts Options, out io.Writer) (retErr error)
 	}
 
 	if opts.DryRun {
-		fmt.Fprintln(out, "[dry-run] would create directories:", paths.PodspawnDir, paths.KeyDir, paths.StateDir, paths.LockDir) //nolint:errcheck
-		fmt.Fprintln(out, "[dry-run] would create", paths.EmergencyKey, "(if missing)")                                          //nolint:errcheck
+		fmt.Fprintln(out, "[dry-run] would create directories:", paths.PodspawnDir, paths.KeyDir, paths.StateDir, paths.LockDir, paths.AgentDir) //nolint:errcheck
+		fmt.Fprintln(out, "[dry-run] would create", paths.EmergencyKey, "(if missing)")                                                          //nolint:errcheck
+		if paths.AuditLog != "" {
+			fmt.Fprintln(out, "[dry-run] would create", paths.AuditLog, "(if missing)") //nolint:errcheck
+		}
 		svc := opts.ServiceName
 		if svc == "" {
 			svc = "<auto-detected>"
@@ -164,12 +171,51 @@ func createDirectories(paths Paths) error {
 		}
 	}
 
+	// Every user's spawn creates its own 0700 session directory here, so
+	// it's world-writable with the sticky bit, like /tmp. Chmod because
+	// MkdirAll's mode is subject to the umask.
+	if err := os.MkdirAll(paths.AgentDir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", paths.AgentDir, err)
+	}
+	if err := os.Chmod(paths.AgentDir, os.ModeSticky|0777); err != nil {
+		return fmt.Errorf("setting permissions on %s: %w", paths.AgentDir, err)
+	}
+
+	// Break-glass keys, one user= per key. Public keys, and
+	// auth-keys reads them as the AuthorizedKeysCommandUser, hence 0644.
 	if _, err := os.Stat(paths.EmergencyKey); errors.Is(err, fs.ErrNotExist) {
-		if err := os.WriteFile(paths.EmergencyKey, nil, 0600); err != nil {
+		if err := os.WriteFile(paths.EmergencyKey, nil, 0644); err != nil {
 			return fmt.Errorf("creating %s: %w", paths.EmergencyKey, err)
 		}
 	}
 
+	return createAuditLog(paths.AuditLog)
+}
+
+// createAuditLog creates the audit log so both auth-keys (running as
+// the AuthorizedKeysCommandUser) and spawn (running as the SSH user)
+// can append to it. None of its writers are privileged, so the file is
+// 0666 in a root-owned directory: it can be written but not replaced,
+// and edits are caught by the hash chain, not the file mode.
+func createAuditLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return fmt.Errorf("setting permissions on %s: %w", dir, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	f.Close() //nolint:errcheck
+	if err := os.Chmod(path, 0666); err != nil {
+		return fmt.Errorf("setting permissions on %s: %w", path, err)
+	}
 	return nil
 }
 
@@ -20,7 +20,9 @@ func testPaths(t *testing.T) Paths {
 		KeyDir:       filepath.Join(root, "etc", "podspawn", "keys"),
 		StateDir:     filepath.Join(root, "var", "lib", "podspawn"),
 		LockDir:      filepath.Join(root, "var", "lib", "podspawn", "locks"),
+		AgentDir:     filepath.Join(root, "var", "lib", "podspawn", "agent"),
 		EmergencyKey: filepath.Join(root, "etc", "podspawn", "emergency.keys"),
+		AuditLog:     filepath.Join(root, "var", "log", "podspawn", "audit.jsonl"),
 	}
 }
 
@@ -199,11 +201,40 @@ func TestCreatesDirs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	for _, dir := range []string{paths.PodspawnDir, paths.KeyDir, paths.StateDir, paths.LockDir} {
+	for _, dir := range []string{paths.PodspawnDir, paths.KeyDir, paths.StateDir, paths.LockDir, paths.AgentDir} {
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
 			t.Errorf("directory not created: %s", dir)
 		}
 	}
+	if info, err := os.Stat(paths.AgentDir); err == nil && info.Mode()&os.ModeSticky == 0 {
+		t.Errorf("agent dir mode = %v, want sticky bit", info.Mode())
+	}
+}
+
+func TestCreatesAuditLogWritableByUnprivilegedWriters(t *testing.T) {
+	paths := testPaths(t)
+	writeSSHDConfig(t, paths.SSHDConfig, minimalSSHDConfig)
+	// A log created earlier by a root admin command, with Append's mode
+	os.MkdirAll(filepath.Dir(paths.AuditLog), 0700)                         //nolint:errcheck
+	os.WriteFile(paths.AuditLog, []byte("{\"hash\":\"existing\"}\n"), 0640) //nolint:errcheck
+
+	if err := Run(paths, NewFakeCommander(), Options{}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(paths.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0666 {
+		t.Errorf("audit log mode = %v, want 0666 so auth-keys and spawn can append", info.Mode().Perm())
+	}
+	if dir, _ := os.Stat(filepath.Dir(paths.AuditLog)); dir.Mode().Perm() != 0755 {
+		t.Errorf("audit log dir mode = %v, want 0755", dir.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(paths.AuditLog); !strings.Contains(string(data), "existing") {
+		t.Error("existing audit log content was overwritten")
+	}
 }
 
 func TestEmergencyKeysNotOverwritten(t *testing.T) {
@@ -0,0 +1,83 @@
+package spawn
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeatInterval is how often observed I/O is flushed to the state
+// store. Coarse on purpose: idle timeouts are measured in minutes, and
+// every flush is a SQLite write.
+var heartbeatInterval = 30 * time.Second
+
+// activityTracker records when bytes last flowed in either direction
+// between the SSH client and the container.
+type activityTracker struct {
+	last atomic.Int64 // unix nanoseconds, 0 = no activity yet
+}
+
+func (a *activityTracker) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+func (a *activityTracker) lastSeen() time.Time {
+	n := a.last.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+type activityReader struct {
+	r io.Reader
+	a *activityTracker
+}
+
+func (ar activityReader) Read(p []byte) (int, error) {
+	n, err := ar.r.Read(p)
+	if n > 0 {
+		ar.a.touch()
+	}
+	return n, err
+}
+
+type activityWriter struct {
+	w io.Writer
+	a *activityTracker
+}
+
+func (aw activityWriter) Write(p []byte) (int, error) {
+	n, err := aw.w.Write(p)
+	if n > 0 {
+		aw.a.touch()
+	}
+	return n, err
+}
+
+// heartbeat flushes tracked activity to last_activity until ctx is done.
+// Only writes when something new was observed since the last flush.
+func (s *Session) heartbeat(ctx context.Context, a *activityTracker) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	var flushed time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			seen := a.lastSeen()
+			if !seen.After(flushed) {
+				continue
+			}
+			if err := s.Store.TouchActivity(s.Username, s.ProjectName, seen); err != nil {
+				slog.Warn("heartbeat: failed to record activity", "user", s.Username, "error", err)
+				continue
+			}
+			flushed = seen
+		}
+	}
+}
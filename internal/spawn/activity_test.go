@@ -0,0 +1,83 @@
+package spawn
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func TestActivityWrappersRecordIO(t *testing.T) {
+	a := &activityTracker{}
+	if !a.lastSeen().IsZero() {
+		t.Fatal("new tracker should have no activity")
+	}
+
+	r := activityReader{strings.NewReader("ls\n"), a}
+	buf := make([]byte, 16)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	first := a.lastSeen()
+	if first.IsZero() {
+		t.Fatal("read should record activity")
+	}
+
+	time.Sleep(time.Millisecond)
+	w := activityWriter{&bytes.Buffer{}, a}
+	if _, err := w.Write([]byte("output")); err != nil {
+		t.Fatal(err)
+	}
+	if !a.lastSeen().After(first) {
+		t.Error("write should advance activity")
+	}
+
+	before := a.lastSeen()
+	if _, err := w.Write(nil); err != nil {
+		t.Fatal(err)
+	}
+	if a.lastSeen() != before {
+		t.Error("empty write should not count as activity")
+	}
+}
+
+func TestHeartbeatFlushesActivity(t *testing.T) {
+	orig := heartbeatInterval
+	heartbeatInterval = 5 * time.Millisecond
+	t.Cleanup(func() { heartbeatInterval = orig })
+
+	store := state.NewFakeStore()
+	old := time.Now().Add(-time.Hour).UTC()
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		ContainerName: "podspawn-deploy",
+		Status:        "running",
+		Connections:   1,
+		CreatedAt:     old,
+		LastActivity:  old,
+		MaxLifetime:   time.Now().Add(8 * time.Hour),
+	})
+
+	sess := &Session{Username: "deploy", Store: store}
+	a := &activityTracker{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sess.heartbeat(ctx, a)
+
+	a.touch()
+	deadline := time.After(2 * time.Second)
+	for {
+		got, _ := store.GetSession("deploy", "")
+		if got.LastActivity.After(old) {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("heartbeat did not update last_activity")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
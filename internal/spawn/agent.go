@@ -0,0 +1,248 @@
+package spawn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+// agentMountPath is where the session's agent directory is mounted in
+// the container. agentLink inside it always points at a live relay.
+const (
+	agentMountPath = "/run/podspawn/agent"
+	agentLink      = "agent.sock"
+)
+
+// sessionAgentDir is the host directory bind-mounted at agentMountPath.
+// It is keyed like the container so it stays stable across reconnects
+// while sshd's SSH_AUTH_SOCK changes on every connection.
+func (s *Session) sessionAgentDir() string {
+	return filepath.Join(s.AgentDir, s.containerName())
+}
+
+// agentMount prepares the session's agent directory and returns its
+// mount. The directory is mounted whether or not this connection has an
+// agent, since a later connection to the same container might.
+func (s *Session) agentMount() (*runtime.Mount, error) {
+	if s.AgentDir == "" {
+		return nil, nil
+	}
+	dir := s.sessionAgentDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating agent directory: %w", err)
+	}
+	return &runtime.Mount{Source: dir, Target: agentMountPath}, nil
+}
+
+// RemoveAgentDir deletes a session's agent directory once its container
+// is gone. A no-op when agentDir is empty.
+func RemoveAgentDir(agentDir, containerName string) {
+	if agentDir == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(agentDir, containerName)); err != nil {
+		slog.Warn("removing agent directory failed", "container", containerName, "error", err)
+	}
+}
+
+// startAgentForwarding starts a relay for this connection's agent and
+// points the session's agent link at it. Returns nil if forwarding is
+// off, the client didn't forward an agent, or the relay can't start.
+func (s *Session) startAgentForwarding(ctx context.Context, containerName string) *agentRelay {
+	upstream := os.Getenv("SSH_AUTH_SOCK")
+	if s.AgentDir == "" || upstream == "" {
+		return nil
+	}
+	relay, err := startAgentRelay(s.sessionAgentDir(), upstream)
+	if err != nil {
+		slog.Warn("agent forwarding unavailable", "user", s.Username, "error", err)
+		return nil
+	}
+	if err := s.shareAgentWithDevUser(ctx, containerName, relay); err != nil {
+		slog.Warn("agent forwarding unavailable", "user", s.Username, "error", err)
+		relay.Close()
+		return nil
+	}
+	s.execEnv = append(s.execEnv, "SSH_AUTH_SOCK="+agentMountPath+"/"+agentLink)
+	return relay
+}
+
+// shareAgentWithDevUser lets a dev user whose uid differs from the host
+// account's reach the relay. The socket is handed to the dev user by
+// root in the container (spawn can't chown on the host) and keeps the
+// host account's group, so other relays can still check it is live;
+// the directory becomes traversable but not listable.
+func (s *Session) shareAgentWithDevUser(ctx context.Context, containerName string, relay *agentRelay) error {
+	if !s.DevUser.Enabled {
+		return nil
+	}
+	uid, _ := s.devUserIDs()
+	if uid == os.Getuid() {
+		return nil
+	}
+	if err := os.Chmod(relay.dir, 0711); err != nil {
+		return fmt.Errorf("opening agent directory to the dev user: %w", err)
+	}
+	if err := os.Chmod(relay.sock, 0660); err != nil {
+		return fmt.Errorf("opening %s to the dev user: %w", relay.sock, err)
+	}
+	var out bytes.Buffer
+	target := agentMountPath + "/" + filepath.Base(relay.sock)
+	exitCode, err := s.Runtime.Exec(ctx, containerName, runtime.ExecOpts{
+		Cmd:    []string{"chown", fmt.Sprintf("%d:%d", uid, os.Getgid()), target},
+		User:   "root",
+		Stdout: &out,
+		Stderr: &out,
+	})
+	if err != nil {
+		return fmt.Errorf("handing agent socket to the dev user: %w", err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("handing agent socket to the dev user: chown exited %d: %s", exitCode, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// agentRelay listens on a socket in the session's agent directory and
+// proxies each connection to the sshd agent socket of one SSH
+// connection. Every connection to a session runs its own relay; the
+// agent link points at the newest one.
+type agentRelay struct {
+	dir      string
+	sock     string
+	upstream string
+	ln       net.Listener
+	wg       sync.WaitGroup
+}
+
+func startAgentRelay(dir, upstream string) (*agentRelay, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating agent directory: %w", err)
+	}
+	sock := filepath.Join(dir, fmt.Sprintf("agent-%d.sock", os.Getpid()))
+	_ = os.Remove(sock) // left over from a crashed process with our pid
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", sock, err)
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("securing %s: %w", sock, err)
+	}
+
+	r := &agentRelay{dir: dir, sock: sock, upstream: upstream, ln: ln}
+	if err := r.pointLinkAt(sock); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+func (r *agentRelay) serve() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		go r.proxy(conn)
+	}
+}
+
+func (r *agentRelay) proxy(conn net.Conn) {
+	defer conn.Close() //nolint:errcheck
+	upstream, err := net.Dial("unix", r.upstream)
+	if err != nil {
+		slog.Debug("agent relay: dialing upstream failed", "error", err)
+		return
+	}
+	defer upstream.Close() //nolint:errcheck
+
+	done := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(upstream, conn); done <- struct{}{} }()
+	go func() { _, _ = io.Copy(conn, upstream); done <- struct{}{} }()
+	<-done
+}
+
+// pointLinkAt atomically replaces the agent link. The target is
+// relative so the link resolves the same on the host and in the
+// container.
+func (r *agentRelay) pointLinkAt(sock string) error {
+	tmp := filepath.Join(r.dir, fmt.Sprintf(".%s.%d", agentLink, os.Getpid()))
+	_ = os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(sock), tmp); err != nil {
+		return fmt.Errorf("linking agent socket: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(r.dir, agentLink)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("linking agent socket: %w", err)
+	}
+	return nil
+}
+
+// Close stops the relay. If the agent link points at this relay it is
+// handed to another connection's relay that is still listening, or
+// removed when there is none.
+func (r *agentRelay) Close() {
+	_ = r.ln.Close()
+	r.wg.Wait()
+	_ = os.Remove(r.sock)
+
+	link := filepath.Join(r.dir, agentLink)
+	if target, err := os.Readlink(link); err != nil || target != filepath.Base(r.sock) {
+		return
+	}
+	if other := liveRelaySocket(r.dir); other != "" {
+		if err := r.pointLinkAt(other); err == nil {
+			return
+		}
+	}
+	_ = os.Remove(link)
+}
+
+// liveRelaySocket returns the most recently started relay socket in dir
+// that still accepts connections, or "" if there is none.
+func liveRelaySocket(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "agent-*.sock"))
+	type candidate struct {
+		path string
+		mod  int64
+	}
+	var live []candidate
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		conn, err := net.Dial("unix", m)
+		if err != nil {
+			// Dead socket from a crashed spawn; tidy it up
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				_ = os.Remove(m)
+			}
+			continue
+		}
+		_ = conn.Close()
+		live = append(live, candidate{m, info.ModTime().UnixNano()})
+	}
+	if len(live) == 0 {
+		return ""
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].mod > live[j].mod })
+	return live[0].path
+}
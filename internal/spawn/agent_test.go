@@ -0,0 +1,247 @@
+package spawn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+// fakeAgent listens on a unix socket and echoes whatever it reads.
+func fakeAgent(t *testing.T, path string) {
+	t.Helper()
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close() //nolint:errcheck
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+}
+
+func TestAgentRelayProxiesToUpstream(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+
+	dir := filepath.Join(base, "sess")
+	relay, err := startAgentRelay(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	target, err := os.Readlink(filepath.Join(dir, agentLink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.IsAbs(target) {
+		t.Errorf("link target %q should be relative", target)
+	}
+
+	conn, err := net.Dial("unix", filepath.Join(dir, agentLink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("got %q through relay, want ping", buf)
+	}
+}
+
+func TestAgentRelayCloseHandsLinkToLiveRelay(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+	dir := filepath.Join(base, "sess")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// Another connection's relay, still running
+	fakeAgent(t, filepath.Join(dir, "agent-1.sock"))
+
+	relay, err := startAgentRelay(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.Close()
+
+	target, err := os.Readlink(filepath.Join(dir, agentLink))
+	if err != nil {
+		t.Fatalf("link should survive: %v", err)
+	}
+	if target != "agent-1.sock" {
+		t.Errorf("link = %q, want agent-1.sock", target)
+	}
+}
+
+func TestAgentRelayCloseRemovesLinkWhenLast(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+	dir := filepath.Join(base, "sess")
+
+	relay, err := startAgentRelay(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.Close()
+
+	if _, err := os.Lstat(filepath.Join(dir, agentLink)); !os.IsNotExist(err) {
+		t.Errorf("link should be removed, lstat err = %v", err)
+	}
+}
+
+func TestRunForwardsAgent(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+	t.Setenv("SSH_AUTH_SOCK", upstream)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "git push")
+
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+		AgentDir: filepath.Join(base, "agent"),
+	}
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := fake.CreateCalls[0].Mounts
+	want := runtime.Mount{Source: filepath.Join(base, "agent", "podspawn-deploy"), Target: agentMountPath}
+	if !slices.Contains(mounts, want) {
+		t.Errorf("mounts = %+v, want %+v", mounts, want)
+	}
+	env := fake.ExecCalls[len(fake.ExecCalls)-1].Opts.Env
+	if !slices.Contains(env, "SSH_AUTH_SOCK=/run/podspawn/agent/agent.sock") {
+		t.Errorf("exec env = %v, want SSH_AUTH_SOCK", env)
+	}
+}
+
+func TestRunWithoutAgentSetsNoEnv(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+		AgentDir: t.TempDir(),
+	}
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.CreateCalls[0].Mounts) != 1 {
+		t.Errorf("agent dir should be mounted for later connections, got %+v", fake.CreateCalls[0].Mounts)
+	}
+	if env := fake.ExecCalls[0].Opts.Env; len(env) != 0 {
+		t.Errorf("exec env = %v, want none without an agent", env)
+	}
+}
+
+func TestAgentForwardingHandsSocketToDevUser(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+	t.Setenv("SSH_AUTH_SOCK", upstream)
+
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		AgentDir: filepath.Join(base, "agent"),
+		DevUser:  config.DevUserConfig{Enabled: true, UID: os.Getuid() + 1, GID: os.Getgid() + 1},
+	}
+	relay := sess.startAgentForwarding(context.Background(), "podspawn-deploy")
+	if relay == nil {
+		t.Fatal("forwarding should start")
+	}
+	defer relay.Close()
+
+	if len(fake.ExecCalls) != 1 {
+		t.Fatalf("expected one chown exec, got %+v", fake.ExecCalls)
+	}
+	chown := fake.ExecCalls[0].Opts
+	want := []string{"chown", fmt.Sprintf("%d:%d", os.Getuid()+1, os.Getgid()), agentMountPath + "/" + filepath.Base(relay.sock)}
+	if chown.User != "root" || !slices.Equal(chown.Cmd, want) {
+		t.Errorf("exec = %v as %q, want %v as root", chown.Cmd, chown.User, want)
+	}
+	if info, err := os.Stat(relay.dir); err != nil || info.Mode().Perm() != 0711 {
+		t.Errorf("agent dir stat = %v, %v; want mode 0711", info, err)
+	}
+	if info, err := os.Stat(relay.sock); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("agent socket stat = %v, %v; want mode 0660", info, err)
+	}
+}
+
+func TestAgentForwardingFailsClosedWhenChownFails(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.sock")
+	fakeAgent(t, upstream)
+	t.Setenv("SSH_AUTH_SOCK", upstream)
+
+	fake := runtime.NewFakeRuntime()
+	fake.ExitCode = 1
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		AgentDir: filepath.Join(base, "agent"),
+		DevUser:  config.DevUserConfig{Enabled: true, UID: os.Getuid() + 1},
+	}
+	if relay := sess.startAgentForwarding(context.Background(), "podspawn-deploy"); relay != nil {
+		relay.Close()
+		t.Fatal("forwarding should be off when the socket can't be handed over")
+	}
+	if len(sess.execEnv) != 0 {
+		t.Errorf("exec env = %v, want none", sess.execEnv)
+	}
+}
+
+func TestRemoveAgentDir(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "podspawn-deploy")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	RemoveAgentDir(base, "podspawn-deploy")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("agent dir should be removed, stat err = %v", err)
+	}
+	RemoveAgentDir("", "podspawn-deploy") // no agent dir configured: no-op
+}
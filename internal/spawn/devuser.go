@@ -0,0 +1,134 @@
+package spawn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/podfile"
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+// fallbackDevUID is used when DevUser leaves the uid to the host account
+// but the account can't be looked up (e.g. users only known to sshd).
+const fallbackDevUID = 1000
+
+// devUser is who the developer's shell, SFTP, hooks and clones run as:
+// a user named after the SSH user when DevUser is enabled, otherwise
+// the image's default user.
+func (s *Session) devUser() podfile.ExecUser {
+	if !s.DevUser.Enabled {
+		return podfile.ExecUser{}
+	}
+	return podfile.ExecUser{Name: s.Username, Home: "/home/" + s.Username}
+}
+
+// devUserIDs resolves the uid/gid for the dev user. Zero in the config
+// means "same as the host account", which keeps bind-mounted files (and
+// the agent relay directory) accessible on both sides.
+func (s *Session) devUserIDs() (int, int) {
+	uid, gid := s.DevUser.UID, s.DevUser.GID
+	if uid != 0 && gid != 0 {
+		return uid, gid
+	}
+	hostUID, hostGID := fallbackDevUID, fallbackDevUID
+	if u, err := user.Lookup(s.Username); err == nil {
+		hostUID, _ = strconv.Atoi(u.Uid)
+		hostGID, _ = strconv.Atoi(u.Gid)
+	}
+	if uid == 0 {
+		uid = hostUID
+	}
+	if gid == 0 {
+		gid = hostGID
+	}
+	return uid, gid
+}
+
+// checkDevUser refuses, before any container is created, hardening
+// from the project or user overrides that createDevUser can't work
+// under. Server defaults are already checked by config.Validate.
+func (s *Session) checkDevUser() error {
+	if err := s.securityConfig().CheckDevUser(s.DevUser); err != nil {
+		return fmt.Errorf("session security settings: %w", err)
+	}
+	return nil
+}
+
+// createDevUser adds the dev user to a freshly created container. Runs
+// as root and is idempotent, so a container restored from a snapshot
+// (where the user already exists) goes through it too.
+func (s *Session) createDevUser(ctx context.Context, containerName string) error {
+	if !s.DevUser.Enabled {
+		return nil
+	}
+	uid, gid := s.devUserIDs()
+	u := s.devUser()
+
+	// Directories the user must own: home (possibly a fresh root-owned
+	// volume), the persisted workspace, and the parents of repo checkouts.
+	owned := []string{u.Home}
+	if s.Persist.Workspace != "" {
+		owned = append(owned, s.Persist.Workspace)
+	}
+	if s.pf != nil {
+		for _, repo := range s.pf.Repos {
+			if parent := path.Dir(repo.Path); repo.Path != "" && parent != "/" {
+				owned = append(owned, parent)
+			}
+		}
+	}
+
+	script := devUserScript(u.Name, uid, gid, u.Home, s.Shell, s.DevUser.Sudo, owned)
+	var stderr bytes.Buffer
+	exitCode, err := s.Runtime.Exec(ctx, containerName, runtime.ExecOpts{
+		Cmd:    []string{"sh", "-c", script},
+		User:   "root",
+		Stdout: &stderr,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("creating user %s: %w", u.Name, err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("creating user %s: exited %d: %s", u.Name, exitCode, strings.TrimSpace(stderr.String()))
+	}
+	slog.Info("created dev user", "user", u.Name, "uid", uid, "gid", gid, "container", containerName)
+	return nil
+}
+
+// devUserScript creates the group and user if missing (shadow-utils
+// first, busybox as the fallback for Alpine) and hands the listed
+// directories to the user. useradd -o tolerates images that already
+// have another account on the uid, like ubuntu's "ubuntu" at 1000.
+// With sudo, an image without it fails up front instead of getting a
+// sudoers entry nothing reads.
+func devUserScript(name string, uid, gid int, home, shell string, sudo bool, owned []string) string {
+	q := podfile.ShellQuote
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	if sudo {
+		b.WriteString("command -v sudo >/dev/null 2>&1 || { echo 'sudo is not installed in the image; install it or set dev_user.sudo: false' >&2; exit 1; }\n")
+	}
+	fmt.Fprintf(&b, "if ! getent group %d >/dev/null 2>&1; then groupadd -g %d %s 2>/dev/null || addgroup -g %d %s; fi\n",
+		gid, gid, q(name), gid, q(name))
+	fmt.Fprintf(&b, "group=$(getent group %d | cut -d: -f1)\n", gid)
+	fmt.Fprintf(&b, "if ! id -u %s >/dev/null 2>&1; then useradd -o -M -d %s -u %d -g %d -s %s %s 2>/dev/null || adduser -D -H -h %s -u %d -G \"$group\" -s %s %s; fi\n",
+		q(name), q(home), uid, gid, q(shell), q(name), q(home), uid, q(shell), q(name))
+	for _, dir := range owned {
+		fmt.Fprintf(&b, "mkdir -p %s && chown %d:%d %s\n", q(dir), uid, gid, q(dir))
+	}
+	// Seed a new home from /etc/skel; an existing (persisted) one is left alone
+	fmt.Fprintf(&b, "if [ -d /etc/skel ] && [ -z \"$(ls -A %s)\" ]; then cp -a /etc/skel/. %s && chown -R %d:%d %s; fi\n",
+		q(home), q(home), uid, gid, q(home))
+	if sudo {
+		fmt.Fprintf(&b, "mkdir -p /etc/sudoers.d && echo %s > /etc/sudoers.d/podspawn && chmod 0440 /etc/sudoers.d/podspawn\n",
+			q(name+" ALL=(ALL) NOPASSWD:ALL"))
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package spawn
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func devUserSession(t *testing.T, fake *runtime.FakeRuntime) *Session {
+	t.Helper()
+	return &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+		DevUser:  config.DevUserConfig{Enabled: true, UID: 1500, GID: 1600, Sudo: true},
+	}
+}
+
+func TestDevUserCreatedAndUsedForCommands(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := devUserSession(t, fake)
+	sess.Persist = config.PersistConfig{Home: true}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "touch notes.txt")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.ExecCalls) != 2 {
+		t.Fatalf("expected user setup + command, got %d execs", len(fake.ExecCalls))
+	}
+	setup := fake.ExecCalls[0].Opts
+	script := setup.Cmd[2]
+	if setup.User != "root" {
+		t.Errorf("setup should run as root, got %q", setup.User)
+	}
+	for _, want := range []string{"useradd", "-u 1500", "-g 1600", "useradd -o -M -d /home/deploy", "chown 1500:1600 /home/deploy", "NOPASSWD", "command -v sudo"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("setup script missing %q:\n%s", want, script)
+		}
+	}
+
+	cmd := fake.ExecCalls[1].Opts
+	if cmd.User != "deploy" || cmd.WorkingDir != "/home/deploy" {
+		t.Errorf("command should run as deploy in its home, got user=%q dir=%q", cmd.User, cmd.WorkingDir)
+	}
+	if m := fake.CreateCalls[0].Mounts; len(m) != 1 || m[0].Target != "/home/deploy" {
+		t.Errorf("persisted home should follow the dev user, got %+v", m)
+	}
+}
+
+func TestDevUserWithoutSudo(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := devUserSession(t, fake)
+	sess.DevUser.Sudo = false
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if script := fake.ExecCalls[0].Opts.Cmd[2]; strings.Contains(script, "sudoers") || strings.Contains(script, "command -v sudo") {
+		t.Error("sudo should only be required and configured when it is enabled")
+	}
+}
+
+func TestDevUserSetupFailureRemovesContainer(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	fake.ExitCode = 1
+	sess := devUserSession(t, fake)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err == nil {
+		t.Fatal("expected error when user setup fails")
+	}
+	if _, ok := fake.Containers["podspawn-deploy"]; ok {
+		t.Error("container should be removed after failed user setup")
+	}
+}
+
+func TestDevUserRejectedUnderHardening(t *testing.T) {
+	on := true
+	fake := runtime.NewFakeRuntime()
+	sess := devUserSession(t, fake)
+	sess.Security = config.SecurityConfig{Capabilities: []string{"NET_BIND_SERVICE"}, NoNewPrivileges: &on, ReadOnlyRootfs: &on}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "dev_user") {
+		t.Fatalf("err = %v, want dev_user rejected", err)
+	}
+	if len(fake.CreateCalls) != 0 {
+		t.Error("no container should be created")
+	}
+}
+
+func TestRootByDefault(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := devUserSession(t, fake)
+	sess.DevUser = config.DevUserConfig{}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.ExecCalls) != 1 {
+		t.Fatalf("expected only the command exec, got %d", len(fake.ExecCalls))
+	}
+	if opts := fake.ExecCalls[0].Opts; opts.User != "" || opts.WorkingDir != "" {
+		t.Errorf("default mode should use the image's user and workdir, got user=%q dir=%q", opts.User, opts.WorkingDir)
+	}
+}
+
+func TestDevUserIDsFallBackWithoutHostAccount(t *testing.T) {
+	sess := &Session{Username: "no-such-user-podspawn", DevUser: config.DevUserConfig{Enabled: true}}
+	uid, gid := sess.devUserIDs()
+	if uid != fallbackDevUID || gid != fallbackDevUID {
+		t.Errorf("ids = %d:%d, want %d:%d", uid, gid, fallbackDevUID, fallbackDevUID)
+	}
+
+	sess.DevUser.GID = 42
+	if _, gid := sess.devUserIDs(); gid != 42 {
+		t.Errorf("configured gid should win, got %d", gid)
+	}
+}
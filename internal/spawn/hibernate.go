@@ -0,0 +1,142 @@
+package spawn
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/audit"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+// SnapshotRef returns the image ref a session hibernated at is
+// committed to: podspawn/snapshot:<user>[.<project>]-<time>. Each
+// snapshot gets its own tag so the one it replaces can be removed by
+// name once the container restored from it is gone.
+func SnapshotRef(user, project string, at time.Time) string {
+	return "podspawn/snapshot:" + sessionKey(user, project) + "-" + at.UTC().Format("20060102T150405.000000000")
+}
+
+// HibernateSession snapshots a session, tears down its resources and
+// then removes the snapshot the new one replaces; that image can't go
+// any earlier while the container restored from it exists. The caller
+// deletes the session record. On error nothing has been torn down.
+func HibernateSession(ctx context.Context, rt runtime.Runtime, store state.SessionStore, sess *state.Session, ttl time.Duration) error {
+	prev, err := store.GetSnapshot(sess.User, sess.Project)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	if err := SnapshotSession(ctx, rt, store, sess, ttl); err != nil {
+		return err
+	}
+	CleanupSessionResources(ctx, rt, sess)
+	if prev != nil {
+		if err := rt.RemoveImage(ctx, prev.Image); err != nil {
+			slog.Warn("removing replaced snapshot failed", "image", prev.Image, "error", err)
+		}
+	}
+	return nil
+}
+
+// SnapshotSession commits a session's dev container to a new snapshot
+// image and records it with the given retention, replacing any earlier
+// record. Companion services are not snapshotted; they start fresh on
+// restore. Persistent volumes are not part of the commit either, they
+// are mounted again as usual.
+//
+// On error nothing has been recorded and the container is left as it was.
+func SnapshotSession(ctx context.Context, rt runtime.Runtime, store state.SessionStore, sess *state.Session, ttl time.Duration) error {
+	now := time.Now().UTC()
+	ref := SnapshotRef(sess.User, sess.Project, now)
+	labels := map[string]string{
+		"managed-by":        "podspawn",
+		"podspawn-user":     sess.User,
+		"podspawn-project":  sess.Project,
+		"podspawn-snapshot": "true",
+	}
+	if err := rt.CommitContainer(ctx, sess.ContainerName, ref, labels); err != nil {
+		return fmt.Errorf("snapshotting %s: %w", sess.ContainerName, err)
+	}
+
+	if err := store.SaveSnapshot(&state.Snapshot{
+		User:      sess.User,
+		Project:   sess.Project,
+		Image:     ref,
+		BaseImage: sess.Image,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		_ = rt.RemoveImage(ctx, ref)
+		return fmt.Errorf("recording snapshot: %w", err)
+	}
+	return nil
+}
+
+// DiscardSnapshot removes a snapshot image and its record. The image
+// removal is best-effort; a tag that's already gone is not an error.
+func DiscardSnapshot(ctx context.Context, rt runtime.Runtime, store state.SessionStore, snap *state.Snapshot) error {
+	if err := rt.RemoveImage(ctx, snap.Image); err != nil {
+		slog.Warn("removing snapshot image failed", "image", snap.Image, "error", err)
+	}
+	return store.DeleteSnapshot(snap.User, snap.Project)
+}
+
+// retire tears down a session whose grace period is over. In hibernate
+// mode the container is snapshotted first; if that fails the session is
+// kept so the user can still reattach, and the next expiry check retries.
+// reason is recorded in the audit log.
+func (s *Session) retire(ctx context.Context, sess *state.Session, reason string) {
+	if s.Mode == "hibernate" {
+		if err := HibernateSession(ctx, s.Runtime, s.Store, sess, s.SnapshotTTL); err != nil {
+			slog.Error("hibernate failed, keeping container", "user", sess.User, "container", sess.ContainerName, "error", err)
+			return
+		}
+		slog.Info("hibernated session", "user", sess.User, "project", sess.Project)
+		reason = "hibernated"
+	} else {
+		CleanupSessionResources(ctx, s.Runtime, sess)
+	}
+	RemoveAgentDir(s.AgentDir, sess.ContainerName)
+	_ = s.Store.DeleteSession(sess.User, sess.Project)
+	s.audit(audit.TypeSessionDestroy, reason)
+}
+
+// snapshotImage picks the image to create the container from: the
+// session's snapshot if one exists and was taken from the same base
+// image, otherwise image itself. A snapshot of an older base (the
+// Podfile or default image changed since) is discarded rather than
+// restored over the new environment.
+func (s *Session) snapshotImage(ctx context.Context, image string) (string, bool) {
+	if s.Mode != "hibernate" {
+		return image, false
+	}
+	snap, err := s.Store.GetSnapshot(s.Username, s.ProjectName)
+	if err != nil {
+		slog.Warn("reading snapshot failed", "user", s.Username, "error", err)
+		return image, false
+	}
+	if snap == nil {
+		return image, false
+	}
+	if snap.BaseImage != image {
+		slog.Info("discarding stale snapshot", "user", s.Username, "snapshot_base", snap.BaseImage, "image", image)
+		_ = DiscardSnapshot(ctx, s.Runtime, s.Store, snap)
+		return image, false
+	}
+	labels, err := s.Runtime.ImageLabels(ctx, snap.Image)
+	if err != nil {
+		slog.Warn("snapshot image missing", "user", s.Username, "image", snap.Image, "error", err)
+		_ = s.Store.DeleteSnapshot(s.Username, s.ProjectName)
+		return image, false
+	}
+	if labels["podspawn-user"] != s.Username || labels["podspawn-project"] != s.ProjectName {
+		// Not ours, so leave the image alone and just forget the record.
+		slog.Warn("snapshot image belongs to another session", "user", s.Username, "image", snap.Image)
+		_ = s.Store.DeleteSnapshot(s.Username, s.ProjectName)
+		return image, false
+	}
+	slog.Info("restoring from snapshot", "user", s.Username, "image", snap.Image)
+	return snap.Image, true
+}
@@ -0,0 +1,201 @@
+package spawn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func expiredGraceSession(t *testing.T, fake *runtime.FakeRuntime, store *state.FakeStore) {
+	t.Helper()
+	fake.Containers["podspawn-deploy"] = true
+	past := time.Now().Add(-10 * time.Second)
+	_ = store.CreateSession(&state.Session{
+		User:          "deploy",
+		ContainerID:   "old-id",
+		ContainerName: "podspawn-deploy",
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		CreatedAt:     past,
+		LastActivity:  past,
+		MaxLifetime:   time.Now().Add(8 * time.Hour),
+	})
+	_ = store.SetGracePeriod("deploy", "", past)
+}
+
+func hibernateSession(t *testing.T, fake *runtime.FakeRuntime, store *state.FakeStore) *Session {
+	t.Helper()
+	return &Session{
+		Username:    "deploy",
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       store,
+		LockDir:     t.TempDir(),
+		GracePeriod: 60 * time.Second,
+		MaxLifetime: 8 * time.Hour,
+		Mode:        "hibernate",
+		SnapshotTTL: 24 * time.Hour,
+	}
+}
+
+func TestHibernateOnExpiryAndRestore(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	expiredGraceSession(t, fake, store)
+	sess := hibernateSession(t, fake, store)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.CommitCalls) != 1 {
+		t.Fatalf("expected one commit, got %v", fake.CommitCalls)
+	}
+	ref := fake.CommitCalls[0]
+	if fake.ImageLabelSets[ref]["podspawn-user"] != "deploy" {
+		t.Errorf("snapshot labels = %v", fake.ImageLabelSets[ref])
+	}
+	snap, _ := store.GetSnapshot("deploy", "")
+	if snap == nil || snap.Image != ref || snap.BaseImage != "ubuntu:24.04" {
+		t.Fatalf("snapshot record = %+v", snap)
+	}
+	if time.Until(snap.ExpiresAt) < 23*time.Hour {
+		t.Errorf("snapshot should expire after the TTL, got %v", snap.ExpiresAt)
+	}
+
+	if len(fake.CreateCalls) != 1 || fake.CreateCalls[0].Image != ref {
+		t.Fatalf("new container should start from the snapshot, got %+v", fake.CreateCalls)
+	}
+	got, _ := store.GetSession("deploy", "")
+	if got == nil || got.Image != "ubuntu:24.04" {
+		t.Errorf("session should record the base image, got %+v", got)
+	}
+}
+
+func TestSnapshotRefsDoNotCollide(t *testing.T) {
+	now := time.Now()
+	if SnapshotRef("alice", "web", now) == SnapshotRef("alice-web", "", now) {
+		t.Error("alice/web and alice-web share a snapshot ref")
+	}
+}
+
+func TestHibernateRemovesReplacedSnapshot(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	expiredGraceSession(t, fake, store)
+	old := SnapshotRef("deploy", "", time.Now().Add(-time.Hour))
+	fake.Images[old] = true
+	_ = store.SaveSnapshot(&state.Snapshot{User: "deploy", Image: old, BaseImage: "ubuntu:24.04", ExpiresAt: time.Now().Add(time.Hour)})
+
+	sess, _ := store.GetSession("deploy", "")
+	if err := HibernateSession(context.Background(), fake, store, sess, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.Images[old] {
+		t.Error("replaced snapshot image should be removed")
+	}
+	snap, _ := store.GetSnapshot("deploy", "")
+	if snap == nil || snap.Image == old || !fake.Images[snap.Image] {
+		t.Errorf("snapshot record = %+v, want the new image", snap)
+	}
+	if fake.Containers["podspawn-deploy"] {
+		t.Error("container should be torn down")
+	}
+}
+
+func TestSnapshotOfAnotherSessionNotRestored(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	ref := SnapshotRef("deploy", "", time.Now())
+	fake.Images[ref] = true
+	fake.ImageLabelSets[ref] = map[string]string{"podspawn-user": "deploy", "podspawn-project": "other"}
+	_ = store.SaveSnapshot(&state.Snapshot{User: "deploy", Image: ref, BaseImage: "ubuntu:24.04", ExpiresAt: time.Now().Add(time.Hour)})
+	sess := hibernateSession(t, fake, store)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.CreateCalls[0].Image != "ubuntu:24.04" {
+		t.Errorf("image = %q, a snapshot labelled for another session must not be restored", fake.CreateCalls[0].Image)
+	}
+	if !fake.Images[ref] {
+		t.Error("another session's image should be left alone")
+	}
+}
+
+func TestHibernateFailureKeepsSession(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	fake.CommitErr = errors.New("disk full")
+	store := state.NewFakeStore()
+	expiredGraceSession(t, fake, store)
+	sess := hibernateSession(t, fake, store)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.CreateCalls) != 0 {
+		t.Error("should reattach to the old container instead of creating one")
+	}
+	if len(fake.ExecCalls) == 0 || fake.ExecCalls[0].ContainerID != "podspawn-deploy" {
+		t.Errorf("exec should target the kept container, got %+v", fake.ExecCalls)
+	}
+}
+
+func TestStaleSnapshotDiscarded(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	now := time.Now()
+	ref := SnapshotRef("deploy", "", now)
+	fake.Images[ref] = true
+	_ = store.SaveSnapshot(&state.Snapshot{
+		User:      "deploy",
+		Image:     ref,
+		BaseImage: "ubuntu:22.04",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	sess := hibernateSession(t, fake, store)
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.CreateCalls[0].Image != "ubuntu:24.04" {
+		t.Errorf("image = %q, want the current base image", fake.CreateCalls[0].Image)
+	}
+	if fake.Images[ref] {
+		t.Error("stale snapshot image should be removed")
+	}
+	if snap, _ := store.GetSnapshot("deploy", ""); snap != nil {
+		t.Error("stale snapshot record should be deleted")
+	}
+}
+
+func TestSnapshotIgnoredOutsideHibernateMode(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	ref := SnapshotRef("deploy", "", time.Now())
+	fake.Images[ref] = true
+	_ = store.SaveSnapshot(&state.Snapshot{User: "deploy", Image: ref, BaseImage: "ubuntu:24.04", ExpiresAt: time.Now().Add(time.Hour)})
+	sess := hibernateSession(t, fake, store)
+	sess.Mode = "grace-period"
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.CreateCalls[0].Image != "ubuntu:24.04" {
+		t.Errorf("image = %q, snapshot should only be used in hibernate mode", fake.CreateCalls[0].Image)
+	}
+}
@@ -0,0 +1,136 @@
+package spawn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/podspawn/podspawn/internal/config"
+)
+
+// QuotaError means opening the session would take the user over their
+// quota. The message is written for the SSH client.
+type QuotaError struct {
+	Reason string
+}
+
+func (e *QuotaError) Error() string {
+	return "quota exceeded: " + e.Reason
+}
+
+// quotaConfig resolves the session's quota: server defaults, then the
+// user's overrides.
+func (s *Session) quotaConfig() config.QuotaConfig {
+	q := s.Quota
+	if s.UserOverrides != nil {
+		q = q.Merge(s.UserOverrides.Quota)
+	}
+	return q
+}
+
+// checkQuota refuses a new session that, together with the user's
+// existing ones, would go over the quota. Sessions in their grace
+// period still hold a container and count. A session without a CPU or
+// memory limit can use the whole host, so it never fits under a cap on
+// that resource. Callers hold the user lock, so concurrent connections
+// can't both slip under the limit.
+func (s *Session) checkQuota() error {
+	q := s.quotaConfig()
+	if q == (config.QuotaConfig{}) {
+		return nil
+	}
+	sessions, err := s.Store.UserSessions(s.Username)
+	if err != nil {
+		return fmt.Errorf("checking quota: %w", err)
+	}
+
+	var names, noCPULimit, noMemoryLimit []string
+	var usedCPUs float64
+	var usedMemory int64
+	for _, sess := range sessions {
+		label := projectLabel(sess.Project)
+		names = append(names, label)
+		if sess.CPUs == 0 {
+			noCPULimit = append(noCPULimit, label)
+		}
+		if sess.Memory == 0 {
+			noMemoryLimit = append(noMemoryLimit, label)
+		}
+		usedCPUs += sess.CPUs
+		usedMemory += sess.Memory
+	}
+	open := strings.Join(names, ", ")
+	cpus, memory := s.resources()
+
+	if q.MaxSessions > 0 && len(sessions) >= q.MaxSessions {
+		return &QuotaError{fmt.Sprintf("%s already has %d of %d sessions open (%s); disconnect from one and let it expire first",
+			s.Username, len(sessions), q.MaxSessions, open)}
+	}
+	if q.MaxCPUs > 0 {
+		switch {
+		case cpus == 0:
+			return &QuotaError{fmt.Sprintf("this session has no CPU limit, so it can't fit in %s's %g CPUs; set cpus for the session and each of its services",
+				s.Username, q.MaxCPUs)}
+		case len(noCPULimit) > 0:
+			return &QuotaError{fmt.Sprintf("%s has sessions without a CPU limit open (%s); disconnect from them and let them expire first",
+				s.Username, strings.Join(noCPULimit, ", "))}
+		case usedCPUs+cpus > q.MaxCPUs:
+			return &QuotaError{fmt.Sprintf("this session needs %g CPUs, %s has %g of %g in use (%s)",
+				cpus, s.Username, usedCPUs, q.MaxCPUs, open)}
+		}
+	}
+	if q.MaxMemory != "" {
+		limit, err := config.ParseMemory(q.MaxMemory)
+		if err != nil {
+			return fmt.Errorf("checking quota: invalid max_memory %q: %w", q.MaxMemory, err)
+		}
+		switch {
+		case memory == 0:
+			return &QuotaError{fmt.Sprintf("this session has no memory limit, so it can't fit in %s's %s; set memory for the session and each of its services",
+				s.Username, gib(limit))}
+		case len(noMemoryLimit) > 0:
+			return &QuotaError{fmt.Sprintf("%s has sessions without a memory limit open (%s); disconnect from them and let them expire first",
+				s.Username, strings.Join(noMemoryLimit, ", "))}
+		case usedMemory+memory > limit:
+			return &QuotaError{fmt.Sprintf("this session needs %s of memory, %s has %s of %s in use (%s)",
+				gib(memory), s.Username, gib(usedMemory), gib(limit), open)}
+		}
+	}
+	return nil
+}
+
+// resources sums the limits of the session's dev container and its
+// companion services. Either total is 0 (unlimited) as soon as one of
+// the containers has no limit on it.
+func (s *Session) resources() (float64, int64) {
+	cpus, memory := s.CPUs, s.Memory
+	if s.Project == nil || s.pf == nil {
+		return cpus, memory
+	}
+	for _, svc := range s.pf.Services {
+		svcMemory, _ := config.ParseMemory(svc.Memory) // validated by the parser
+		if svc.CPUs == 0 {
+			cpus = 0
+		}
+		if svcMemory == 0 {
+			memory = 0
+		}
+		if cpus > 0 {
+			cpus += svc.CPUs
+		}
+		if memory > 0 {
+			memory += svcMemory
+		}
+	}
+	return cpus, memory
+}
+
+func projectLabel(project string) string {
+	if project == "" {
+		return "default"
+	}
+	return project
+}
+
+func gib(n int64) string {
+	return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+}
@@ -0,0 +1,250 @@
+package spawn
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/podfile"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+// openSession records a live session for deploy in project.
+func openSession(t *testing.T, fake *runtime.FakeRuntime, store *state.FakeStore, project string, cpus float64, memory int64) {
+	t.Helper()
+	name := "podspawn-deploy"
+	if project != "" {
+		name += "-" + project
+	}
+	fake.Containers[name] = true
+	now := time.Now()
+	if err := store.CreateSession(&state.Session{
+		User:          "deploy",
+		Project:       project,
+		ContainerID:   name + "-id",
+		ContainerName: name,
+		Image:         "ubuntu:24.04",
+		Status:        "running",
+		Connections:   1,
+		CreatedAt:     now,
+		LastActivity:  now,
+		MaxLifetime:   now.Add(8 * time.Hour),
+		CPUs:          cpus,
+		Memory:        memory,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func quotaSession(t *testing.T, fake *runtime.FakeRuntime, store *state.FakeStore, quota config.QuotaConfig) *Session {
+	t.Helper()
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+	return &Session{
+		Username:    "deploy",
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		CPUs:        2,
+		Memory:      2 << 30,
+		Store:       store,
+		LockDir:     t.TempDir(),
+		GracePeriod: 60 * time.Second,
+		MaxLifetime: 8 * time.Hour,
+		Mode:        "grace-period",
+		Quota:       quota,
+	}
+}
+
+func TestQuotaMaxSessions(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	openSession(t, fake, store, "backend", 2, 2<<30)
+	sess := quotaSession(t, fake, store, config.QuotaConfig{MaxSessions: 1})
+
+	_, err := sess.Run(context.Background())
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("err = %v, want QuotaError", err)
+	}
+	if !strings.Contains(err.Error(), "1 of 1") || !strings.Contains(err.Error(), "backend") {
+		t.Errorf("message should name the limit and open sessions, got %q", err)
+	}
+	if len(fake.CreateCalls) != 0 {
+		t.Error("no container should be created over quota")
+	}
+}
+
+func TestQuotaAllowsReattach(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	openSession(t, fake, store, "", 2, 2<<30)
+	sess := quotaSession(t, fake, store, config.QuotaConfig{MaxSessions: 1, MaxCPUs: 2})
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatalf("reattaching to an existing session should not hit the quota: %v", err)
+	}
+}
+
+func TestQuotaResources(t *testing.T) {
+	cases := []struct {
+		name    string
+		quota   config.QuotaConfig
+		wantErr string
+	}{
+		{"cpus", config.QuotaConfig{MaxCPUs: 3}, "CPUs"},
+		{"memory", config.QuotaConfig{MaxMemory: "3g"}, "3.0 GiB"},
+		{"fits", config.QuotaConfig{MaxSessions: 2, MaxCPUs: 4, MaxMemory: "4g"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := runtime.NewFakeRuntime()
+			store := state.NewFakeStore()
+			openSession(t, fake, store, "backend", 2, 2<<30)
+			sess := quotaSession(t, fake, store, tc.quota)
+
+			_, err := sess.Run(context.Background())
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := store.GetSession("deploy", "")
+				if got.CPUs != 2 || got.Memory != 2<<30 {
+					t.Errorf("session should record its resources, got cpus=%g memory=%d", got.CPUs, got.Memory)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want mention of %s", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuotaUnlimitedSessions(t *testing.T) {
+	cases := []struct {
+		name       string
+		cpus       float64
+		memory     int64
+		openCPUs   float64
+		openMemory int64
+		quota      config.QuotaConfig
+		wantErr    string
+	}{
+		{"new without cpu limit", 0, 2 << 30, 1, 1 << 30, config.QuotaConfig{MaxCPUs: 8}, "no CPU limit"},
+		{"new without memory limit", 2, 0, 1, 1 << 30, config.QuotaConfig{MaxMemory: "8g"}, "no memory limit"},
+		{"open without cpu limit", 2, 2 << 30, 0, 1 << 30, config.QuotaConfig{MaxCPUs: 8}, "without a CPU limit open (backend)"},
+		{"open without memory limit", 2, 2 << 30, 1, 0, config.QuotaConfig{MaxMemory: "8g"}, "without a memory limit open (backend)"},
+		{"uncapped resource", 0, 0, 0, 0, config.QuotaConfig{MaxSessions: 2}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := runtime.NewFakeRuntime()
+			store := state.NewFakeStore()
+			openSession(t, fake, store, "backend", tc.openCPUs, tc.openMemory)
+			sess := quotaSession(t, fake, store, tc.quota)
+			sess.CPUs, sess.Memory = tc.cpus, tc.memory
+
+			_, err := sess.Run(context.Background())
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var quotaErr *QuotaError
+			if !errors.As(err, &quotaErr) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want QuotaError mentioning %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuotaCountsServices(t *testing.T) {
+	projectDir := t.TempDir()
+	raw := []byte("base: ubuntu:24.04\nservices:\n  - name: postgres\n    image: postgres:16\n    cpus: 1\n    memory: 1g\n")
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tag := podfile.ComputeTag("backend", raw)
+
+	cases := []struct {
+		name    string
+		quota   config.QuotaConfig
+		wantErr string
+	}{
+		{"cpus", config.QuotaConfig{MaxCPUs: 5}, "needs 3 CPUs"},
+		{"memory", config.QuotaConfig{MaxMemory: "4g"}, "needs 3.0 GiB"},
+		{"fits", config.QuotaConfig{MaxCPUs: 6, MaxMemory: "6g"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := runtime.NewFakeRuntime()
+			fake.Images[tag] = true
+			store := state.NewFakeStore()
+			openSession(t, fake, store, "", 3, 3<<30)
+			sess := quotaSession(t, fake, store, tc.quota)
+			sess.ProjectName = "backend"
+			sess.Project = &config.ProjectConfig{LocalPath: projectDir, ImageTag: tag}
+
+			_, err := sess.Run(context.Background())
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := store.GetSession("deploy", "backend")
+				if got.CPUs != 3 || got.Memory != 3<<30 {
+					t.Errorf("session should record the services' resources too, got cpus=%g memory=%d", got.CPUs, got.Memory)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuotaUserOverride(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	openSession(t, fake, store, "backend", 2, 2<<30)
+	sess := quotaSession(t, fake, store, config.QuotaConfig{MaxSessions: 1})
+	sess.UserOverrides = &config.UserOverrides{Quota: &config.QuotaConfig{MaxSessions: 3}}
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatalf("user override should raise the limit: %v", err)
+	}
+}
+
+func TestQuotaCheckedBeforeServicesStart(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	openSession(t, fake, store, "", 2, 2<<30)
+
+	projectDir := t.TempDir()
+	raw := []byte("base: ubuntu:24.04\nservices:\n  - name: postgres\n    image: postgres:16\n")
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tag := podfile.ComputeTag("backend", raw)
+	fake.Images[tag] = true
+
+	sess := quotaSession(t, fake, store, config.QuotaConfig{MaxSessions: 1})
+	sess.ProjectName = "backend"
+	sess.Project = &config.ProjectConfig{LocalPath: projectDir, ImageTag: tag}
+
+	_, err := sess.Run(context.Background())
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("err = %v, want QuotaError", err)
+	}
+	if len(fake.CreateNetworkCalls) != 0 {
+		t.Errorf("services should not start for a refused session, networks = %v", fake.CreateNetworkCalls)
+	}
+}
@@ -0,0 +1,37 @@
+package spawn
+
+import (
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+// securityConfig resolves the hardening settings for this session:
+// server defaults, then the project's, then the user's.
+func (s *Session) securityConfig() config.SecurityConfig {
+	sec := s.Security
+	if s.Project != nil {
+		sec = sec.Merge(s.Project.Security)
+	}
+	if s.UserOverrides != nil {
+		sec = sec.Merge(s.UserOverrides.Security)
+	}
+	return sec
+}
+
+// applySecurity flattens the session's hardening settings into opts.
+func (s *Session) applySecurity(opts *runtime.ContainerOpts) {
+	sec := s.securityConfig()
+	if sec.Capabilities != nil {
+		opts.CapDrop = []string{"ALL"}
+		opts.CapAdd = sec.Capabilities
+	}
+	opts.NoNewPrivileges = sec.NoNewPrivileges != nil && *sec.NoNewPrivileges
+	opts.PidsLimit = sec.PidsLimit
+	if sec.ReadOnlyRootfs != nil && *sec.ReadOnlyRootfs {
+		opts.ReadOnlyRootfs = true
+		opts.Tmpfs = map[string]string{"/tmp": "rw,nosuid,nodev"}
+	}
+	opts.SeccompProfile = sec.SeccompProfile
+	opts.AppArmorProfile = sec.AppArmorProfile
+	opts.UsernsMode = sec.UsernsMode
+}
@@ -0,0 +1,97 @@
+package spawn
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func TestSecurityProfilePlumbedToContainer(t *testing.T) {
+	yes := true
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+		Security: config.SecurityConfig{
+			Capabilities:    []string{"CHOWN", "SETUID"},
+			NoNewPrivileges: &yes,
+			PidsLimit:       256,
+			ReadOnlyRootfs:  &yes,
+			SeccompProfile:  "/etc/podspawn/seccomp.json",
+			AppArmorProfile: "podspawn",
+			UsernsMode:      "host",
+		},
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := fake.CreateCalls[0]
+	if !slices.Equal(opts.CapDrop, []string{"ALL"}) || !slices.Equal(opts.CapAdd, []string{"CHOWN", "SETUID"}) {
+		t.Errorf("caps drop=%v add=%v", opts.CapDrop, opts.CapAdd)
+	}
+	if !opts.NoNewPrivileges || opts.PidsLimit != 256 {
+		t.Errorf("no-new-privileges=%v pids=%d", opts.NoNewPrivileges, opts.PidsLimit)
+	}
+	if !opts.ReadOnlyRootfs || opts.Tmpfs["/tmp"] == "" {
+		t.Errorf("read-only rootfs should come with a /tmp tmpfs, got ro=%v tmpfs=%v", opts.ReadOnlyRootfs, opts.Tmpfs)
+	}
+	if opts.SeccompProfile != "/etc/podspawn/seccomp.json" || opts.AppArmorProfile != "podspawn" || opts.UsernsMode != "host" {
+		t.Errorf("profiles not plumbed: %+v", opts)
+	}
+}
+
+func TestSecurityDefaultsLeaveDockerDefaults(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	opts := fake.CreateCalls[0]
+	if opts.CapDrop != nil || opts.NoNewPrivileges || opts.ReadOnlyRootfs || opts.PidsLimit != 0 {
+		t.Errorf("zero config should not harden anything, got %+v", opts)
+	}
+}
+
+func TestSecurityOverridePrecedence(t *testing.T) {
+	yes, no := true, false
+	sess := &Session{
+		Security: config.SecurityConfig{NoNewPrivileges: &yes, PidsLimit: 100},
+		Project: &config.ProjectConfig{
+			Security: &config.SecurityConfig{PidsLimit: 200, Capabilities: []string{"CHOWN"}},
+		},
+		UserOverrides: &config.UserOverrides{
+			Security: &config.SecurityConfig{NoNewPrivileges: &no, Capabilities: []string{}},
+		},
+	}
+
+	sec := sess.securityConfig()
+	if sec.PidsLimit != 200 {
+		t.Errorf("pids_limit = %d, want project's 200", sec.PidsLimit)
+	}
+	if *sec.NoNewPrivileges {
+		t.Error("user override should win for no_new_privileges")
+	}
+	if sec.Capabilities == nil || len(sec.Capabilities) != 0 {
+		t.Errorf("user's empty allowlist should win, got %v", sec.Capabilities)
+	}
+}
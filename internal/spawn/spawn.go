@@ -1,9 +1,10 @@
 package spawn
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -13,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/podspawn/podspawn/internal/audit"
 	"github.com/podspawn/podspawn/internal/config"
 	"github.com/podspawn/podspawn/internal/lock"
 	"github.com/podspawn/podspawn/internal/podfile"
@@ -21,25 +23,34 @@ import (
 	"golang.org/x/term"
 )
 
-const sftpServerPath = "/usr/lib/openssh/sftp-server"
-
 type Session struct {
 	Username      string
 	ProjectName   string                // empty = default image session
 	Project       *config.ProjectConfig // nil = use default image
 	UserOverrides *config.UserOverrides // nil = no per-user overrides
+	Podfiles      *podfile.Resolver     // parents for extends; nil = paths in the repo only
 	Runtime       runtime.Runtime
 	Image         string
 	Shell         string
 	CPUs          float64
 	Memory        int64
-	Store         state.SessionStore // nil = Phase 0 mode (destroy on exit)
+	OCIRuntime    string                // Docker runtime (runsc, kata-runtime); empty = daemon default
+	Security      config.SecurityConfig // server-wide hardening; project and user settings are merged over it
+	DevUser       config.DevUserConfig  // run as a non-root user named after Username
+	Store         state.SessionStore    // nil = Phase 0 mode (destroy on exit)
 	LockDir       string
 	GracePeriod   time.Duration
 	MaxLifetime   time.Duration
-	Mode          string // "grace-period" | "destroy-on-disconnect"
-
-	pf *podfile.Podfile // cached after first parse
+	Mode          string               // "grace-period" | "destroy-on-disconnect" | "hibernate"
+	SnapshotTTL   time.Duration        // hibernate mode: how long a snapshot is kept
+	Persist       config.PersistConfig // named volumes for home/workspace; Podfile persist: replaces it
+	AgentDir      string               // host dir for agent relay sockets; empty = no agent forwarding
+	Quota         config.QuotaConfig   // server-wide per-user limits; user overrides are merged over it
+	Audit         *audit.Log           // nil = no audit trail
+
+	pf       *podfile.Podfile // cached after first parse
+	activity *activityTracker // nil = no heartbeat (Phase 0 mode)
+	execEnv  []string         // extra env for the user's shell/command exec
 }
 
 func (s *Session) containerName() string {
@@ -57,6 +68,16 @@ func (s *Session) Run(ctx context.Context) (int, error) {
 		}
 		s.ensurePodfileParsed()
 		s.runHooks(ctx, containerName, isNew)
+
+		s.activity = &activityTracker{}
+		hbCtx, stopHeartbeat := context.WithCancel(ctx)
+		defer stopHeartbeat()
+		go s.heartbeat(hbCtx, s.activity)
+
+		if relay := s.startAgentForwarding(ctx, containerName); relay != nil {
+			defer relay.Close()
+		}
+
 		return s.routeSession(ctx, containerName)
 	}
 
@@ -103,29 +124,62 @@ func (s *Session) ensureContainerWithState(ctx context.Context) (string, bool, e
 			return "", false, err
 		}
 		slog.Info("reattaching to container", "name", sess.ContainerName, "connections", sess.Connections+1)
+		s.audit(audit.TypeSessionReattach, "")
 		return sess.ContainerName, false, nil
 	}
 
-	image, env, networkID, serviceIDs, err := s.resolveProject(ctx)
+	image, env, err := s.resolveProject(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if err := s.checkQuota(); err != nil {
+		return "", false, err
+	}
+	if err := s.checkDevUser(); err != nil {
+		return "", false, err
+	}
+	cpus, memory := s.resources()
+	networkID, serviceIDs, err := s.startServices(ctx)
 	if err != nil {
 		return "", false, err
 	}
 
-	slog.Info("creating container", "name", containerName, "image", image)
-	id, err := s.Runtime.CreateContainer(ctx, runtime.ContainerOpts{
+	mounts, err := s.persistentMounts(ctx)
+	if err != nil {
+		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
+		return "", false, err
+	}
+	agent, err := s.agentMount()
+	if err != nil {
+		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
+		return "", false, err
+	}
+	if agent != nil {
+		mounts = append(mounts, *agent)
+	}
+
+	runImage, restored := s.snapshotImage(ctx, image)
+
+	opts := runtime.ContainerOpts{
 		Name:        containerName,
-		Image:       image,
+		Image:       runImage,
 		Cmd:         []string{"sleep", "infinity"},
 		Env:         env,
+		Mounts:      mounts,
 		CPUs:        s.CPUs,
 		Memory:      s.Memory,
+		Runtime:     s.OCIRuntime,
 		NetworkID:   networkID,
 		NetworkName: containerName,
 		Labels: map[string]string{
 			"managed-by":    "podspawn",
 			"podspawn-user": s.Username,
 		},
-	})
+	}
+	s.applySecurity(&opts)
+
+	slog.Info("creating container", "name", containerName, "image", runImage)
+	id, err := s.Runtime.CreateContainer(ctx, opts)
 	if err != nil {
 		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
 		return "", false, err
@@ -135,6 +189,11 @@ func (s *Session) ensureContainerWithState(ctx context.Context) (string, bool, e
 		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
 		return "", false, err
 	}
+	if err := s.createDevUser(ctx, containerName); err != nil {
+		_ = s.Runtime.RemoveContainer(ctx, containerName)
+		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
+		return "", false, err
+	}
 
 	now := time.Now().UTC()
 	if err := s.Store.CreateSession(&state.Session{
@@ -150,13 +209,17 @@ func (s *Session) ensureContainerWithState(ctx context.Context) (string, bool, e
 		MaxLifetime:   now.Add(s.MaxLifetime),
 		NetworkID:     networkID,
 		ServiceIDs:    strings.Join(serviceIDs, ","),
+		CPUs:          cpus,
+		Memory:        memory,
 	}); err != nil {
 		_ = s.Runtime.RemoveContainer(ctx, containerName)
 		s.cleanupServicesAndNetwork(ctx, serviceIDs, networkID)
 		return "", false, fmt.Errorf("recording session: %w", err)
 	}
+	s.audit(audit.TypeSessionCreate, "")
 
-	return containerName, true, nil
+	// A restored container already ran its on_create setup before hibernating
+	return containerName, !restored, nil
 }
 
 // ensureContainerLegacy is the Phase 0 path: no state, no locking.
@@ -167,24 +230,33 @@ func (s *Session) ensureContainerLegacy(ctx context.Context, containerName strin
 	}
 
 	if !exists {
-		slog.Info("creating container", "name", containerName, "image", s.Image)
-		_, err := s.Runtime.CreateContainer(ctx, runtime.ContainerOpts{
-			Name:   containerName,
-			Image:  s.Image,
-			Cmd:    []string{"sleep", "infinity"},
-			CPUs:   s.CPUs,
-			Memory: s.Memory,
+		if err := s.checkDevUser(); err != nil {
+			return 1, err
+		}
+		opts := runtime.ContainerOpts{
+			Name:    containerName,
+			Image:   s.Image,
+			Cmd:     []string{"sleep", "infinity"},
+			CPUs:    s.CPUs,
+			Memory:  s.Memory,
+			Runtime: s.OCIRuntime,
 			Labels: map[string]string{
 				"managed-by":    "podspawn",
 				"podspawn-user": s.Username,
 			},
-		})
-		if err != nil {
+		}
+		s.applySecurity(&opts)
+
+		slog.Info("creating container", "name", containerName, "image", s.Image)
+		if _, err := s.Runtime.CreateContainer(ctx, opts); err != nil {
 			return 1, err
 		}
 		if err := s.Runtime.StartContainer(ctx, containerName); err != nil {
 			return 1, err
 		}
+		if err := s.createDevUser(ctx, containerName); err != nil {
+			return 1, err
+		}
 	} else {
 		slog.Info("reattaching to container", "name", containerName)
 	}
@@ -198,7 +270,7 @@ func (s *Session) routeSession(ctx context.Context, containerName string) (int,
 	case origCmd == "":
 		return s.interactiveShell(ctx, containerName)
 	case isSFTP(origCmd):
-		return s.execCommand(ctx, containerName, sftpServerPath)
+		return s.execCommand(ctx, containerName, podfile.SFTPServerPath)
 	default:
 		return s.execCommand(ctx, containerName, origCmd)
 	}
@@ -225,16 +297,15 @@ func (s *Session) interactiveShell(ctx context.Context, containerName string) (i
 		defer term.Restore(stdinFd, oldState) //nolint:errcheck // best-effort restore
 	}
 
-	exitCode, err := s.Runtime.Exec(ctx, containerName, runtime.ExecOpts{
-		Cmd:    []string{s.Shell},
-		TTY:    true,
-		Stdin:  os.Stdin,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-		ExecIDCallback: func(execID string) {
-			go handleResize(ctx, s.Runtime, execID)
-		},
-	})
+	stdin, stdout, stderr := s.stdio()
+	opts := s.devUser().ExecOpts("", s.Shell)
+	opts.Env = s.execEnv
+	opts.TTY = true
+	opts.Stdin, opts.Stdout, opts.Stderr = stdin, stdout, stderr
+	opts.ExecIDCallback = func(execID string) {
+		go handleResize(ctx, s.Runtime, execID)
+	}
+	exitCode, err := s.Runtime.Exec(ctx, containerName, opts)
 	if err != nil {
 		return 1, err
 	}
@@ -243,19 +314,28 @@ func (s *Session) interactiveShell(ctx context.Context, containerName string) (i
 }
 
 func (s *Session) execCommand(ctx context.Context, containerName, origCmd string) (int, error) {
-	exitCode, err := s.Runtime.Exec(ctx, containerName, runtime.ExecOpts{
-		Cmd:    []string{"sh", "-c", origCmd},
-		TTY:    false,
-		Stdin:  os.Stdin,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-	})
+	stdin, stdout, stderr := s.stdio()
+	opts := s.devUser().ExecOpts("", "sh", "-c", origCmd)
+	opts.Env = s.execEnv
+	opts.Stdin, opts.Stdout, opts.Stderr = stdin, stdout, stderr
+	exitCode, err := s.Runtime.Exec(ctx, containerName, opts)
 	if err != nil {
 		return 1, err
 	}
 	return exitCode, nil
 }
 
+// stdio returns the process's standard streams, wrapped to record
+// activity when a heartbeat is running.
+func (s *Session) stdio() (io.Reader, io.Writer, io.Writer) {
+	if s.activity == nil {
+		return os.Stdin, os.Stdout, os.Stderr
+	}
+	return activityReader{os.Stdin, s.activity},
+		activityWriter{os.Stdout, s.activity},
+		activityWriter{os.Stderr, s.activity}
+}
+
 func handleResize(ctx context.Context, rt runtime.Runtime, execID string) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGWINCH)
@@ -289,8 +369,10 @@ func (s *Session) reconcileUser(ctx context.Context) {
 	}
 	if stale != nil {
 		slog.Info("reconcile: cleaning up stale session", "user", stale.User, "container", stale.ContainerName)
-		cleanupSessionResources(ctx, s.Runtime, stale)
+		CleanupSessionResources(ctx, s.Runtime, stale)
+		RemoveAgentDir(s.AgentDir, stale.ContainerName)
 		_ = s.Store.DeleteSession(stale.User, stale.Project)
+		s.audit(audit.TypeSessionDestroy, "stale session")
 	}
 
 	// Expired grace period for this user/project
@@ -300,8 +382,7 @@ func (s *Session) reconcileUser(ctx context.Context) {
 	}
 	if sess.Status == "grace_period" && sess.GraceExpiry.Valid && sess.GraceExpiry.Time.Before(time.Now()) {
 		slog.Info("reconcile: grace period expired", "user", sess.User, "container", sess.ContainerName)
-		cleanupSessionResources(ctx, s.Runtime, sess)
-		_ = s.Store.DeleteSession(sess.User, sess.Project)
+		s.retire(ctx, sess, "grace period expired")
 	}
 }
 
@@ -311,17 +392,12 @@ func (s *Session) ensurePodfileParsed() {
 	if s.pf != nil || s.Project == nil {
 		return
 	}
-	raw, err := podfile.FindAndRead(s.Project.LocalPath)
+	src, err := podfile.Load(s.Project.LocalPath, s.Podfiles)
 	if err != nil {
 		slog.Warn("could not load podfile for hooks", "error", err)
 		return
 	}
-	pf, err := podfile.Parse(bytes.NewReader(raw))
-	if err != nil {
-		slog.Warn("could not parse podfile for hooks", "error", err)
-		return
-	}
-	s.pf = pf
+	s.pf = src.Podfile
 }
 
 func (s *Session) runHooks(ctx context.Context, containerName string, isNew bool) {
@@ -331,19 +407,19 @@ func (s *Session) runHooks(ctx context.Context, containerName string, isNew bool
 
 	if isNew {
 		if s.pf.Dotfiles != nil {
-			if err := podfile.CloneDotfiles(ctx, s.Runtime, containerName, s.pf.Dotfiles); err != nil {
+			if err := podfile.CloneDotfiles(ctx, s.Runtime, containerName, s.devUser(), s.pf.Dotfiles); err != nil {
 				slog.Warn("dotfiles setup failed", "error", err)
 			}
 		}
 		for _, repo := range s.pf.Repos {
-			if err := podfile.CloneRepoInContainer(ctx, s.Runtime, containerName, repo); err != nil {
+			if err := podfile.CloneRepoInContainer(ctx, s.Runtime, containerName, s.devUser(), repo); err != nil {
 				slog.Warn("repo clone failed", "url", repo.URL, "error", err)
 			}
 		}
-		podfile.RunHook(ctx, s.Runtime, containerName, "on_create", s.pf.OnCreate)
+		podfile.RunHook(ctx, s.Runtime, containerName, s.devUser(), "on_create", s.pf.OnCreate)
 	}
 
-	podfile.RunHook(ctx, s.Runtime, containerName, "on_start", s.pf.OnStart)
+	podfile.RunHook(ctx, s.Runtime, containerName, s.devUser(), "on_start", s.pf.OnStart)
 }
 
 func (s *Session) applyUserOverrides() {
@@ -365,6 +441,9 @@ func (s *Session) applyUserOverrides() {
 	if uo.Shell != "" {
 		s.Shell = uo.Shell
 	}
+	if uo.Runtime != "" {
+		s.OCIRuntime = uo.Runtime
+	}
 	if uo.Dotfiles != nil && s.pf != nil {
 		s.pf.Dotfiles = &podfile.DotfilesConfig{
 			Repo:    uo.Dotfiles.Repo,
@@ -381,32 +460,38 @@ func (s *Session) applyUserOverrides() {
 	}
 }
 
-// resolveProject loads the Podfile (if a project is configured), resolves the
-// cached image, and creates companion services. Returns the image to use,
-// env vars, network ID, and service container IDs.
-func (s *Session) resolveProject(ctx context.Context) (image string, env []string, networkID string, serviceIDs []string, err error) {
+// resolveProject loads the Podfile (if a project is configured), checks
+// the project's recorded image exists and resolves the session's resources. Returns the image to use and
+// env vars.
+func (s *Session) resolveProject(ctx context.Context) (image string, env []string, err error) {
 	if s.Project == nil {
 		s.applyUserOverrides()
-		return s.Image, nil, "", nil, nil
+		return s.Image, nil, nil
 	}
 
-	raw, err := podfile.FindAndRead(s.Project.LocalPath)
+	src, err := podfile.Load(s.Project.LocalPath, s.Podfiles)
 	if err != nil {
-		return "", nil, "", nil, fmt.Errorf("loading podfile for %s: %w", s.ProjectName, err)
+		return "", nil, fmt.Errorf("loading podfile for %s: %w", s.ProjectName, err)
 	}
-	pf, err := podfile.Parse(bytes.NewReader(raw))
-	if err != nil {
-		return "", nil, "", nil, fmt.Errorf("parsing podfile for %s: %w", s.ProjectName, err)
+	for _, w := range src.Warnings {
+		slog.Warn("podfile translation", "project", s.ProjectName, "warning", w)
 	}
+	pf := src.Podfile
 	s.pf = pf
 
-	tag := podfile.ComputeTag(s.ProjectName, raw)
+	// The image add-project or update-project built and recorded; its
+	// tag isn't recomputed here, which would hash the build context on
+	// every connection.
+	tag := s.Project.ImageTag
+	if tag == "" {
+		return "", nil, fmt.Errorf("no image built for project %s; run: podspawn update-project %s", s.ProjectName, s.ProjectName)
+	}
 	exists, err := s.Runtime.ImageExists(ctx, tag)
 	if err != nil {
-		return "", nil, "", nil, fmt.Errorf("checking image %s: %w", tag, err)
+		return "", nil, fmt.Errorf("checking image %s: %w", tag, err)
 	}
 	if !exists {
-		return "", nil, "", nil, fmt.Errorf("image %s not built; run: podspawn update-project %s", tag, s.ProjectName)
+		return "", nil, fmt.Errorf("image %s not built; run: podspawn update-project %s", tag, s.ProjectName)
 	}
 	image = tag
 
@@ -417,9 +502,15 @@ func (s *Session) resolveProject(ctx context.Context) (image string, env []strin
 		mem, _ := config.ParseMemory(pf.Resources.Memory)
 		s.Memory = mem
 	}
+	if pf.Resources.Runtime != "" {
+		s.OCIRuntime = pf.Resources.Runtime
+	}
 	if pf.Shell != "" {
 		s.Shell = pf.Shell
 	}
+	if pf.Persist != nil {
+		s.Persist = config.PersistConfig{Home: pf.Persist.Home, Workspace: pf.Persist.Workspace}
+	}
 
 	// User overrides take priority over Podfile values
 	s.applyUserOverrides()
@@ -431,20 +522,26 @@ func (s *Session) resolveProject(ctx context.Context) (image string, env []strin
 	}
 	sort.Strings(env)
 
-	if len(pf.Services) > 0 {
-		netName := fmt.Sprintf("podspawn-%s-%s-net", s.Username, s.ProjectName)
-		networkID, err = s.Runtime.CreateNetwork(ctx, netName)
-		if err != nil {
-			return "", nil, "", nil, fmt.Errorf("creating network: %w", err)
-		}
-		serviceIDs, err = podfile.StartServices(ctx, s.Runtime, pf.Services, networkID, s.containerName())
-		if err != nil {
-			_ = s.Runtime.RemoveNetwork(ctx, networkID)
-			return "", nil, "", nil, fmt.Errorf("starting services: %w", err)
-		}
-	}
+	return image, env, nil
+}
 
-	return image, env, networkID, serviceIDs, nil
+// startServices creates the project's companion services on their own
+// network. Returns the network ID and service container IDs.
+func (s *Session) startServices(ctx context.Context) (networkID string, serviceIDs []string, err error) {
+	if s.Project == nil || s.pf == nil || len(s.pf.Services) == 0 {
+		return "", nil, nil
+	}
+	netName := fmt.Sprintf("podspawn-%s-%s-net", s.Username, s.ProjectName)
+	networkID, err = s.Runtime.CreateNetwork(ctx, netName)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating network: %w", err)
+	}
+	serviceIDs, err = podfile.StartServices(ctx, s.Runtime, s.pf.Services, networkID, s.containerName(), s.Username)
+	if err != nil {
+		_ = s.Runtime.RemoveNetwork(ctx, networkID)
+		return "", nil, fmt.Errorf("starting services: %w", err)
+	}
+	return networkID, serviceIDs, nil
 }
 
 func (s *Session) cleanupServicesAndNetwork(ctx context.Context, serviceIDs []string, networkID string) {
@@ -456,7 +553,10 @@ func (s *Session) cleanupServicesAndNetwork(ctx context.Context, serviceIDs []st
 	}
 }
 
-func cleanupSessionResources(ctx context.Context, rt runtime.Runtime, sess *state.Session) {
+// CleanupSessionResources removes a session's dev container, companion
+// services, and network. Best-effort: removal errors are ignored so a
+// half-torn-down session can still be cleaned up.
+func CleanupSessionResources(ctx context.Context, rt runtime.Runtime, sess *state.Session) {
 	_ = rt.RemoveContainer(ctx, sess.ContainerName)
 	if sess.ServiceIDs != "" {
 		podfile.StopServices(ctx, rt, strings.Split(sess.ServiceIDs, ","))
@@ -492,6 +592,7 @@ func (s *Session) Disconnect(ctx context.Context) {
 		return
 	}
 
+	s.audit(audit.TypeSessionDisconnect, fmt.Sprintf("%d connections left", count))
 	if count > 0 {
 		slog.Info("session still active", "user", s.Username, "connections", count)
 		return
@@ -504,8 +605,7 @@ func (s *Session) Disconnect(ctx context.Context) {
 			return
 		}
 		slog.Info("destroying container", "user", s.Username, "container", sess.ContainerName)
-		cleanupSessionResources(ctx, s.Runtime, sess)
-		_ = s.Store.DeleteSession(s.Username, s.ProjectName)
+		s.retire(ctx, sess, "disconnected")
 		return
 	}
 
@@ -513,7 +613,20 @@ func (s *Session) Disconnect(ctx context.Context) {
 	slog.Info("starting grace period", "user", s.Username, "expires", expiry)
 	if err := s.Store.SetGracePeriod(s.Username, s.ProjectName, expiry); err != nil {
 		slog.Error("failed to set grace period", "user", s.Username, "error", err)
+		return
 	}
+	s.audit(audit.TypeSessionGrace, "expires "+expiry.UTC().Format(time.RFC3339))
+}
+
+// audit records a session event. Connection events carry the forced
+// command so the trail shows what was run, not just that someone
+// connected.
+func (s *Session) audit(typ, detail string) {
+	e := audit.Event{Type: typ, User: s.Username, Project: s.ProjectName, Detail: detail}
+	if typ == audit.TypeSessionCreate || typ == audit.TypeSessionReattach {
+		e.Command = os.Getenv("SSH_ORIGINAL_COMMAND")
+	}
+	s.Audit.Record(e)
 }
 
 // RunAndCleanup runs the session and handles disconnect after.
@@ -522,6 +635,10 @@ func (s *Session) RunAndCleanup(ctx context.Context) int {
 	exitCode, err := s.Run(ctx)
 	if err != nil {
 		slog.Error("session failed", "user", s.Username, "error", err)
+		var quotaErr *QuotaError
+		if errors.As(err, &quotaErr) {
+			fmt.Fprintf(os.Stderr, "podspawn: %v\n", err)
+		}
 	}
 
 	cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
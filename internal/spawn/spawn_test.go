@@ -5,10 +5,12 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/podspawn/podspawn/internal/audit"
 	"github.com/podspawn/podspawn/internal/config"
 	"github.com/podspawn/podspawn/internal/podfile"
 	"github.com/podspawn/podspawn/internal/runtime"
@@ -597,6 +599,7 @@ func TestRunWithProjectFailsWithoutPrebuiltImage(t *testing.T) {
 		ProjectName: "backend",
 		Project: &config.ProjectConfig{
 			LocalPath: projectDir,
+			ImageTag:  podfile.ComputeTag("backend", []byte("base: ubuntu:24.04\n")),
 		},
 		Runtime:     fake,
 		Image:       "ubuntu:24.04",
@@ -620,6 +623,72 @@ func TestRunWithProjectFailsWithoutPrebuiltImage(t *testing.T) {
 	}
 }
 
+func TestRunWithProjectUsesRecordedImage(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	projectDir := t.TempDir()
+	// Changed since the image was built: spawn must not rehash it
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), []byte("base: ubuntu:24.04\npackages: [jq]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.Images["podspawn/backend:recorded"] = true
+
+	sess := &Session{
+		Username:    "deploy",
+		ProjectName: "backend",
+		Project:     &config.ProjectConfig{LocalPath: projectDir, ImageTag: "podspawn/backend:recorded"},
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.CreateCalls[0].Image; got != "podspawn/backend:recorded" {
+		t.Errorf("image = %q, want the tag recorded by update-project", got)
+	}
+
+	sess.Project.ImageTag = ""
+	sess.Store = state.NewFakeStore()
+	if _, err := sess.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "update-project") {
+		t.Errorf("a project without a recorded image should point at update-project, got %v", err)
+	}
+}
+
+func TestRunWithBuildContextDoesNotHashIt(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	projectDir := t.TempDir()
+	// The context is hashed by add-project/update-project; a missing
+	// one must not break connecting to the recorded image
+	podfileYAML := "build:\n  dockerfile: Dockerfile\n  context: missing\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), []byte(podfileYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.Images["podspawn/backend:recorded"] = true
+
+	sess := &Session{
+		Username:    "deploy",
+		ProjectName: "backend",
+		Project:     &config.ProjectConfig{LocalPath: projectDir, ImageTag: "podspawn/backend:recorded"},
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.CreateCalls[0].Image; got != "podspawn/backend:recorded" {
+		t.Errorf("image = %q, want the recorded tag", got)
+	}
+}
+
 func TestOnStartRunsOnReattach(t *testing.T) {
 	fake := runtime.NewFakeRuntime()
 	fake.Containers["podspawn-deploy-backend"] = true
@@ -798,6 +867,48 @@ func TestStartContainerFailureCleansUpContainer(t *testing.T) {
 	}
 }
 
+func TestProjectFromDevcontainer(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+
+	projectDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectDir, ".devcontainer.json"),
+		[]byte(`{"image": "node:22", "containerEnv": {"NODE_ENV": "development"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := podfile.Load(projectDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := podfile.ComputeTag("frontend", src.Raw)
+	fake.Images[tag] = true
+
+	sess := &Session{
+		Username:    "deploy",
+		ProjectName: "frontend",
+		Project:     &config.ProjectConfig{LocalPath: projectDir, ImageTag: tag},
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+		GracePeriod: 60 * time.Second,
+		MaxLifetime: 8 * time.Hour,
+		Mode:        "grace-period",
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	opts := fake.CreateCalls[0]
+	if opts.Image != tag {
+		t.Errorf("image = %q, want %q", opts.Image, tag)
+	}
+	if !slices.Contains(opts.Env, "NODE_ENV=development") {
+		t.Errorf("env = %v, want NODE_ENV from containerEnv", opts.Env)
+	}
+}
+
 func TestApplyUserOverridesEnvMerge(t *testing.T) {
 	fake := runtime.NewFakeRuntime()
 	store := state.NewFakeStore()
@@ -807,13 +918,15 @@ func TestApplyUserOverridesEnvMerge(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), podfileContent, 0644); err != nil {
 		t.Fatal(err)
 	}
-	fake.Images[podfile.ComputeTag("backend", podfileContent)] = true
+	tag := podfile.ComputeTag("backend", podfileContent)
+	fake.Images[tag] = true
 
 	sess := &Session{
 		Username:    "deploy",
 		ProjectName: "backend",
 		Project: &config.ProjectConfig{
 			LocalPath: projectDir,
+			ImageTag:  tag,
 		},
 		UserOverrides: &config.UserOverrides{
 			Env: map[string]string{
@@ -868,13 +981,15 @@ func TestEnvExpansionIncludesProject(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), podfileContent, 0644); err != nil {
 		t.Fatal(err)
 	}
-	fake.Images[podfile.ComputeTag("backend", podfileContent)] = true
+	tag := podfile.ComputeTag("backend", podfileContent)
+	fake.Images[tag] = true
 
 	sess := &Session{
 		Username:    "deploy",
 		ProjectName: "backend",
 		Project: &config.ProjectConfig{
 			LocalPath: projectDir,
+			ImageTag:  tag,
 		},
 		Runtime:     fake,
 		Image:       "ubuntu:24.04",
@@ -935,3 +1050,108 @@ func TestApplyUserOverridesWithoutPodfile(t *testing.T) {
 		t.Errorf("Memory = %d, want 8GiB", sess.Memory)
 	}
 }
+
+func TestOCIRuntimePrecedence(t *testing.T) {
+	projectDir := t.TempDir()
+	podfileContent := []byte("base: ubuntu:24.04\nresources:\n  runtime: kata-runtime\n")
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), podfileContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		project   bool
+		overrides *config.UserOverrides
+		want      string
+	}{
+		{"server default", false, nil, "runc"},
+		{"podfile beats default", true, nil, "kata-runtime"},
+		{"user override beats podfile", true, &config.UserOverrides{Runtime: "runsc"}, "runsc"},
+		{"user override without project", false, &config.UserOverrides{Runtime: "runsc"}, "runsc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runtime.NewFakeRuntime()
+			tag := podfile.ComputeTag("backend", podfileContent)
+			fake.Images[tag] = true
+			sess := &Session{
+				Username:      "contractor",
+				UserOverrides: tt.overrides,
+				Runtime:       fake,
+				Image:         "ubuntu:24.04",
+				Shell:         "/bin/bash",
+				OCIRuntime:    "runc",
+				Store:         state.NewFakeStore(),
+				LockDir:       t.TempDir(),
+			}
+			if tt.project {
+				sess.ProjectName = "backend"
+				sess.Project = &config.ProjectConfig{LocalPath: projectDir, ImageTag: tag}
+			}
+			t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+			if _, err := sess.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.CreateCalls[0].Runtime; got != tt.want {
+				t.Errorf("runtime = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionAuditTrail(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	store := state.NewFakeStore()
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	newSession := func() *Session {
+		return &Session{
+			Username:    "deploy",
+			Runtime:     fake,
+			Image:       "ubuntu:24.04",
+			Shell:       "/bin/bash",
+			Store:       store,
+			LockDir:     t.TempDir(),
+			GracePeriod: 60 * time.Second,
+			MaxLifetime: 8 * time.Hour,
+			Mode:        "grace-period",
+			Audit:       &audit.Log{Path: logPath},
+		}
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "make test")
+
+	first := newSession()
+	if _, err := first.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second := newSession()
+	if _, err := second.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second.Disconnect(context.Background())
+	first.Disconnect(context.Background())
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	events, err := audit.Read(f, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{
+		audit.TypeSessionCreate, audit.TypeSessionReattach,
+		audit.TypeSessionDisconnect, audit.TypeSessionDisconnect, audit.TypeSessionGrace,
+	}
+	if strings.Join(types, " ") != strings.Join(want, " ") {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	if events[0].Command != "make test" || events[1].Command != "make test" {
+		t.Errorf("connection events should carry the forced command, got %+v", events[:2])
+	}
+}
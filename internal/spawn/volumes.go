@@ -0,0 +1,100 @@
+package spawn
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/podspawn/podspawn/internal/runtime"
+)
+
+// Volume kinds, stored in the podspawn-volume label.
+const (
+	VolumeHome      = "home"
+	VolumeWorkspace = "workspace"
+)
+
+// VolumeName returns the Docker volume that backs kind for a user's
+// session: podspawn-<user>[.<project>]-<kind>.
+func VolumeName(user, project, kind string) string {
+	return "podspawn-" + sessionKey(user, project) + "-" + kind
+}
+
+// sessionKey joins user and project for names of per-session resources.
+// Usernames can't contain ".", so alice's project web (alice.web) can't
+// collide with user alice-web's project-less session.
+func sessionKey(user, project string) string {
+	if project != "" {
+		return user + "." + project
+	}
+	return user
+}
+
+// VolumeLabels returns the labels podspawn puts on a persistent volume.
+func VolumeLabels(user, project, kind string) map[string]string {
+	return map[string]string{
+		"managed-by":       "podspawn",
+		"podspawn-user":    user,
+		"podspawn-project": project,
+		"podspawn-volume":  kind,
+	}
+}
+
+// homeDir is where the session's home directory lives in the container.
+func (s *Session) homeDir() string {
+	return s.devUser().HomeDir()
+}
+
+// persistentMounts creates (if needed) the named volumes selected by
+// s.Persist and returns mounts for them. Docker copies the image's
+// content at the target into a fresh volume on first mount, so a new
+// home volume starts with the image's skeleton dotfiles.
+//
+// Volumes are found by their labels, not their name, so a volume that
+// happens to have the expected name but belongs to another session is
+// never mounted.
+func (s *Session) persistentMounts(ctx context.Context) ([]runtime.Mount, error) {
+	type volumeSpec struct{ kind, target string }
+	var wanted []volumeSpec
+	if s.Persist.Home {
+		wanted = append(wanted, volumeSpec{VolumeHome, s.homeDir()})
+	}
+	if s.Persist.Workspace != "" {
+		wanted = append(wanted, volumeSpec{VolumeWorkspace, s.Persist.Workspace})
+	}
+
+	var mounts []runtime.Mount
+	for _, w := range wanted {
+		name, err := s.sessionVolume(ctx, w.kind)
+		if err != nil {
+			return nil, fmt.Errorf("preparing %s volume: %w", w.kind, err)
+		}
+		mounts = append(mounts, runtime.Mount{Type: "volume", Source: name, Target: w.target})
+	}
+	return mounts, nil
+}
+
+// sessionVolume returns the session's volume of kind, creating it if
+// the session has none yet.
+func (s *Session) sessionVolume(ctx context.Context, kind string) (string, error) {
+	name := VolumeName(s.Username, s.ProjectName, kind)
+	if err := s.Runtime.CreateVolume(ctx, name, VolumeLabels(s.Username, s.ProjectName, kind)); err != nil {
+		return "", err
+	}
+	// CreateVolume is a no-op for an existing name, so check we got ours.
+	// podspawn-project is compared here rather than filtered on: an
+	// empty label value doesn't filter reliably.
+	vols, err := s.Runtime.ListVolumes(ctx, map[string]string{
+		"managed-by":      "podspawn",
+		"podspawn-user":   s.Username,
+		"podspawn-volume": kind,
+	}, false)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range vols {
+		if v.Name == name && v.Labels["podspawn-project"] == s.ProjectName {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("volume %s exists but is not labelled for this session", name)
+}
@@ -0,0 +1,135 @@
+package spawn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/podspawn/podspawn/internal/config"
+	"github.com/podspawn/podspawn/internal/podfile"
+	"github.com/podspawn/podspawn/internal/runtime"
+	"github.com/podspawn/podspawn/internal/state"
+)
+
+func TestVolumeName(t *testing.T) {
+	if got := VolumeName("deploy", "", VolumeHome); got != "podspawn-deploy-home" {
+		t.Errorf("got %q, want podspawn-deploy-home", got)
+	}
+	if got := VolumeName("deploy", "backend", VolumeWorkspace); got != "podspawn-deploy.backend-workspace" {
+		t.Errorf("got %q, want podspawn-deploy.backend-workspace", got)
+	}
+}
+
+func TestVolumeNamesDoNotCollide(t *testing.T) {
+	if VolumeName("alice", "web", VolumeHome) == VolumeName("alice-web", "", VolumeHome) {
+		t.Error("alice/web and alice-web share a volume name")
+	}
+}
+
+func volumeSession(fake *runtime.FakeRuntime, t *testing.T) *Session {
+	return &Session{
+		Username:    "alice",
+		ProjectName: "web",
+		Runtime:     fake,
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+		Persist:     config.PersistConfig{Home: true},
+	}
+}
+
+func TestVolumeOfAnotherSessionNotMounted(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	fake.Volumes[VolumeName("alice", "web", VolumeHome)] = VolumeLabels("alice-web", "", VolumeHome)
+
+	if _, err := volumeSession(fake, t).persistentMounts(context.Background()); err == nil {
+		t.Fatal("expected error for a volume labelled for another session")
+	}
+}
+
+func TestPersistentVolumesMounted(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username:    "deploy",
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+		MaxLifetime: 8 * time.Hour,
+		Persist:     config.PersistConfig{Home: true, Workspace: "/workspace"},
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := fake.CreateCalls[0].Mounts
+	if len(mounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %+v", mounts)
+	}
+	want := map[string]string{"/root": "podspawn-deploy-home", "/workspace": "podspawn-deploy-workspace"}
+	for _, m := range mounts {
+		if m.Type != "volume" || want[m.Target] != m.Source {
+			t.Errorf("unexpected mount %+v", m)
+		}
+	}
+	labels := fake.Volumes["podspawn-deploy-home"]
+	if labels["podspawn-user"] != "deploy" || labels["podspawn-volume"] != VolumeHome {
+		t.Errorf("home volume labels = %v", labels)
+	}
+}
+
+func TestNoPersistenceByDefault(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	sess := &Session{
+		Username: "deploy",
+		Runtime:  fake,
+		Image:    "ubuntu:24.04",
+		Shell:    "/bin/bash",
+		Store:    state.NewFakeStore(),
+		LockDir:  t.TempDir(),
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.CreateCalls[0].Mounts) != 0 || len(fake.Volumes) != 0 {
+		t.Errorf("no volumes expected, got mounts %+v", fake.CreateCalls[0].Mounts)
+	}
+}
+
+func TestPodfilePersistOverridesDefault(t *testing.T) {
+	fake := runtime.NewFakeRuntime()
+	projectDir := t.TempDir()
+	content := []byte("base: ubuntu:24.04\npersist:\n  workspace: /src\n")
+	if err := os.WriteFile(filepath.Join(projectDir, "podfile.yaml"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tag := podfile.ComputeTag("backend", content)
+	fake.Images[tag] = true
+
+	sess := &Session{
+		Username:    "deploy",
+		ProjectName: "backend",
+		Project:     &config.ProjectConfig{LocalPath: projectDir, ImageTag: tag},
+		Runtime:     fake,
+		Image:       "ubuntu:24.04",
+		Shell:       "/bin/bash",
+		Store:       state.NewFakeStore(),
+		LockDir:     t.TempDir(),
+		Persist:     config.PersistConfig{Home: true},
+	}
+	t.Setenv("SSH_ORIGINAL_COMMAND", "id")
+
+	if _, err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mounts := fake.CreateCalls[0].Mounts
+	if len(mounts) != 1 || mounts[0].Target != "/src" || mounts[0].Source != "podspawn-deploy.backend-workspace" {
+		t.Errorf("podfile persist should replace defaults, got %+v", mounts)
+	}
+}
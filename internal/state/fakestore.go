@@ -3,19 +3,24 @@ package state
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
 type FakeStore struct {
-	mu       sync.Mutex
-	Sessions map[string]*Session // keyed by "user|project"
+	mu        sync.Mutex
+	Sessions  map[string]*Session  // keyed by "user|project"
+	Snapshots map[string]*Snapshot // keyed by "user|project"
 }
 
 var _ SessionStore = (*FakeStore)(nil)
 
 func NewFakeStore() *FakeStore {
-	return &FakeStore{Sessions: make(map[string]*Session)}
+	return &FakeStore{
+		Sessions:  make(map[string]*Session),
+		Snapshots: make(map[string]*Snapshot),
+	}
 }
 
 func sessionKey(user, project string) string {
@@ -60,6 +65,19 @@ func (f *FakeStore) UpdateConnections(user, project string, delta int) (int, err
 	return sess.Connections, nil
 }
 
+func (f *FakeStore) TouchActivity(user, project string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sess, ok := f.Sessions[sessionKey(user, project)]
+	if !ok {
+		return nil
+	}
+	if at.After(sess.LastActivity) {
+		sess.LastActivity = at.UTC()
+	}
+	return nil
+}
+
 func (f *FakeStore) SetGracePeriod(user, project string, expiry time.Time) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -91,6 +109,23 @@ func (f *FakeStore) DeleteSession(user, project string) error {
 	return nil
 }
 
+func (f *FakeStore) ListSessions() ([]*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]*Session, 0, len(f.Sessions))
+	for _, sess := range f.Sessions {
+		cp := *sess
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].User != out[j].User {
+			return out[i].User < out[j].User
+		}
+		return out[i].Project < out[j].Project
+	})
+	return out, nil
+}
+
 func (f *FakeStore) ExpiredGracePeriods() ([]*Session, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -119,6 +154,33 @@ func (f *FakeStore) ExpiredLifetimes() ([]*Session, error) {
 	return out, nil
 }
 
+func (f *FakeStore) IdleSessions(cutoff time.Time) ([]*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*Session
+	for _, sess := range f.Sessions {
+		if sess.Status == "running" && sess.LastActivity.Before(cutoff) {
+			cp := *sess
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeStore) UserSessions(user string) ([]*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*Session
+	for _, sess := range f.Sessions {
+		if sess.User == user {
+			cp := *sess
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Project < out[j].Project })
+	return out, nil
+}
+
 func (f *FakeStore) StaleZeroConnections(user, project string) (*Session, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -133,4 +195,57 @@ func (f *FakeStore) StaleZeroConnections(user, project string) (*Session, error)
 	return nil, nil
 }
 
+func (f *FakeStore) SaveSnapshot(snap *Snapshot) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cp := *snap
+	f.Snapshots[sessionKey(snap.User, snap.Project)] = &cp
+	return nil
+}
+
+func (f *FakeStore) GetSnapshot(user, project string) (*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snap, ok := f.Snapshots[sessionKey(user, project)]
+	if !ok {
+		return nil, nil
+	}
+	cp := *snap
+	return &cp, nil
+}
+
+func (f *FakeStore) DeleteSnapshot(user, project string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Snapshots, sessionKey(user, project))
+	return nil
+}
+
+func (f *FakeStore) ExpiredSnapshots() ([]*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	var out []*Snapshot
+	for _, snap := range f.Snapshots {
+		if snap.ExpiresAt.Before(now) {
+			cp := *snap
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeStore) UserSnapshots(user string) ([]*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*Snapshot
+	for _, snap := range f.Snapshots {
+		if snap.User == user {
+			cp := *snap
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
 func (f *FakeStore) Close() error { return nil }
@@ -22,8 +22,31 @@ type Session struct {
 	CreatedAt     time.Time
 	LastActivity  time.Time
 	MaxLifetime   time.Time
-	NetworkID     string // Docker network for companion services
-	ServiceIDs    string // comma-separated container IDs
+	NetworkID     string  // Docker network for companion services
+	ServiceIDs    string  // comma-separated container IDs
+	CPUs          float64 // dev container plus services; 0 = unlimited
+	Memory        int64   // bytes, summed like CPUs; counted against the user's quota
+}
+
+// Snapshot is a committed image of a hibernated session's container,
+// restored the next time the user connects to the same project.
+type Snapshot struct {
+	User      string
+	Project   string
+	Image     string // snapshot image ref
+	BaseImage string // image the snapshotted container was created from
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// AuditRecord mirrors one audit log entry. Data is the entry's JSON
+// line exactly as written to the log file.
+type AuditRecord struct {
+	Hash string
+	Time time.Time
+	Type string
+	User string
+	Data string
 }
 
 // SessionStore is the interface for session persistence.
@@ -32,12 +55,23 @@ type SessionStore interface {
 	CreateSession(sess *Session) error
 	GetSession(user, project string) (*Session, error)
 	UpdateConnections(user, project string, delta int) (int, error)
+	TouchActivity(user, project string, at time.Time) error
 	SetGracePeriod(user, project string, expiry time.Time) error
 	CancelGracePeriod(user, project string) error
 	DeleteSession(user, project string) error
+	ListSessions() ([]*Session, error)
 	ExpiredGracePeriods() ([]*Session, error)
 	ExpiredLifetimes() ([]*Session, error)
+	IdleSessions(cutoff time.Time) ([]*Session, error)
+	UserSessions(user string) ([]*Session, error)
 	StaleZeroConnections(user, project string) (*Session, error)
+
+	SaveSnapshot(snap *Snapshot) error // replaces any existing snapshot for user/project
+	GetSnapshot(user, project string) (*Snapshot, error)
+	DeleteSnapshot(user, project string) error
+	ExpiredSnapshots() ([]*Snapshot, error)
+	UserSnapshots(user string) ([]*Snapshot, error)
+
 	Close() error
 }
 
@@ -47,7 +81,7 @@ type Store struct {
 
 var _ SessionStore = (*Store)(nil)
 
-const schemaVersion = 2
+const schemaVersion = 3
 
 func Open(dbPath string) (*Store, error) {
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
@@ -87,6 +121,35 @@ func migrate(db *sql.DB) error {
 		return fmt.Errorf("reading schema version: %w", err)
 	}
 
+	// Snapshots outlive sessions, and the audit mirror outlives
+	// everything, so these tables are never dropped on upgrade.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS snapshots (
+			user       TEXT NOT NULL,
+			project    TEXT NOT NULL DEFAULT '',
+			image      TEXT NOT NULL,
+			base_image TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			PRIMARY KEY (user, project)
+		)`)
+	if err != nil {
+		return fmt.Errorf("creating snapshots table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id   INTEGER PRIMARY KEY AUTOINCREMENT,
+			hash TEXT NOT NULL UNIQUE,
+			time DATETIME NOT NULL,
+			type TEXT NOT NULL,
+			user TEXT NOT NULL DEFAULT '',
+			data TEXT NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("creating audit_log table: %w", err)
+	}
+
 	if version >= schemaVersion {
 		return nil
 	}
@@ -109,6 +172,8 @@ func migrate(db *sql.DB) error {
 			max_lifetime   DATETIME NOT NULL,
 			network_id     TEXT NOT NULL DEFAULT '',
 			service_ids    TEXT NOT NULL DEFAULT '',
+			cpus           REAL NOT NULL DEFAULT 0,
+			memory         INTEGER NOT NULL DEFAULT 0,
 			PRIMARY KEY (user, project)
 		)`)
 	if err != nil {
@@ -133,17 +198,17 @@ func (s *Store) Close() error {
 
 func (s *Store) CreateSession(sess *Session) error {
 	_, err := s.db.Exec(
-		`INSERT INTO sessions (user, project, container_id, container_name, image, status, connections, created_at, last_activity, max_lifetime, network_id, service_ids)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO sessions (user, project, container_id, container_name, image, status, connections, created_at, last_activity, max_lifetime, network_id, service_ids, cpus, memory)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		sess.User, sess.Project, sess.ContainerID, sess.ContainerName, sess.Image,
 		sess.Status, sess.Connections,
 		sess.CreatedAt.UTC(), sess.LastActivity.UTC(), sess.MaxLifetime.UTC(),
-		sess.NetworkID, sess.ServiceIDs,
+		sess.NetworkID, sess.ServiceIDs, sess.CPUs, sess.Memory,
 	)
 	return err
 }
 
-const sessionColumns = `user, project, container_id, container_name, image, status, connections, grace_expiry, created_at, last_activity, max_lifetime, network_id, service_ids`
+const sessionColumns = `user, project, container_id, container_name, image, status, connections, grace_expiry, created_at, last_activity, max_lifetime, network_id, service_ids, cpus, memory`
 
 func scanSession(scanner interface{ Scan(...any) error }) (*Session, error) {
 	sess := &Session{}
@@ -151,7 +216,7 @@ func scanSession(scanner interface{ Scan(...any) error }) (*Session, error) {
 		&sess.User, &sess.Project, &sess.ContainerID, &sess.ContainerName, &sess.Image,
 		&sess.Status, &sess.Connections, &sess.GraceExpiry,
 		&sess.CreatedAt, &sess.LastActivity, &sess.MaxLifetime,
-		&sess.NetworkID, &sess.ServiceIDs,
+		&sess.NetworkID, &sess.ServiceIDs, &sess.CPUs, &sess.Memory,
 	)
 	return sess, err
 }
@@ -184,6 +249,17 @@ func (s *Store) UpdateConnections(user, project string, delta int) (int, error)
 	return count, nil
 }
 
+// TouchActivity records I/O activity on a session. Never moves
+// last_activity backwards, so heartbeats from concurrent connections
+// can arrive in any order.
+func (s *Store) TouchActivity(user, project string, at time.Time) error {
+	_, err := s.db.Exec(
+		`UPDATE sessions SET last_activity = MAX(last_activity, ?) WHERE user = ? AND project = ?`,
+		at.UTC(), user, project,
+	)
+	return err
+}
+
 func (s *Store) SetGracePeriod(user, project string, expiry time.Time) error {
 	_, err := s.db.Exec(
 		`UPDATE sessions SET status = 'grace_period', grace_expiry = ? WHERE user = ? AND project = ?`,
@@ -205,6 +281,11 @@ func (s *Store) DeleteSession(user, project string) error {
 	return err
 }
 
+// ListSessions returns every session row, ordered by user then project.
+func (s *Store) ListSessions() ([]*Session, error) {
+	return s.queryMultiple(`SELECT ` + sessionColumns + ` FROM sessions ORDER BY user, project`)
+}
+
 func (s *Store) ExpiredGracePeriods() ([]*Session, error) {
 	return s.queryMultiple(
 		`SELECT `+sessionColumns+` FROM sessions WHERE status = 'grace_period' AND grace_expiry < ?`,
@@ -219,6 +300,20 @@ func (s *Store) ExpiredLifetimes() ([]*Session, error) {
 	)
 }
 
+// IdleSessions returns running sessions with no recorded activity since
+// cutoff. Sessions in their grace period are left to grace expiry.
+func (s *Store) IdleSessions(cutoff time.Time) ([]*Session, error) {
+	return s.queryMultiple(
+		`SELECT `+sessionColumns+` FROM sessions WHERE status = 'running' AND last_activity < ?`,
+		cutoff.UTC(),
+	)
+}
+
+// UserSessions returns all of a user's sessions, across projects.
+func (s *Store) UserSessions(user string) ([]*Session, error) {
+	return s.queryMultiple(`SELECT `+sessionColumns+` FROM sessions WHERE user = ? ORDER BY project`, user)
+}
+
 func (s *Store) StaleZeroConnections(user, project string) (*Session, error) {
 	row := s.db.QueryRow(
 		`SELECT `+sessionColumns+` FROM sessions WHERE user = ? AND project = ? AND connections = 0 AND grace_expiry IS NULL`,
@@ -251,3 +346,98 @@ func (s *Store) queryMultiple(query string, args ...any) ([]*Session, error) {
 	}
 	return sessions, rows.Err()
 }
+
+func (s *Store) SaveSnapshot(snap *Snapshot) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO snapshots (user, project, image, base_image, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		snap.User, snap.Project, snap.Image, snap.BaseImage,
+		snap.CreatedAt.UTC(), snap.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+const snapshotColumns = `user, project, image, base_image, created_at, expires_at`
+
+func scanSnapshot(scanner interface{ Scan(...any) error }) (*Snapshot, error) {
+	snap := &Snapshot{}
+	err := scanner.Scan(
+		&snap.User, &snap.Project, &snap.Image, &snap.BaseImage,
+		&snap.CreatedAt, &snap.ExpiresAt,
+	)
+	return snap, err
+}
+
+func (s *Store) GetSnapshot(user, project string) (*Snapshot, error) {
+	row := s.db.QueryRow(
+		`SELECT `+snapshotColumns+` FROM snapshots WHERE user = ? AND project = ?`, user, project)
+
+	snap, err := scanSnapshot(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (s *Store) DeleteSnapshot(user, project string) error {
+	_, err := s.db.Exec(`DELETE FROM snapshots WHERE user = ? AND project = ?`, user, project)
+	return err
+}
+
+func (s *Store) ExpiredSnapshots() ([]*Snapshot, error) {
+	return s.querySnapshots(`SELECT `+snapshotColumns+` FROM snapshots WHERE expires_at < ?`, time.Now().UTC())
+}
+
+// UserSnapshots returns all of a user's snapshots, across projects.
+func (s *Store) UserSnapshots(user string) ([]*Snapshot, error) {
+	return s.querySnapshots(`SELECT `+snapshotColumns+` FROM snapshots WHERE user = ?`, user)
+}
+
+func (s *Store) querySnapshots(query string, args ...any) ([]*Snapshot, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var snaps []*Snapshot
+	for rows.Next() {
+		snap, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+func (s *Store) SaveAuditRecord(rec *AuditRecord) error {
+	_, err := s.db.Exec(
+		`INSERT INTO audit_log (hash, time, type, user, data) VALUES (?, ?, ?, ?, ?)`,
+		rec.Hash, rec.Time.UTC(), rec.Type, rec.User, rec.Data,
+	)
+	return err
+}
+
+// AuditHashes returns the hashes of every mirrored audit entry in the
+// order they were recorded.
+func (s *Store) AuditHashes() ([]string, error) {
+	rows, err := s.db.Query(`SELECT hash FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
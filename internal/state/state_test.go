@@ -226,6 +226,71 @@ func TestExpiredLifetimes(t *testing.T) {
 	}
 }
 
+func TestListSessionsOrdered(t *testing.T) {
+	store := openTestDB(t)
+
+	for _, k := range []struct{ user, project string }{
+		{"zoe", ""}, {"deploy", "web"}, {"deploy", ""},
+	} {
+		sess := testSessionData(k.user)
+		sess.Project = k.project
+		if err := store.CreateSession(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(got))
+	}
+	want := []string{"deploy/", "deploy/web", "zoe/"}
+	for i, sess := range got {
+		if key := sess.User + "/" + sess.Project; key != want[i] {
+			t.Errorf("sessions[%d] = %s, want %s", i, key, want[i])
+		}
+	}
+}
+
+func TestTouchActivityAndIdleSessions(t *testing.T) {
+	store := openTestDB(t)
+
+	old := time.Now().Add(-2 * time.Hour).UTC()
+	for _, user := range []string{"idle", "busy", "leaving"} {
+		sess := testSessionData(user)
+		sess.LastActivity = old
+		if err := store.CreateSession(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SetGracePeriod("leaving", "", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	recent := time.Now().UTC()
+	if err := store.TouchActivity("busy", "", recent); err != nil {
+		t.Fatal(err)
+	}
+	// An older heartbeat arriving late must not move activity backwards
+	if err := store.TouchActivity("busy", "", old); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := store.GetSession("busy", "")
+	if got.LastActivity.Before(recent.Add(-time.Second)) {
+		t.Errorf("last_activity = %v, want ~%v", got.LastActivity, recent)
+	}
+
+	idle, err := store.IdleSessions(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idle) != 1 || idle[0].User != "idle" {
+		t.Fatalf("idle sessions = %v, want only idle (grace-period sessions excluded)", idle)
+	}
+}
+
 func TestStaleZeroConnections(t *testing.T) {
 	store := openTestDB(t)
 
@@ -376,3 +441,143 @@ func TestNetworkAndServiceIDsRoundTrip(t *testing.T) {
 		t.Errorf("service_ids = %q, want svc-postgres,svc-redis", got.ServiceIDs)
 	}
 }
+
+func TestSnapshotSaveReplaceDelete(t *testing.T) {
+	store := openTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	snap := &Snapshot{
+		User:      "deploy",
+		Project:   "backend",
+		Image:     "podspawn/snapshot:deploy-backend",
+		BaseImage: "ubuntu:24.04",
+		CreatedAt: now,
+		ExpiresAt: now.Add(24 * time.Hour),
+	}
+	if err := store.SaveSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	snap.BaseImage = "ubuntu:25.04"
+	if err := store.SaveSnapshot(snap); err != nil {
+		t.Fatalf("saving again should replace: %v", err)
+	}
+
+	got, err := store.GetSnapshot("deploy", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.BaseImage != "ubuntu:25.04" || !got.ExpiresAt.Equal(snap.ExpiresAt) {
+		t.Fatalf("got %+v", got)
+	}
+
+	if err := store.DeleteSnapshot("deploy", "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetSnapshot("deploy", "backend"); got != nil {
+		t.Error("snapshot should be deleted")
+	}
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	store := openTestDB(t)
+	now := time.Now().UTC()
+	_ = store.SaveSnapshot(&Snapshot{User: "old", Image: "a", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+	_ = store.SaveSnapshot(&Snapshot{User: "fresh", Image: "a", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	expired, err := store.ExpiredSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].User != "old" {
+		t.Errorf("expired = %+v, want only old", expired)
+	}
+}
+
+func TestSnapshotsSurviveSchemaUpgrade(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store1, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	_ = store1.SaveSnapshot(&Snapshot{User: "deploy", Image: "a", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	// Pretend the db predates the current sessions schema
+	if _, err := store1.db.Exec(`UPDATE schema_version SET version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	_ = store1.Close()
+
+	store2, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store2.Close() }()
+
+	if got, _ := store2.GetSnapshot("deploy", ""); got == nil {
+		t.Error("snapshot should survive a sessions schema upgrade")
+	}
+}
+
+func TestUserSnapshots(t *testing.T) {
+	store := openTestDB(t)
+	now := time.Now()
+	_ = store.SaveSnapshot(&Snapshot{User: "deploy", Image: "a", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	_ = store.SaveSnapshot(&Snapshot{User: "deploy", Project: "backend", Image: "c", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	_ = store.SaveSnapshot(&Snapshot{User: "alice", Image: "d", BaseImage: "b", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	snaps, err := store.UserSnapshots("deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Errorf("expected 2 snapshots for deploy, got %d", len(snaps))
+	}
+}
+
+func TestUserSessionsWithResources(t *testing.T) {
+	store := openTestDB(t)
+	a := testSessionData("deploy")
+	a.CPUs, a.Memory = 2, 2<<30
+	b := testSessionData("deploy")
+	b.Project, b.ContainerName = "backend", "podspawn-deploy-backend"
+	b.CPUs, b.Memory = 0.5, 512<<20
+	_ = store.CreateSession(a)
+	_ = store.CreateSession(b)
+	_ = store.CreateSession(testSessionData("alice"))
+
+	sessions, err := store.UserSessions("deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions for deploy, got %d", len(sessions))
+	}
+	if sessions[0].Project != "" || sessions[0].CPUs != 2 || sessions[0].Memory != 2<<30 {
+		t.Errorf("first session = %+v", sessions[0])
+	}
+	if sessions[1].Project != "backend" || sessions[1].CPUs != 0.5 || sessions[1].Memory != 512<<20 {
+		t.Errorf("second session = %+v", sessions[1])
+	}
+}
+
+func TestAuditRecords(t *testing.T) {
+	store := openTestDB(t)
+	now := time.Now()
+	for _, h := range []string{"b2", "a1"} {
+		if err := store.SaveAuditRecord(&AuditRecord{Hash: h, Time: now, Type: "auth", User: "deploy", Data: "{}"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveAuditRecord(&AuditRecord{Hash: "a1", Time: now, Type: "auth", Data: "{}"}); err == nil {
+		t.Error("duplicate hash should be rejected")
+	}
+
+	hashes, err := store.AuditHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || hashes[0] != "b2" || hashes[1] != "a1" {
+		t.Errorf("hashes = %v, want insertion order", hashes)
+	}
+}